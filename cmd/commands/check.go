@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
-	"github.com/boomskats/sqlc2proto/cmd/common"
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/typecheck"
+	"github.com/boomskats/sqlc2proto/pkg/gen"
 	"github.com/spf13/cobra"
 )
 
@@ -14,78 +17,140 @@ func NewCheckCmd() *cobra.Command {
 	checkCmd := &cobra.Command{
 		Use:   "check",
 		Short: "Check the generated files for correctness",
-		Long: `Checks if the imports in the generated mapper files are correct.
-This command verifies that the protobuf-generated Go code exists and can be imported.
+		Long: `Type-checks the generated mapper files.
+This command compiles the mappers together with the sqlc package and the protobuf-generated
+Go code, and reports compile errors (missing fields, type mismatches, wrong import paths)
+against the message and field they come from.
 It helps identify issues in the workflow between sqlc2proto and buf generate.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			configFile, _ := cmd.Flags().GetString("config")
 			verbose, _ := cmd.Flags().GetBool("verbose")
+			targetName, _ := cmd.Flags().GetString("target")
 
-			// Try to load config file
-			if configFile != "" {
-				if err := common.LoadConfigFile(configFile, &Config, verbose); err != nil {
-					fmt.Printf("Error loading config file: %v\n", err)
-					os.Exit(1)
-				}
-			} else {
-				// Try default config locations
-				common.TryLoadDefaultConfig(&Config, verbose)
-			}
-
-			if verbose {
-				common.PrintConfig(Config)
-			}
-
-			// Check if mappers are enabled
-			if !Config.GenerateMappers {
-				fmt.Println("Mapper generation is not enabled in the configuration.")
-				fmt.Println("Enable it with --with-mappers or by setting 'withMappers: true' in your config file.")
+			targets, err := loadTargets(cmd, targetName, os.Stdout)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
 				os.Exit(1)
 			}
 
-			// Check if mapper file exists
-			mappersDir := filepath.Join(Config.ProtoOutputDir, "mappers")
-			mapperPath := filepath.Join(mappersDir, "mappers.go")
-			if _, err := os.Stat(mapperPath); os.IsNotExist(err) {
-				fmt.Printf("Mapper file not found at %s\n", mapperPath)
-				fmt.Println("Run sqlc2proto first to generate the mapper file.")
-				os.Exit(1)
-			}
-
-			// Check if proto file exists
-			protoPath := filepath.Join(Config.ProtoOutputDir, "models.proto")
-			if _, err := os.Stat(protoPath); os.IsNotExist(err) {
-				fmt.Printf("Proto file not found at %s\n", protoPath)
-				fmt.Println("Run sqlc2proto first to generate the proto file.")
-				os.Exit(1)
-			}
-
-			// Determine the expected import path for the protobuf-generated Go code
-			expectedImportPath := Config.ProtoGoImport
-			if expectedImportPath == "" {
-				if Config.GoPackagePath != "" {
-					expectedImportPath = Config.GoPackagePath
-				} else {
-					expectedImportPath = common.InferGoPackage(Config.ProtoPackageName, Config.ModuleName)
+			failed := 0
+			for _, target := range targets {
+				if target.Name != "" {
+					fmt.Printf("Target %s:\n", target.Name)
+				}
+				cfg := target.Config
+				if verbose {
+					config.PrintConfig(os.Stdout, cfg, target.Sources)
+				}
+				if !checkTarget(&cfg) {
+					failed++
 				}
 			}
-
-			// Check if the protobuf-generated Go code exists
-			// This is a simple check that looks for the .pb.go file
-			protoGoPath := filepath.Join(Config.ProtoOutputDir, "models.pb.go")
-			if _, err := os.Stat(protoGoPath); os.IsNotExist(err) {
-				fmt.Printf("Protobuf-generated Go code not found at %s\n", protoGoPath)
-				fmt.Println("Run 'buf generate' to generate the Go code from the proto file.")
+			if failed > 0 {
 				os.Exit(1)
 			}
-
-			fmt.Println("✅ Verification successful!")
-			fmt.Println("The mapper file imports the protobuf-generated Go code correctly.")
-			fmt.Printf("Import path: %s\n", expectedImportPath)
 		},
 	}
 
 	checkCmd.Flags().BoolP("verbose", "v", false, "Enable verbose output")
+	checkCmd.Flags().String("target", "", "Only check the named target from the config file")
 
 	return checkCmd
 }
+
+// checkTarget type-checks the mappers generated for one target and reports
+// whether they compile
+func checkTarget(cfg *config.Config) bool {
+	// Check if mappers are enabled
+	if !cfg.GenerateMappers {
+		fmt.Println("Mapper generation is not enabled in the configuration.")
+		fmt.Println("Enable it with --with-mappers or by setting 'withMappers: true' in your config file.")
+		return false
+	}
+
+	// Check if mapper file exists
+	mappersDir := filepath.Join(cfg.ProtoOutputDir, "mappers")
+	mapperPath := filepath.Join(mappersDir, "mappers.go")
+	split := cfg.Layout == "split"
+	if split {
+		// The split layout keeps the shared helpers in their own file
+		mapperPath = filepath.Join(mappersDir, "helpers.go")
+	}
+	if _, err := os.Stat(mapperPath); os.IsNotExist(err) {
+		fmt.Printf("Mapper file not found at %s\n", mapperPath)
+		fmt.Println("Run sqlc2proto first to generate the mapper file.")
+		return false
+	}
+
+	// Check if proto file exists
+	protoPath := filepath.Join(cfg.ProtoOutputDir, "models.proto")
+	if split {
+		protoPath = filepath.Join(cfg.ProtoOutputDir, "*.proto")
+	}
+	if !generatedFileExists(protoPath) {
+		fmt.Printf("Proto file not found at %s\n", protoPath)
+		fmt.Println("Run sqlc2proto first to generate the proto file.")
+		return false
+	}
+
+	// Determine the expected import path for the protobuf-generated Go code
+	expectedImportPath := cfg.ProtoGoImport
+	if expectedImportPath == "" {
+		if cfg.GoPackagePath != "" {
+			expectedImportPath = cfg.GoPackagePath
+		} else {
+			expectedImportPath = config.InferGoPackage(cfg.ProtoPackageName, cfg.ModuleName)
+		}
+	}
+
+	// Type-check the mappers against the sqlc and protobuf-generated packages
+	messages, err := generatedMessages(*cfg)
+	if err != nil {
+		// Compile errors are still reported, just without their message and field
+		fmt.Printf("Warning: %v\n", err)
+	}
+	problems, err := typecheck.CheckMappers(mappersDir, messages)
+	if err != nil {
+		fmt.Printf("Failed to type-check mappers: %v\n", err)
+		return false
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Found %d problem(s) in the generated mappers:\n", len(problems))
+		missingProtoCode := false
+		for _, problem := range problems {
+			fmt.Printf("  %s\n", problem)
+			if strings.Contains(problem.Err, expectedImportPath) {
+				missingProtoCode = true
+			}
+		}
+		if missingProtoCode {
+			fmt.Printf("Run 'buf generate' to generate the Go code for %s.\n", expectedImportPath)
+		}
+		return false
+	}
+
+	fmt.Println("✅ Verification successful!")
+	fmt.Println("The mappers compile against the sqlc and protobuf-generated Go code.")
+	fmt.Printf("Import path: %s\n", expectedImportPath)
+	return true
+}
+
+// generatedMessages returns the messages generate builds for the mappers.
+// They go through the same Parse and Resolve steps, so that the field rules
+// of the includes file rename and exclude the same fields.
+func generatedMessages(cfg config.Config) ([]gen.Message, error) {
+	schema, err := gen.Parse(gen.ParseOptions{Config: cfg})
+	if err != nil {
+		return nil, err
+	}
+	plan, err := gen.Resolve(schema, gen.ResolveOptions{Config: cfg, ReadGoMod: true})
+	if err != nil {
+		return nil, err
+	}
+	return plan.Messages, nil
+}
+
+// generatedFileExists reports whether a file matching the given path or glob exists
+func generatedFileExists(pattern string) bool {
+	matches, err := filepath.Glob(pattern)
+	return err == nil && len(matches) > 0
+}
@@ -2,13 +2,13 @@ package commands
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
-	"github.com/boomskats/sqlc2proto/cmd/common"
-	"github.com/boomskats/sqlc2proto/internal/generator"
-	"github.com/boomskats/sqlc2proto/internal/includes"
-	"github.com/boomskats/sqlc2proto/internal/parser"
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/diff"
+	"github.com/boomskats/sqlc2proto/pkg/gen"
 	"github.com/spf13/cobra"
 )
 
@@ -26,230 +26,235 @@ Example:
 	 sqlc2proto generate --sqlc-dir=./db/sqlc --proto-dir=./proto --package=api.v1 --with-mappers --with-services
 `,
 		Run: func(cmd *cobra.Command, args []string) {
-			configFile, _ := cmd.Flags().GetString("config")
 			verbose, _ := cmd.Flags().GetBool("verbose")
 			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			check, _ := cmd.Flags().GetBool("check")
+			toStdout, _ := cmd.Flags().GetBool("stdout")
+			noCache, _ := cmd.Flags().GetBool("no-cache")
+			targetName, _ := cmd.Flags().GetString("target")
 
-			// Try to load config file if specified or exists in default location
-			if configFile != "" {
-				if err := common.LoadConfigFile(configFile, &Config, verbose); err != nil {
-					fmt.Printf("Error loading config file: %v\n", err)
-					os.Exit(1)
-				}
-			} else {
-				// Try default config locations (sqlc2proto.yaml, sqlc2proto.yml, .sqlc2proto.yaml, .sqlc2proto.yml)
-				common.TryLoadDefaultConfig(&Config, verbose)
-			}
-
-			// If go package is still empty, try to parse go.mod file
-			if Config.GoPackagePath == "" {
-				// Try to parse go.mod file to get module name
-				moduleName, err := common.GetModuleNameFromGoMod()
-				if err == nil && Config.ModuleName == "" {
-					// If we found a module name and it's not already set, use it
-					Config.ModuleName = moduleName
-					if verbose {
-						fmt.Printf("Found module name in go.mod: %s\n", moduleName)
-					}
-				}
-				// Now infer from proto package and moduleName (which might have been set from go.mod)
-				Config.GoPackagePath = common.InferGoPackage(Config.ProtoPackageName, Config.ModuleName)
+			// With --stdout the generated files are the only output; progress
+			// messages go to stderr so the stream can be piped
+			var out io.Writer = os.Stdout
+			if toStdout {
+				out = os.Stderr
 			}
 
-			if verbose {
-				common.PrintConfig(Config)
+			targets, err := loadTargets(cmd, targetName, out)
+			if err != nil {
+				fmt.Fprintf(out, "Error: %v\n", err)
+				os.Exit(1)
 			}
 
 			if dryRun {
-				fmt.Println("Dry run - no files will be generated")
+				fmt.Fprintln(out, "Dry run - no files will be generated")
 			}
 
-			// Ensure output directory exists
-			if !dryRun {
-				if err := os.MkdirAll(Config.ProtoOutputDir, 0o755); err != nil {
-					fmt.Printf("Failed to create output directory: %v\n", err)
-					os.Exit(1)
-				}
+			// Read-only modes must not touch the workspace, so they don't
+			// write to the parse cache either
+			useCache := !noCache && !check && !dryRun && !toStdout
+			files, err := renderTargets(targets, out, verbose, useCache)
+			if err != nil {
+				fmt.Fprintf(out, "Error: %v\n", err)
+				os.Exit(1)
 			}
 
-			// Check if includeFile is specified and exists
-			var includesData *includes.IncludesFile
-			if Config.IncludeFile != "" {
-				if verbose {
-					fmt.Printf("Looking for includes file at %s\n", Config.IncludeFile)
+			switch {
+			case toStdout:
+				if err := writeStream(os.Stdout, files); err != nil {
+					fmt.Fprintf(out, "Error: %v\n", err)
+					os.Exit(1)
 				}
-
-				// Try to load the includes file
-				includesFile, err := includes.LoadIncludesFile(Config.IncludeFile)
+			case check, dryRun:
+				changed, err := printDiffs(files, useColor())
 				if err != nil {
-					if os.IsNotExist(err) {
-						fmt.Printf("Includes file %s not found. Run 'sqlc2proto getincludes' to generate it.\n", Config.IncludeFile)
-						fmt.Println("Proceeding with generating all models and queries...")
-					} else {
-						fmt.Printf("Error loading includes file: %v\n", err)
-						os.Exit(1) // Halt on parsing errors
-					}
+					fmt.Fprintf(out, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				if dryRun {
+					fmt.Fprintf(out, "%d file(s) would be changed\n", changed)
+				} else if changed > 0 {
+					fmt.Fprintf(out, "%d generated file(s) are out of date. Run 'sqlc2proto generate' to update them.\n", changed)
+					os.Exit(1)
 				} else {
-					includesData = &includesFile
-					if verbose {
-						fmt.Printf("Loaded includes file with %d models and %d queries\n",
-							len(includesFile.Models), len(includesFile.Queries))
-					}
+					fmt.Fprintln(out, "Generated files are up to date.")
+				}
+			default:
+				if _, err := gen.Write(files, gen.WriteOptions{Log: writerLog(out, verbose)}); err != nil {
+					fmt.Fprintf(out, "Error: %v\n", err)
+					os.Exit(1)
 				}
 			}
+		},
+	}
 
-			// Process sqlc directory
-			messages, err := parser.ProcessSQLCDirectory(Config.SQLCDir, Config.FieldStyle)
-			if err != nil {
-				fmt.Printf("Failed to process sqlc directory: %v\n", err)
-				os.Exit(1)
-			}
+	// Add flags to the generate command. Flags that are set override the
+	// config file and the environment.
+	defaults := config.DefaultConfig()
+	generateCmd.Flags().String("target", "", "Only generate the named target from the targets list in the config file")
+	generateCmd.Flags().String("sqlc-dir", defaults.SQLCDir, "Directory containing sqlc-generated files")
+	generateCmd.Flags().String("proto-dir", defaults.ProtoOutputDir, "Directory to output .proto files")
+	generateCmd.Flags().String("package", defaults.ProtoPackageName, "Package name for proto files")
+	generateCmd.Flags().String("go-package", defaults.GoPackagePath, "Go package path for generated proto code")
+	generateCmd.Flags().String("module", defaults.ModuleName, "Module name for import paths")
+	generateCmd.Flags().String("proto-go-import", defaults.ProtoGoImport, "Import path for protobuf-generated Go code")
+	generateCmd.Flags().Bool("with-mappers", defaults.GenerateMappers, "Generate conversion functions between sqlc and proto types")
+	generateCmd.Flags().Bool("strict-mappers", defaults.MapperOptions.Strict, "Generate FromProto mappers that return an error for invalid input")
+	generateCmd.Flags().Bool("with-mapper-tests", defaults.MapperOptions.GenerateTests, "Generate round-trip tests for the mappers")
+	generateCmd.Flags().Bool("with-services", defaults.GenerateServices, "Generate service definitions from sqlc queries")
+	generateCmd.Flags().String("service-style", defaults.ServiceStyle, "Service style: 'rpc' (one request/response per query) or 'aip' (resource-oriented standard methods)")
+	generateCmd.Flags().String("layout", defaults.Layout, "Output layout: 'single' (models.proto, service.proto, mappers.go) or 'split' (one file per entity)")
+	generateCmd.Flags().String("field-style", defaults.FieldStyle, "Field naming style: 'json' (use json tags), 'snake_case' (convert to snake_case), or 'original' (keep original casing)")
+	generateCmd.Flags().String("include-file", defaults.IncludeFile, "Path to file specifying which models and queries to include")
+	generateCmd.Flags().Bool("dry-run", false, "Show a diff of what would be generated without writing files")
+	generateCmd.Flags().Bool("stdout", false, "Write the generated files to stdout, each preceded by a '==> path <==' marker, instead of to disk")
+	generateCmd.Flags().Bool("no-cache", false, "Parse all sqlc files instead of reusing cached results from "+cacheDir)
+	generateCmd.Flags().Bool("check", false, "Compare the generated files with the files on disk and exit non-zero if they are out of date")
 
-			// Parse the Querier interface if service generation is enabled
-			var queryMethods []parser.QueryMethod
-			if Config.GenerateServices {
-				queryMethods, err = parser.ParseSQLCQuerierInterface(Config.SQLCDir)
-				if err != nil {
-					if verbose {
-						fmt.Printf("Warning: Failed to parse Querier interface: %v\n", err)
-						fmt.Println("Make sure sqlc is configured with emit_interface: true")
-						fmt.Println("Skipping service generation...")
-					}
-				}
-			}
+	return generateCmd
+}
 
-			// Filter messages and queries based on includes file
-			if includesData != nil && (len(includesData.Models) > 0 || len(includesData.Queries) > 0) {
-				// Resolve dependencies for included queries
-				resolvedIncludes := includes.ResolveDependencies(*includesData, queryMethods, messages)
-
-				if verbose {
-					// Log which models are included due to dependencies
-					additions := includes.GetDependencyAdditions(*includesData, resolvedIncludes)
-					if len(additions) > 0 {
-						fmt.Println("Models included due to dependencies:")
-						for _, model := range additions {
-							fmt.Printf("  - %s\n", model)
-						}
-					}
-				}
+// cacheDir holds the parse cache, relative to the working directory
+const cacheDir = ".sqlc2proto/cache"
 
-				// Filter messages
-				var filteredMessages []parser.ProtoMessage
-				for _, msg := range messages {
-					if includes.IsModelIncluded(resolvedIncludes, msg.Name) {
-						filteredMessages = append(filteredMessages, msg)
-					}
-				}
-				messages = filteredMessages
-
-				// Filter query methods
-				if len(queryMethods) > 0 {
-					var filteredQueryMethods []parser.QueryMethod
-					for _, method := range queryMethods {
-						if includes.IsQueryIncluded(*includesData, method.Name) {
-							filteredQueryMethods = append(filteredQueryMethods, method)
-						}
-					}
-					queryMethods = filteredQueryMethods
-				}
+// buildID identifies the sqlc2proto build, so that cached parse results are
+// not reused after an upgrade
+func buildID() string {
+	id := Version
+	if exe, err := os.Executable(); err == nil {
+		if info, err := os.Stat(exe); err == nil {
+			id += fmt.Sprintf(" %d %d", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return id
+}
 
-				if verbose {
-					fmt.Printf("After filtering: %d message types and %d query methods\n",
-						len(messages), len(queryMethods))
-				}
-			}
+// writerLog prints the progress messages of the generation steps to w
+func writerLog(w io.Writer, verbose bool) gen.Log {
+	return gen.Log{
+		Printf: func(format string, args ...any) {
+			fmt.Fprintf(w, format, args...)
+		},
+		Verbose: verbose,
+	}
+}
 
-			if verbose {
-				fmt.Printf("Generating %d message types from %s\n", len(messages), Config.SQLCDir)
-				for _, msg := range messages {
-					fmt.Printf("  - %s (%d fields)\n", msg.Name, len(msg.Fields))
-				}
+// renderTargets renders the files of all targets in memory. Targets are
+// rendered in order, and two targets generating the same file is an error.
+// Progress messages are printed to out.
+func renderTargets(targets []config.Target, out io.Writer, verbose bool, useCache bool) (*gen.FileSet, error) {
+	all := &gen.FileSet{}
+	owners := make(map[string]string)
+
+	for _, target := range targets {
+		if target.Name != "" {
+			fmt.Fprintf(out, "Target %s:\n", target.Name)
+		}
+
+		files, err := renderTarget(target, out, verbose, useCache)
+		if err != nil {
+			if target.Name != "" {
+				return nil, fmt.Errorf("target %s: %w", target.Name, err)
 			}
+			return nil, err
+		}
 
-			// Generate proto file
-			protoPath := filepath.Join(Config.ProtoOutputDir, "models.proto")
-			if dryRun {
-				fmt.Printf("Would generate proto file: %s\n", protoPath)
-			} else {
-				if err := generator.GenerateProtoFile(messages, Config, protoPath); err != nil {
-					fmt.Printf("Failed to generate proto file: %v\n", err)
-					os.Exit(1)
-				}
-				fmt.Printf("Generated Protobuf definitions in %s\n", protoPath)
+		for _, file := range files.Files {
+			path := filepath.Clean(file.Path)
+			if owner, ok := owners[path]; ok {
+				return nil, fmt.Errorf("targets %s and %s both generate %s", owner, target.Name, file.Path)
 			}
+			owners[path] = target.Name
+		}
+		all.Files = append(all.Files, files.Files...)
+		all.Stale = append(all.Stale, files.Stale...)
+	}
 
-			// Generate mapper file if requested
-			if Config.GenerateMappers {
-				// Remove old mappers.go file if it exists (for backward compatibility)
-				oldMapperPath := filepath.Join(Config.ProtoOutputDir, "mappers.go")
-				if !dryRun {
-					// Ignore error if file doesn't exist
-					_ = os.Remove(oldMapperPath)
-				}
+	return all, nil
+}
 
-				// Create mappers directory
-				mappersDir := filepath.Join(Config.ProtoOutputDir, "mappers")
-				if !dryRun {
-					if err := os.MkdirAll(mappersDir, 0o755); err != nil {
-						fmt.Printf("Failed to create mappers directory: %v\n", err)
-						os.Exit(1)
-					}
-				}
+// renderTarget parses the sqlc directory of a target and renders its files
+// in memory
+func renderTarget(target config.Target, out io.Writer, verbose bool, useCache bool) (*gen.FileSet, error) {
+	cfg := target.Config
+	log := writerLog(out, verbose)
 
-				mapperPath := filepath.Join(mappersDir, "mappers.go")
-				if dryRun {
-					fmt.Printf("Would generate mapper file: %s\n", mapperPath)
-				} else {
-					if err := generator.GenerateMapperFile(messages, Config, mapperPath); err != nil {
-						fmt.Printf("Failed to generate mapper file: %v\n", err)
-						os.Exit(1)
-					}
-					fmt.Printf("Generated mapper functions in %s\n", mapperPath)
-				}
-			}
+	// Reuse the parse results of sqlc files that haven't changed
+	parseOpts := gen.ParseOptions{Config: cfg, Log: log}
+	if useCache {
+		parseOpts.CacheDir = cacheDir
+		parseOpts.CacheKey = buildID()
+	}
+	schema, err := gen.Parse(parseOpts)
+	if err != nil {
+		return nil, err
+	}
 
-			// Generate service definitions if requested
-			if Config.GenerateServices && len(queryMethods) > 0 {
-				if verbose {
-					fmt.Printf("Generating services for %d query methods\n", len(queryMethods))
-					for _, method := range queryMethods {
-						fmt.Printf("  - %s (returns %s)\n", method.Name, method.ReturnType)
-					}
-				}
+	plan, err := gen.Resolve(schema, gen.ResolveOptions{Config: cfg, ReadGoMod: true, Log: log})
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		config.PrintConfig(out, plan.Config, target.Sources)
+	}
 
-				// Generate service definitions
-				services := parser.GenerateServiceDefinitions(queryMethods, messages)
+	return gen.Render(plan, gen.RenderOptions{Log: log})
+}
 
-				// Generate service.proto file
-				servicePath := filepath.Join(Config.ProtoOutputDir, "service.proto")
-				if dryRun {
-					fmt.Printf("Would generate service file: %s\n", servicePath)
-				} else {
-					if err := generator.GenerateServiceFile(services, Config, servicePath); err != nil {
-						fmt.Printf("Failed to generate service file: %v\n", err)
-						os.Exit(1)
-					}
-					fmt.Printf("Generated service definitions in %s\n", servicePath)
-				}
-			} else if Config.GenerateServices && len(queryMethods) == 0 {
-				fmt.Println("No query methods found or selected. Skipping service generation.")
-			}
-		},
+// printDiffs prints a unified diff between each file on disk and its
+// rendered content, including files that would be removed, and returns the
+// number of files that differ
+func printDiffs(files *gen.FileSet, color bool) (int, error) {
+	changes, err := files.Changes()
+	if err != nil {
+		return 0, err
 	}
 
-	// Add flags to the generate command
-	generateCmd.Flags().StringVar(&Config.SQLCDir, "sqlc-dir", Config.SQLCDir, "Directory containing sqlc-generated files")
-	generateCmd.Flags().StringVar(&Config.ProtoOutputDir, "proto-dir", Config.ProtoOutputDir, "Directory to output .proto files")
-	generateCmd.Flags().StringVar(&Config.ProtoPackageName, "package", Config.ProtoPackageName, "Package name for proto files")
-	generateCmd.Flags().StringVar(&Config.GoPackagePath, "go-package", Config.GoPackagePath, "Go package path for generated proto code")
-	generateCmd.Flags().StringVar(&Config.ModuleName, "module", Config.ModuleName, "Module name for import paths")
-	generateCmd.Flags().StringVar(&Config.ProtoGoImport, "proto-go-import", Config.ProtoGoImport, "Import path for protobuf-generated Go code")
-	generateCmd.Flags().BoolVar(&Config.GenerateMappers, "with-mappers", Config.GenerateMappers, "Generate conversion functions between sqlc and proto types")
-	generateCmd.Flags().BoolVar(&Config.GenerateServices, "with-services", Config.GenerateServices, "Generate service definitions from sqlc queries")
-	generateCmd.Flags().StringVar(&Config.FieldStyle, "field-style", Config.FieldStyle, "Field naming style: 'json' (use json tags), 'snake_case' (convert to snake_case), or 'original' (keep original casing)")
-	generateCmd.Flags().StringVar(&Config.IncludeFile, "include-file", Config.IncludeFile, "Path to file specifying which models and queries to include")
-	generateCmd.Flags().Bool("dry-run", false, "Show what would be generated without writing files")
+	for _, change := range changes {
+		// New files are shown in full, removed files as deleted
+		oldName, newName := "a/"+filepath.ToSlash(change.Path), "b/"+filepath.ToSlash(change.Path)
+		if change.Old == nil {
+			oldName = "/dev/null"
+		}
+		if change.New == nil {
+			newName = "/dev/null"
+		}
 
-	return generateCmd
+		d := diff.Unified(oldName, newName, change.Old, change.New)
+		if color {
+			d = diff.Colorize(d)
+		}
+		fmt.Print(d)
+	}
+
+	return len(changes), nil
+}
+
+// writeStream writes all rendered files to a single stream, each preceded
+// by a "==> path <==" marker line
+func writeStream(w io.Writer, files *gen.FileSet) error {
+	for i, file := range files.Files {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "==> %s <==\n", filepath.ToSlash(file.Path)); err != nil {
+			return err
+		}
+		if _, err := w.Write(file.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useColor reports whether diffs should be colorised, i.e. stdout is a
+// terminal and NO_COLOR is not set
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
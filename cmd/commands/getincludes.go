@@ -7,9 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/boomskats/sqlc2proto/cmd/common"
 	"github.com/boomskats/sqlc2proto/internal/includes"
-	"github.com/boomskats/sqlc2proto/internal/parser"
+	"github.com/boomskats/sqlc2proto/pkg/gen"
 	"github.com/spf13/cobra"
 )
 
@@ -21,29 +20,41 @@ func NewGetIncludesCmd() *cobra.Command {
 		Long: `Generate a YAML file listing all available models and queries.
 This file can be edited to select which models and queries to include in the generation process.
 
+With --merge, an existing file is updated instead of replaced: selections,
+comments and formatting are kept, new models and queries are added as
+commented-out entries, and entries that no longer exist are marked as stale.
+
 Example:
      sqlc2proto getincludes --output=./custom-includes.yaml
+     sqlc2proto getincludes --target=billing
+     sqlc2proto getincludes --merge
 `,
 		Run: func(cmd *cobra.Command, args []string) {
-			configFile, _ := cmd.Flags().GetString("config")
 			verbose, _ := cmd.Flags().GetBool("verbose")
 			outputPath, _ := cmd.Flags().GetString("output")
 			force, _ := cmd.Flags().GetBool("force")
+			merge, _ := cmd.Flags().GetBool("merge")
+			targetName, _ := cmd.Flags().GetString("target")
 
-			// Try to load config file if specified or exists in default location
-			if configFile != "" {
-				if err := common.LoadConfigFile(configFile, &Config, verbose); err != nil {
-					fmt.Printf("Error loading config file: %v\n", err)
-					os.Exit(1)
+			// Each target has its own sqlc directory and includes file
+			targets, err := loadTargets(cmd, targetName, os.Stdout)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if len(targets) > 1 {
+				var names []string
+				for _, target := range targets {
+					names = append(names, target.Name)
 				}
-			} else {
-				// Try default config locations
-				common.TryLoadDefaultConfig(&Config, verbose)
+				fmt.Printf("Error: the config file defines %d targets (%s), select one with --target\n", len(targets), strings.Join(names, ", "))
+				os.Exit(1)
 			}
+			config := targets[0].Config
 
 			// If output path is not specified, use the one from config
 			if outputPath == "" {
-				outputPath = Config.IncludeFile
+				outputPath = config.IncludeFile
 			}
 
 			if verbose {
@@ -51,20 +62,26 @@ Example:
 			}
 
 			// Check if the output file already exists
-			if _, err := os.Stat(outputPath); err == nil && !force {
+			existing, err := os.ReadFile(outputPath)
+			exists := err == nil
+			if exists && !force && !merge {
 				// File exists, ask for confirmation
 				fmt.Printf("File %s already exists. Overwrite? (y/N): ", outputPath)
 				reader := bufio.NewReader(os.Stdin)
 				response, _ := reader.ReadString('\n')
 				response = strings.TrimSpace(strings.ToLower(response))
 				if response != "y" && response != "yes" {
-					fmt.Println("Operation cancelled.")
+					fmt.Println("Operation cancelled. Use --merge to update the file while keeping your selections.")
 					return
 				}
 			}
 
-			// Process sqlc directory to find all models
-			messages, err := parser.ProcessSQLCDirectory(Config.SQLCDir, Config.FieldStyle)
+			// Process sqlc directory to find all models, and the Querier
+			// interface for queries if service generation is enabled. The
+			// existing includes file must not filter the listing.
+			parseConfig := config
+			parseConfig.IncludeFile = ""
+			schema, err := gen.Parse(gen.ParseOptions{Config: parseConfig, Log: writerLog(os.Stdout, verbose)})
 			if err != nil {
 				fmt.Printf("Failed to process sqlc directory: %v\n", err)
 				os.Exit(1)
@@ -72,42 +89,52 @@ Example:
 
 			// Extract model names
 			var modelNames []string
-			for _, msg := range messages {
+			for _, msg := range schema.Messages {
 				modelNames = append(modelNames, msg.Name)
 			}
 
 			if verbose {
-				fmt.Printf("Found %d models in %s\n", len(modelNames), Config.SQLCDir)
+				fmt.Printf("Found %d models in %s\n", len(modelNames), config.SQLCDir)
 				for _, model := range modelNames {
 					fmt.Printf("  - %s\n", model)
 				}
 			}
 
-			// Parse the Querier interface to find all queries
+			// Extract query names
 			var queryNames []string
-			if Config.GenerateServices {
-				queryMethods, err := parser.ParseSQLCQuerierInterface(Config.SQLCDir)
-				if err != nil {
-					if verbose {
-						fmt.Printf("Warning: Failed to parse Querier interface: %v\n", err)
-						fmt.Println("Make sure sqlc is configured with emit_interface: true")
-						fmt.Println("No queries will be included in the template.")
+			for _, method := range schema.Queries {
+				queryNames = append(queryNames, method.Name)
+			}
+
+			if verbose {
+				if config.GenerateServices {
+					fmt.Printf("Found %d queries in Querier interface\n", len(queryNames))
+					for _, query := range queryNames {
+						fmt.Printf("  - %s\n", query)
 					}
 				} else {
-					// Extract query names
-					for _, method := range queryMethods {
-						queryNames = append(queryNames, method.Name)
-					}
+					fmt.Println("Service generation is disabled, no queries will be included in the template.")
+				}
+			}
 
-					if verbose {
-						fmt.Printf("Found %d queries in Querier interface\n", len(queryNames))
-						for _, query := range queryNames {
-							fmt.Printf("  - %s\n", query)
-						}
-					}
+			// Update the existing file in place
+			if merge && exists {
+				merged, result, err := includes.MergeIncludes(existing, modelNames, queryNames)
+				if err != nil {
+					fmt.Printf("Failed to merge %s: %v\n", outputPath, err)
+					os.Exit(1)
+				}
+				printMergeResult(result, verbose)
+				if string(merged) == string(existing) {
+					fmt.Printf("%s is up to date\n", outputPath)
+					return
 				}
-			} else if verbose {
-				fmt.Println("Service generation is disabled, no queries will be included in the template.")
+				if err := os.WriteFile(outputPath, merged, 0o644); err != nil {
+					fmt.Printf("Failed to write includes file: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("Updated %s\n", outputPath)
+				return
 			}
 
 			// Ensure the output directory exists
@@ -134,6 +161,32 @@ Example:
 	// Add flags
 	getIncludesCmd.Flags().String("output", "", "Output file path (default: value of includeFile in config or sqlc2proto.includes.yaml)")
 	getIncludesCmd.Flags().Bool("force", false, "Overwrite existing file without confirmation")
+	getIncludesCmd.Flags().Bool("merge", false, "Update an existing file, keeping its selections and comments")
+	getIncludesCmd.Flags().String("target", "", "Target from the config file to list models and queries for")
 
 	return getIncludesCmd
 }
+
+// printMergeResult prints the entries added to or marked as stale in an
+// includes file
+func printMergeResult(result includes.MergeResult, verbose bool) {
+	for _, change := range []struct {
+		names  []string
+		format string
+	}{
+		{result.AddedModels, "Added %d new model(s) as commented-out entries\n"},
+		{result.AddedQueries, "Added %d new queries as commented-out entries\n"},
+		{result.StaleModels, "Marked %d model entries that match nothing as stale\n"},
+		{result.StaleQueries, "Marked %d query entries that match nothing as stale\n"},
+	} {
+		if len(change.names) == 0 {
+			continue
+		}
+		fmt.Printf(change.format, len(change.names))
+		if verbose {
+			for _, name := range change.names {
+				fmt.Printf("  - %s\n", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/boomskats/sqlc2proto/cmd/common"
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/graph"
+	"github.com/boomskats/sqlc2proto/pkg/gen"
+	"github.com/spf13/cobra"
+)
+
+// NewGraphCmd creates the graph command
+func NewGraphCmd() *cobra.Command {
+	graphCmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Print the dependency graph of structs, queries, messages and services",
+		Long: `Prints the relationships between the sqlc structs, the queries using them,
+the request and response messages and the services generated from them, as a
+Graphviz DOT graph or a Mermaid flowchart.
+
+Only what would be generated is shown, after applying the includes file. When
+the sqlc schema is available, from sqlc.yaml or --schema, foreign keys between
+the models are shown too. With --focus, the graph is limited to what a node
+depends on and what depends on it, which shows why a model is generated.
+
+Example:
+	 sqlc2proto graph > graph.dot
+	 sqlc2proto graph --format mermaid --focus Book
+	 sqlc2proto graph --schema db/schema.sql -o graph.dot
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			verbose, _ := cmd.Flags().GetBool("verbose")
+			format, _ := cmd.Flags().GetString("format")
+			outputPath, _ := cmd.Flags().GetString("output")
+			focus, _ := cmd.Flags().GetString("focus")
+			schemaPaths, _ := cmd.Flags().GetStringSlice("schema")
+			targetName, _ := cmd.Flags().GetString("target")
+
+			// The graph is the only output on stdout; progress messages go to
+			// stderr so it can be piped
+			var out io.Writer = os.Stdout
+			if outputPath == "" {
+				out = os.Stderr
+			}
+
+			write := graph.WriteDOT
+			switch format {
+			case "dot":
+			case "mermaid":
+				write = graph.WriteMermaid
+			default:
+				fmt.Fprintf(out, "Error: unknown format %q, expected dot or mermaid\n", format)
+				os.Exit(1)
+			}
+
+			targets, err := loadTargets(cmd, targetName, out)
+			if err != nil {
+				fmt.Fprintf(out, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if len(targets) > 1 {
+				var names []string
+				for _, target := range targets {
+					names = append(names, target.Name)
+				}
+				fmt.Fprintf(out, "Error: the config file defines %d targets (%s), select one with --target\n", len(targets), strings.Join(names, ", "))
+				os.Exit(1)
+			}
+
+			g, err := buildGraph(targets[0].Config, schemaPaths, out, verbose)
+			if err != nil {
+				fmt.Fprintf(out, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			if focus != "" {
+				nodes := g.Find(focus)
+				if len(nodes) == 0 {
+					fmt.Fprintf(out, "Error: no struct, query, message or service named %s is generated\n", focus)
+					os.Exit(1)
+				}
+				g = g.Focus(nodes[0])
+			}
+
+			if outputPath == "" {
+				if err := write(os.Stdout, g); err != nil {
+					fmt.Fprintf(out, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+			file, err := os.Create(outputPath)
+			if err != nil {
+				fmt.Fprintf(out, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			err = write(file, g)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				fmt.Fprintf(out, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(out, "Wrote the graph to %s\n", outputPath)
+		},
+	}
+
+	graphCmd.Flags().StringP("format", "f", "dot", "Output format: 'dot' (Graphviz) or 'mermaid'")
+	graphCmd.Flags().StringP("output", "o", "", "Write the graph to a file instead of stdout")
+	graphCmd.Flags().String("focus", "", "Only show what the named struct, query, message or service depends on and what depends on it")
+	graphCmd.Flags().StringSlice("schema", nil, "SQL schema files or directories to read foreign keys from (default: the schema in sqlc.yaml)")
+	graphCmd.Flags().String("target", "", "Target from the config file to show the graph of")
+
+	return graphCmd
+}
+
+// buildGraph builds the graph of what the configuration generates, with the
+// foreign keys of the schema if it can be found. Progress messages are
+// printed to out.
+func buildGraph(cfg config.Config, schemaPaths []string, out io.Writer, verbose bool) (*graph.Graph, error) {
+	log := writerLog(out, verbose)
+	schema, err := gen.Parse(gen.ParseOptions{Config: cfg, Log: log})
+	if err != nil {
+		return nil, err
+	}
+	plan, err := gen.Resolve(schema, gen.ResolveOptions{Config: cfg, ReadGoMod: true, Log: log})
+	if err != nil {
+		return nil, err
+	}
+
+	g := graph.New(plan.Messages, plan.Queries)
+	g.AddServices(plan.Services)
+
+	// Without --schema, use the schema sqlc generates the package from
+	if len(schemaPaths) == 0 {
+		project, err := common.DetectProject(".")
+		if err != nil {
+			fmt.Fprintf(out, "Warning: %v\n", err)
+		}
+		schemaPaths = project.SchemaPaths(cfg.SQLCDir)
+	}
+	if len(schemaPaths) == 0 {
+		if verbose {
+			fmt.Fprintln(out, "No SQL schema found, foreign keys are not shown")
+		}
+		return g, nil
+	}
+
+	keys, err := graph.LoadForeignKeys(schemaPaths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema: %w", err)
+	}
+	if verbose {
+		fmt.Fprintf(out, "Found %d foreign key(s) in %s\n", len(keys), strings.Join(schemaPaths, ", "))
+		for _, key := range keys {
+			fmt.Fprintf(out, "  - %s\n", key)
+		}
+	}
+	g.AddForeignKeys(keys)
+	return g, nil
+}
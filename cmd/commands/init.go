@@ -1,10 +1,15 @@
 package commands
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"strings"
 
 	"github.com/boomskats/sqlc2proto/cmd/common"
+	"github.com/boomskats/sqlc2proto/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -13,11 +18,24 @@ func NewInitCmd() *cobra.Command {
 	initCmd := &cobra.Command{
 		Use:   "init",
 		Short: "Initialize a new sqlc2proto configuration file",
-		Long: `Creates a new sqlc2proto.yaml configuration file with default values.
-You can then edit this file to customize the behavior of sqlc2proto.`,
+		Long: `Creates a new sqlc2proto.yaml configuration file for the project in the
+current directory.
+
+The project's go.mod, sqlc.yaml, buf.yaml and buf.gen.yaml are inspected to
+propose the sqlc directory, the proto package and Go import paths that match
+the buf setup. Each proposal can be accepted or changed interactively, or all
+of them accepted with --yes. Missing buf.yaml and buf.gen.yaml files can be
+created to generate Go code and Connect handlers next to the proto files.
+
+Example:
+	 sqlc2proto init
+	 sqlc2proto init --yes --buf
+`,
 		Run: func(cmd *cobra.Command, args []string) {
 			configFile, _ := cmd.Flags().GetString("output")
 			verbose, _ := cmd.Flags().GetBool("verbose")
+			yes, _ := cmd.Flags().GetBool("yes")
+			scaffoldBuf, _ := cmd.Flags().GetBool("buf")
 
 			if configFile == "" {
 				configFile = "sqlc2proto.yaml"
@@ -29,48 +47,217 @@ You can then edit this file to customize the behavior of sqlc2proto.`,
 				os.Exit(1)
 			}
 
-			// Create config file with default values
-			config := common.Config{
+			project, err := common.DetectProject(".")
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			printProject(project, verbose)
+
+			// Start from the defaults and fill in what the project tells us
+			cfg := config.Config{
 				SQLCDir:          "./db/sqlc",
 				ProtoOutputDir:   "./proto/gen",
-				ProtoPackageName: "api.v1",
-				GoPackagePath:    "",
-				GenerateMappers:  false,
-				GenerateServices: false,
+				ProtoPackageName: project.ProtoPackage(),
 				ServiceNaming:    "entity",
 				ServiceSuffix:    "Service",
-				// Note: GenerateImpl field has been removed as Connect-RPC tooling
-				// will generate the service implementation code from the proto definitions.
-				ModuleName:    "",
-				TypeMappings:  map[string]string{},
-				ProtoGoImport: "",     // Import path for protobuf-generated Go code
-				FieldStyle:    "json", // Default to using JSON tags
-			}
-
-			// Try to parse go.mod file to get module name
-			moduleName, err := common.GetModuleNameFromGoMod()
-			if err == nil {
-				// If we found a module name, use it to set GoPackagePath
-				config.ModuleName = moduleName
-				config.GoPackagePath = fmt.Sprintf("%s/proto", moduleName)
-				if verbose {
-					fmt.Printf("Found module name in go.mod: %s\n", moduleName)
-					fmt.Printf("Setting GoPackagePath to: %s\n", config.GoPackagePath)
-				}
+				ServiceStyle:     "rpc",
+				Layout:           "single",
+				ModuleName:       project.ModuleName,
+				TypeMappings:     map[string]string{},
+				FieldStyle:       "json", // Default to using JSON tags
+			}
+			cfg.MapperOptions.EmitEmptySlices = project.EmitEmptySlices
+			if len(project.SQLCDirs) > 0 {
+				cfg.SQLCDir = project.SQLCDirs[0]
+			}
+
+			p := &prompter{reader: bufio.NewReader(os.Stdin), yes: yes}
+
+			cfg.SQLCDir = p.ask("sqlc output directory", cfg.SQLCDir, nil)
+			cfg.ProtoPackageName = p.ask("Proto package", cfg.ProtoPackageName, func(value string) error {
+				return config.Config{ProtoPackageName: value}.Validate()
+			})
+
+			// Proto files of a buf module in a subdirectory go below it, in
+			// the directory matching their package
+			if project.BufRoot != "." {
+				cfg.ProtoOutputDir = "./" + path.Join(project.BufRoot, strings.ReplaceAll(cfg.ProtoPackageName, ".", "/"))
+			}
+			cfg.ProtoOutputDir = p.ask("Proto output directory", cfg.ProtoOutputDir, nil)
+
+			// Offer to create the buf files that are missing
+			writeBufConfig, writeBufGenConfig := project.BufConfig == "", project.BufGenConfig == ""
+			var bufFiles []string
+			if writeBufConfig {
+				bufFiles = append(bufFiles, "buf.yaml")
+			}
+			if writeBufGenConfig {
+				bufFiles = append(bufFiles, "buf.gen.yaml")
+			}
+			if len(bufFiles) == 0 || !p.confirm(fmt.Sprintf("Create %s for Connect", strings.Join(bufFiles, " and ")), scaffoldBuf) {
+				writeBufConfig, writeBufGenConfig = false, false
+			}
+			if writeBufGenConfig {
+				// The scaffolded buf.gen.yaml generates code next to the proto files
+				project.BufGenConfig = "buf.gen.yaml"
+				project.BufGoOut = "."
+				project.BufSourceRelative = true
+			}
+
+			// With paths=source_relative, go_package must name the directory
+			// buf writes the Go code to, which is also what the mappers import
+			goImport := project.GoImport(cfg.ProtoOutputDir)
+			if goImport == "" && project.BufGenConfig != "" && !project.BufSourceRelative && project.BufGoPackagePrefix == "" {
+				fmt.Printf("Note: %s doesn't use paths=source_relative for protoc-gen-go, so goPackage and protoGoImport can't be derived from it\n", project.BufGenConfig)
+			}
+			cfg.GoPackagePath = p.ask("Go package of the generated proto code (goPackage)", goImport, nil)
+			// Managed mode replaces go_package, so only its prefix decides the import path
+			protoGoImport := cfg.GoPackagePath
+			if project.BufGoPackagePrefix != "" {
+				protoGoImport = goImport
+			}
+			cfg.ProtoGoImport = p.ask("Import path of the generated proto code (protoGoImport)", protoGoImport, nil)
+
+			// Mappers import the sqlc package by its module path, and services
+			// need sqlc's Querier interface
+			cfg.GenerateMappers = p.confirm("Generate mappers between sqlc and proto types", project.ModuleName != "")
+			cfg.GenerateServices = p.confirm("Generate services from sqlc queries", project.EmitInterface)
+			if cfg.GenerateServices && project.SQLCConfig != "" && !project.EmitInterface {
+				fmt.Printf("Note: services need emit_interface: true in %s\n", project.SQLCConfig)
+			}
+			if cfg.GenerateServices {
+				cfg.ServiceStyle = p.ask("Service style, 'rpc' or 'aip'", cfg.ServiceStyle, func(value string) error {
+					return config.Config{ServiceStyle: value}.Validate()
+				})
+				cfg.ServiceOptions.HTTPAnnotations = p.confirm("Add google.api.http annotations for HTTP/JSON transcoding", false)
+			}
+
+			if err := cfg.Validate(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
 			}
 
 			// Write config file with comments
-			if err := common.WriteConfigWithComments(config, configFile); err != nil {
+			if err := config.WriteConfigWithComments(cfg, configFile); err != nil {
 				fmt.Printf("Failed to write config file: %v\n", err)
 				os.Exit(1)
 			}
-
 			fmt.Printf("Created config file %s\n", configFile)
+
+			if writeBufConfig {
+				// AIP resources and HTTP annotations import the google.api protos
+				googleapis := cfg.GenerateServices && (cfg.ServiceStyle == "aip" || cfg.ServiceOptions.HTTPAnnotations)
+				if err := common.WriteBufConfig("buf.yaml", googleapis); err != nil {
+					fmt.Printf("Failed to write buf.yaml: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Println("Created buf.yaml")
+			}
+			if writeBufGenConfig {
+				if err := common.WriteBufGenConfig("buf.gen.yaml"); err != nil {
+					fmt.Printf("Failed to write buf.gen.yaml: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Println("Created buf.gen.yaml")
+			}
+
 			fmt.Println("You can now edit this file to customize sqlc2proto behavior.")
 		},
 	}
 
 	initCmd.Flags().StringP("output", "o", "sqlc2proto.yaml", "Path to write the config file")
+	initCmd.Flags().BoolP("yes", "y", false, "Accept all proposed values without prompting")
+	initCmd.Flags().Bool("buf", false, "Create buf.yaml and buf.gen.yaml for Connect if they don't exist")
 
 	return initCmd
 }
+
+// printProject prints the project files that init found
+func printProject(project common.Project, verbose bool) {
+	if project.ModuleName != "" {
+		fmt.Printf("Found go.mod: module %s\n", project.ModuleName)
+	}
+	if project.SQLCConfig != "" {
+		fmt.Printf("Found %s: Go code in %s\n", project.SQLCConfig, strings.Join(project.SQLCDirs, ", "))
+		if len(project.SQLCDirs) > 1 {
+			fmt.Println("  Only the first package is configured, add targets for the others")
+		}
+	}
+	if project.BufConfig != "" {
+		fmt.Printf("Found %s: module root %s\n", project.BufConfig, project.BufRoot)
+	}
+	if project.BufGenConfig != "" {
+		fmt.Printf("Found %s\n", project.BufGenConfig)
+		if verbose {
+			fmt.Printf("  protoc-gen-go output: %s\n", project.BufGoOut)
+			fmt.Printf("  paths=source_relative: %t\n", project.BufSourceRelative)
+			if project.BufGoPackagePrefix != "" {
+				fmt.Printf("  go_package_prefix: %s\n", project.BufGoPackagePrefix)
+			}
+		}
+	}
+}
+
+// prompter asks for values on the terminal, proposing a default for each.
+// With yes set, or once the input is closed, the defaults are used.
+type prompter struct {
+	reader *bufio.Reader
+	yes    bool
+}
+
+// ask returns the answer to a question, or def if the answer is empty. An
+// answer rejected by validate is asked again.
+func (p *prompter) ask(question, def string, validate func(string) error) string {
+	for {
+		answer, ok := p.read(fmt.Sprintf("%s [%s]: ", question, def))
+		if !ok || answer == "" {
+			return def
+		}
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Printf("  %v\n", err)
+				continue
+			}
+		}
+		return answer
+	}
+}
+
+// confirm returns the answer to a yes/no question, or def if the answer is
+// empty
+func (p *prompter) confirm(question string, def bool) bool {
+	options := "y/N"
+	if def {
+		options = "Y/n"
+	}
+	for {
+		answer, ok := p.read(fmt.Sprintf("%s? (%s): ", question, options))
+		if !ok || answer == "" {
+			return def
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
+
+// read prints a prompt and reads a line, reporting false if the defaults
+// should be used instead
+func (p *prompter) read(prompt string) (string, bool) {
+	if p.yes {
+		return "", false
+	}
+	fmt.Print(prompt)
+	line, err := p.reader.ReadString('\n')
+	if err == io.EOF && line == "" {
+		// Use the defaults for the remaining questions
+		fmt.Println()
+		p.yes = true
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
@@ -2,9 +2,10 @@ package commands
 
 import (
 	"fmt"
+	"io"
 	"os"
 
-	common "github.com/boomskats/sqlc2proto/cmd/common"
+	"github.com/boomskats/sqlc2proto/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -21,7 +22,9 @@ Available Commands:
   init        Initialize a new sqlc2proto configuration file
   getincludes Generate a template file for selecting models and queries
   generate    Generate Protocol Buffers from sqlc structs
+  watch       Regenerate Protocol Buffers when the sqlc output changes
   check       Check the generated files for correctness
+  graph       Print the dependency graph of structs, queries, messages and services
   completion  Generate the autocompletion script for the specified shell
   {{- else}}
   {{- range .Commands}}{{if (or .IsAvailableCommand (eq .Name "help"))}}
@@ -31,13 +34,42 @@ Available Commands:
 
 {{end}}`
 
-var (
-	// Version will be set during build
-	Version = "dev"
+// Version will be set during build
+var Version = "dev"
+
+// loadTargets resolves the configuration of the selected targets from, in
+// increasing order of precedence, the defaults, the config file given with
+// --config or found in a default location, SQLC2PROTO_* environment
+// variables and the flags set on the command line. Progress messages are
+// printed to out.
+func loadTargets(cmd *cobra.Command, targetName string, out io.Writer) ([]config.Target, error) {
+	configFile, _ := cmd.Flags().GetString("config")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	if configFile == "" {
+		configFile = config.FindConfigFile()
+	}
 
-	// Config holds the global configuration
-	Config = common.DefaultConfig()
-)
+	layers := config.NewLayers()
+	if configFile != "" {
+		if verbose {
+			fmt.Fprintf(out, "Loading config from %s\n", configFile)
+		}
+		if err := layers.LoadFile(configFile); err != nil {
+			return nil, err
+		}
+	}
+	warnings, err := layers.LoadEnv(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(out, "Warning: %s\n", warning)
+	}
+	if err := layers.LoadFlags(cmd.Flags()); err != nil {
+		return nil, err
+	}
+	return layers.Targets(targetName)
+}
 
 // NewRootCmd creates the root command
 func NewRootCmd() *cobra.Command {
@@ -86,14 +118,18 @@ Simply type ` + rootCmd.Name() + ` help [path to command] for full details.`,
 	initCmd := NewInitCmd()
 	getIncludesCmd := NewGetIncludesCmd()
 	generateCmd := NewGenerateCmd()
+	watchCmd := NewWatchCmd()
 	checkCmd := NewCheckCmd()
+	graphCmd := NewGraphCmd()
 
 	// Add commands to root in the order we want them to appear
 	rootCmd.AddCommand(helpCmd)
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(getIncludesCmd)
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(graphCmd)
 
 	// Set custom help template
 	rootCmd.SetHelpTemplate(customHelpTemplate)
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/watch"
+	"github.com/boomskats/sqlc2proto/pkg/gen"
+	"github.com/spf13/cobra"
+)
+
+// NewWatchCmd creates the watch command
+func NewWatchCmd() *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Regenerate Protocol Buffers when the sqlc output changes",
+		Long: `Watches the sqlc directories, the configuration file and the includes files,
+and regenerates the output whenever they change.
+
+Changes are detected by polling, so no external tools are needed. Bursts of
+changes (e.g. from sqlc generate) are debounced into a single regeneration,
+and only files whose content changed are rewritten. Errors are reported
+without exiting, so the watcher keeps running while you fix them.
+
+Example:
+	 sqlc2proto watch --interval=500ms --debounce=300ms
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			configFile, _ := cmd.Flags().GetString("config")
+			verbose, _ := cmd.Flags().GetBool("verbose")
+			interval, _ := cmd.Flags().GetDuration("interval")
+			debounce, _ := cmd.Flags().GetDuration("debounce")
+			noCache, _ := cmd.Flags().GetBool("no-cache")
+			targetName, _ := cmd.Flags().GetString("target")
+
+			w := watch.Watcher{Interval: interval, Debounce: debounce}
+			if err := w.Validate(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Watch the config file given with --config or found in a default location
+			if configFile == "" {
+				configFile = config.FindConfigFile()
+			}
+
+			// The configuration is reloaded on every run. The watched paths
+			// follow the targets of the last configuration that loaded, so a
+			// watch started with a broken config file picks up the sqlc
+			// directories once the file is fixed.
+			var targets []config.Target
+			regenerate := func() (loaded bool) {
+				resolved, err := loadTargets(cmd, targetName, os.Stdout)
+				if err != nil {
+					logf("Error: %v", err)
+					return false
+				}
+				targets = resolved
+
+				files, err := renderTargets(targets, os.Stdout, verbose, !noCache)
+				if err != nil {
+					logf("Error: %v", err)
+					return true
+				}
+				result, err := gen.Write(files, gen.WriteOptions{Log: writerLog(os.Stdout, verbose)})
+				if err != nil {
+					logf("Error: %v", err)
+					return true
+				}
+				if result.Changed() == 0 {
+					logf("Generated files are up to date")
+				} else {
+					logf("Updated %d file(s)", result.Changed())
+				}
+				return true
+			}
+
+			// Without a config file to watch, a configuration that doesn't
+			// load can't be fixed while watching
+			if !regenerate() && configFile == "" {
+				os.Exit(1)
+			}
+
+			paths := func() []string {
+				var watched []string
+				if configFile != "" {
+					watched = append(watched, configFile)
+				}
+				for _, target := range targets {
+					watched = append(watched, target.SQLCDir)
+					if target.IncludeFile != "" {
+						watched = append(watched, target.IncludeFile)
+					}
+				}
+				return watched
+			}
+			if verbose {
+				fmt.Printf("Watching %v\n", paths())
+			}
+			logf("Watching for changes, press Ctrl+C to stop")
+
+			// Stop cleanly on Ctrl+C
+			stop := make(chan struct{})
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			go func() {
+				<-interrupt
+				close(stop)
+			}()
+
+			w.Run(paths, stop, func(changed []string) {
+				for _, path := range changed {
+					logf("Changed: %s", path)
+				}
+				if regenerate() && verbose {
+					fmt.Printf("Watching %v\n", paths())
+				}
+			})
+		},
+	}
+
+	watchCmd.Flags().String("target", "", "Only watch the named target from the targets list in the config file")
+	watchCmd.Flags().Duration("interval", 500*time.Millisecond, "How often to check for changes")
+	watchCmd.Flags().Duration("debounce", 300*time.Millisecond, "How long to wait for changes to settle before regenerating")
+	watchCmd.Flags().Bool("no-cache", false, "Parse all sqlc files instead of reusing cached results from "+cacheDir)
+
+	return watchCmd
+}
+
+// logf prints a timestamped message
+func logf(format string, args ...any) {
+	fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,402 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Project describes the Go, sqlc and buf setup found in a directory
+type Project struct {
+	// ModuleName is the module declared in go.mod, empty if there is none
+	ModuleName string
+
+	// SQLCConfig is the sqlc configuration file, empty if there is none
+	SQLCConfig string
+
+	// SQLCDirs are the output directories of the Go packages generated by sqlc
+	SQLCDirs []string
+
+	// SQLCSchemas are the schema files or directories of each Go package,
+	// by output directory
+	SQLCSchemas map[string][]string
+
+	// EmitInterface and EmitEmptySlices are the sqlc options of the first Go
+	// package
+	EmitInterface   bool
+	EmitEmptySlices bool
+
+	// BufConfig and BufGenConfig are the buf configuration files, empty if
+	// there are none
+	BufConfig    string
+	BufGenConfig string
+
+	// BufRoot is the directory of the buf module containing the proto files,
+	// relative to the project
+	BufRoot string
+
+	// BufGoOut is the output directory of the protoc-gen-go plugin, and
+	// BufSourceRelative whether it uses paths=source_relative
+	BufGoOut          string
+	BufSourceRelative bool
+
+	// BufGoPackagePrefix is the go_package prefix set by buf managed mode
+	BufGoPackagePrefix string
+}
+
+// The configuration file names that sqlc and buf look for
+var (
+	sqlcConfigNames   = []string{"sqlc.yaml", "sqlc.yml", "sqlc.json"}
+	bufConfigNames    = []string{"buf.yaml", "buf.yml"}
+	bufGenConfigNames = []string{"buf.gen.yaml", "buf.gen.yml"}
+)
+
+// DetectProject inspects go.mod, the sqlc configuration and the buf
+// configuration in dir. Missing files are not errors; files that can't be
+// parsed are.
+func DetectProject(dir string) (Project, error) {
+	project := Project{BufRoot: "."}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		project.ModuleName = moduleFromGoMod(data)
+	}
+
+	if name := findFile(dir, sqlcConfigNames); name != "" {
+		project.SQLCConfig = name
+		if err := project.readSQLCConfig(filepath.Join(dir, name)); err != nil {
+			return project, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if name := findFile(dir, bufConfigNames); name != "" {
+		project.BufConfig = name
+		if err := project.readBufConfig(filepath.Join(dir, name)); err != nil {
+			return project, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if name := findFile(dir, bufGenConfigNames); name != "" {
+		project.BufGenConfig = name
+		if err := project.readBufGenConfig(filepath.Join(dir, name)); err != nil {
+			return project, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return project, nil
+}
+
+// findFile returns the first of names that exists in dir
+func findFile(dir string, names []string) string {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// moduleFromGoMod returns the module path declared in a go.mod file
+func moduleFromGoMod(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(name), `"`)
+		}
+	}
+	return ""
+}
+
+// sqlcGoOptions are the sqlc options of a Go package that matter to sqlc2proto
+type sqlcGoOptions struct {
+	Out             string     `yaml:"out"`
+	Path            string     `yaml:"path"`   // Version 1
+	Schema          stringList `yaml:"schema"` // Version 1
+	EmitInterface   bool       `yaml:"emit_interface"`
+	EmitEmptySlices bool       `yaml:"emit_empty_slices"`
+}
+
+// stringList is a sqlc option given as a single string or a list of them
+type stringList []string
+
+// UnmarshalYAML accepts a scalar or a sequence of scalars
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*l = values
+	return nil
+}
+
+// readSQLCConfig reads the Go packages of a sqlc configuration, in the
+// version 1 or version 2 format
+func (p *Project) readSQLCConfig(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var config struct {
+		Packages []sqlcGoOptions `yaml:"packages"`
+		SQL      []struct {
+			Schema stringList `yaml:"schema"`
+			Gen    struct {
+				Go *sqlcGoOptions `yaml:"go"`
+			} `yaml:"gen"`
+		} `yaml:"sql"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	packages := config.Packages
+	for _, sql := range config.SQL {
+		if sql.Gen.Go != nil {
+			pkg := *sql.Gen.Go
+			pkg.Schema = sql.Schema
+			packages = append(packages, pkg)
+		}
+	}
+	p.SQLCSchemas = make(map[string][]string)
+	for i, pkg := range packages {
+		dir := pkg.Out
+		if dir == "" {
+			dir = pkg.Path
+		}
+		if dir == "" {
+			continue
+		}
+		p.SQLCDirs = append(p.SQLCDirs, relativePath(dir))
+		for _, schema := range pkg.Schema {
+			p.SQLCSchemas[relativePath(dir)] = append(p.SQLCSchemas[relativePath(dir)], relativePath(schema))
+		}
+		if i == 0 {
+			p.EmitInterface = pkg.EmitInterface
+			p.EmitEmptySlices = pkg.EmitEmptySlices
+		}
+	}
+	return nil
+}
+
+// readBufConfig reads the module root of a buf.yaml. Version 1 modules are
+// rooted at the directory of the file, version 2 workspaces list their
+// module paths.
+func (p *Project) readBufConfig(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var config struct {
+		Modules []struct {
+			Path string `yaml:"path"`
+		} `yaml:"modules"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	if len(config.Modules) > 0 && config.Modules[0].Path != "" {
+		p.BufRoot = path.Clean(config.Modules[0].Path)
+	}
+	return nil
+}
+
+// readBufGenConfig reads the protoc-gen-go plugin and the managed mode
+// go_package prefix of a buf.gen.yaml, in the version 1 or version 2 format
+func (p *Project) readBufGenConfig(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var config struct {
+		Managed struct {
+			GoPackagePrefix yaml.Node `yaml:"go_package_prefix"` // Version 1
+			Override        []struct {
+				FileOption string `yaml:"file_option"`
+				Module     string `yaml:"module"`
+				Path       string `yaml:"path"`
+				Value      string `yaml:"value"`
+			} `yaml:"override"` // Version 2
+		} `yaml:"managed"`
+		Plugins []struct {
+			Plugin string    `yaml:"plugin"`
+			Name   string    `yaml:"name"`
+			Remote string    `yaml:"remote"`
+			Local  yaml.Node `yaml:"local"`
+			Out    string    `yaml:"out"`
+			Opt    yaml.Node `yaml:"opt"`
+		} `yaml:"plugins"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	// go_package_prefix is a string, or a map with a default in version 1
+	prefix := config.Managed.GoPackagePrefix
+	switch prefix.Kind {
+	case yaml.ScalarNode:
+		p.BufGoPackagePrefix = prefix.Value
+	case yaml.MappingNode:
+		var value struct {
+			Default string `yaml:"default"`
+		}
+		if err := prefix.Decode(&value); err == nil {
+			p.BufGoPackagePrefix = value.Default
+		}
+	}
+	// Later overrides take precedence, and those limited to a module or a
+	// path only if there is none for all files
+	scoped := ""
+	for _, override := range config.Managed.Override {
+		if override.FileOption != "go_package_prefix" {
+			continue
+		}
+		if override.Module != "" || override.Path != "" {
+			scoped = override.Value
+			continue
+		}
+		p.BufGoPackagePrefix = override.Value
+	}
+	if p.BufGoPackagePrefix == "" {
+		p.BufGoPackagePrefix = scoped
+	}
+
+	for _, plugin := range config.Plugins {
+		name := plugin.Plugin + plugin.Name + plugin.Remote + plugin.Local.Value
+		if !isGoPlugin(name) {
+			continue
+		}
+		p.BufGoOut = relativePath(plugin.Out)
+		var opts []string
+		switch plugin.Opt.Kind {
+		case yaml.ScalarNode:
+			opts = strings.Split(plugin.Opt.Value, ",")
+		case yaml.SequenceNode:
+			_ = plugin.Opt.Decode(&opts)
+		}
+		for _, opt := range opts {
+			if strings.TrimSpace(opt) == "paths=source_relative" {
+				p.BufSourceRelative = true
+			}
+		}
+		break
+	}
+	return nil
+}
+
+// goPluginPattern matches the names of the protoc-gen-go plugin, e.g. "go",
+// "protoc-gen-go" or "buf.build/protocolbuffers/go:v1.34.2"
+var goPluginPattern = regexp.MustCompile(`^(?:.*/)?(?:protoc-gen-)?go(?::.*)?$`)
+
+// nonIdentPattern matches the characters that can't appear in a proto
+// package name
+var nonIdentPattern = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// isGoPlugin reports whether a buf plugin name refers to protoc-gen-go
+func isGoPlugin(name string) bool {
+	return goPluginPattern.MatchString(name) && !strings.Contains(name, "/grpc/") && !strings.Contains(name, "/connectrpc/")
+}
+
+// relativePath returns a slash-separated path relative to the project, e.g.
+// "./db/sqlc" for "db/sqlc"
+func relativePath(p string) string {
+	p = path.Clean(filepath.ToSlash(p))
+	if p == "." || path.IsAbs(p) || strings.HasPrefix(p, "../") {
+		return p
+	}
+	return "./" + p
+}
+
+// SchemaPaths returns the schema files or directories of the sqlc package
+// generated to dir
+func (p Project) SchemaPaths(dir string) []string {
+	return p.SQLCSchemas[relativePath(dir)]
+}
+
+// ProtoPackage proposes a proto package name from the last element of the
+// module path, e.g. "library.v1" for "example.com/library"
+func (p Project) ProtoPackage() string {
+	name := strings.ToLower(path.Base(p.ModuleName))
+	name = nonIdentPattern.ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if p.ModuleName == "" || name == "" || (config.Config{ProtoPackageName: name}).Validate() != nil {
+		return "api.v1"
+	}
+	return name + ".v1"
+}
+
+// GoImport returns the Go import path of the code that buf generates from the
+// proto files in protoDir, or "" if it can't be determined. With managed
+// mode, the import path is the go_package prefix followed by the directory
+// of the files in the buf module. With paths=source_relative, the code is
+// written to the same directory below the plugin's output directory, which
+// the go_package option written by sqlc2proto must match.
+func (p Project) GoImport(protoDir string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(p.BufRoot), filepath.FromSlash(protoDir))
+	if err != nil || strings.HasPrefix(filepath.ToSlash(rel), "../") {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+
+	if p.BufGoPackagePrefix != "" {
+		return path.Join(p.BufGoPackagePrefix, rel)
+	}
+	if p.ModuleName == "" {
+		return ""
+	}
+	out := "."
+	if p.BufGenConfig != "" {
+		if !p.BufSourceRelative {
+			return ""
+		}
+		out = p.BufGoOut
+	}
+	return path.Join(p.ModuleName, out, rel)
+}
+
+// WriteBufConfig writes a buf.yaml for a module rooted at its directory,
+// depending on googleapis for the google.api.http annotations if needed
+func WriteBufConfig(path string, googleapis bool) error {
+	content := `version: v1
+`
+	if googleapis {
+		content += `deps:
+  - buf.build/googleapis/googleapis
+`
+	}
+	content += `lint:
+  use:
+    - DEFAULT
+  except:
+    - PACKAGE_VERSION_SUFFIX
+    - SERVICE_SUFFIX
+breaking:
+  use:
+    - FILE
+`
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// WriteBufGenConfig writes a buf.gen.yaml that generates Go code and Connect
+// handlers next to the proto files
+func WriteBufGenConfig(path string) error {
+	content := `version: v1
+plugins:
+  - plugin: go
+    out: .
+    opt:
+      - paths=source_relative
+  - plugin: connect-go
+    out: .
+    opt:
+      - paths=source_relative
+`
+	return os.WriteFile(path, []byte(content), 0o644)
+}
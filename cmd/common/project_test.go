@@ -0,0 +1,252 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setupProject copies a project from testdata to a temporary directory.
+// The go.mod files of the projects are stored as go.mod.txt, as a go.mod
+// would make the project a module of its own.
+func setupProject(t *testing.T, name string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS(filepath.Join("testdata", name))); err != nil {
+		t.Fatal(err)
+	}
+	goMod := filepath.Join(dir, "go.mod.txt")
+	if _, err := os.Stat(goMod); err == nil {
+		if err := os.Rename(goMod, filepath.Join(dir, "go.mod")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDetectProject(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     Project
+		protoDir string
+		goImport string
+	}{
+		{
+			name: "sqlc-v1",
+			want: Project{
+				ModuleName:    "example.com/library",
+				SQLCConfig:    "sqlc.json",
+				SQLCDirs:      []string{"./internal/db"},
+				SQLCSchemas:   map[string][]string{"./internal/db": {"./schema.sql"}},
+				EmitInterface: true,
+				BufRoot:       ".",
+			},
+			// Without buf.gen.yaml, the code is assumed to be next to the proto files
+			protoDir: "./proto/gen",
+			goImport: "example.com/library/proto/gen",
+		},
+		{
+			name: "sqlc-v2",
+			want: Project{
+				ModuleName: "example.com/shop",
+				SQLCConfig: "sqlc.yml",
+				SQLCDirs:   []string{"./db/sqlc", "./billing/sqlc"},
+				SQLCSchemas: map[string][]string{
+					"./db/sqlc":      {"./db/migrations", "./db/views.sql"},
+					"./billing/sqlc": {"./billing/schema.sql"},
+				},
+				// The options of the first package only
+				EmitEmptySlices: true,
+				BufRoot:         ".",
+			},
+			protoDir: "./proto/shop/v1",
+			goImport: "example.com/shop/proto/shop/v1",
+		},
+		{
+			name: "buf-v1",
+			want: Project{
+				ModuleName:        "example.com/library",
+				BufConfig:         "buf.yaml",
+				BufGenConfig:      "buf.gen.yaml",
+				BufRoot:           ".",
+				BufGoOut:          "./gen",
+				BufSourceRelative: true,
+			},
+			protoDir: "./proto/library/v1",
+			goImport: "example.com/library/gen/proto/library/v1",
+		},
+		{
+			name: "buf-v1-managed",
+			want: Project{
+				ModuleName:         "example.com/library",
+				BufGenConfig:       "buf.gen.yaml",
+				BufRoot:            ".",
+				BufGoOut:           "./gen",
+				BufGoPackagePrefix: "example.com/library/gen",
+			},
+			protoDir: "./library/v1",
+			goImport: "example.com/library/gen/library/v1",
+		},
+		{
+			name: "buf-v2",
+			want: Project{
+				ModuleName:         "example.com/library",
+				BufConfig:          "buf.yaml",
+				BufGenConfig:       "buf.gen.yaml",
+				BufRoot:            "proto",
+				BufGoOut:           "./internal/gen",
+				BufSourceRelative:  true,
+				BufGoPackagePrefix: "example.com/library/internal/gen",
+			},
+			protoDir: "./proto/library/v1",
+			goImport: "example.com/library/internal/gen/library/v1",
+		},
+		{
+			name: "buf-v2-local",
+			want: Project{
+				ModuleName:        "example.com/library",
+				BufConfig:         "buf.yaml",
+				BufGenConfig:      "buf.gen.yaml",
+				BufRoot:           "api",
+				BufGoOut:          "./gen/go",
+				BufSourceRelative: true,
+			},
+			protoDir: "./api/library/v1",
+			goImport: "example.com/library/gen/go/library/v1",
+		},
+		{
+			name: "buf-not-source-relative",
+			want: Project{
+				ModuleName:   "example.com/library",
+				BufGenConfig: "buf.gen.yml",
+				BufRoot:      ".",
+				BufGoOut:     "./gen",
+			},
+			// The import path depends on the go_package option
+			protoDir: "./proto/library/v1",
+			goImport: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, err := DetectProject(setupProject(t, tt.name))
+			if err != nil {
+				t.Fatalf("DetectProject failed: %v", err)
+			}
+			if !reflect.DeepEqual(project, tt.want) {
+				t.Errorf("DetectProject = %+v, want %+v", project, tt.want)
+			}
+			if got := project.GoImport(tt.protoDir); got != tt.goImport {
+				t.Errorf("GoImport(%q) = %q, want %q", tt.protoDir, got, tt.goImport)
+			}
+		})
+	}
+}
+
+func TestDetectProjectEmpty(t *testing.T) {
+	project, err := DetectProject(t.TempDir())
+	if err != nil {
+		t.Fatalf("DetectProject failed: %v", err)
+	}
+	if want := (Project{BufRoot: "."}); !reflect.DeepEqual(project, want) {
+		t.Errorf("DetectProject = %+v, want %+v", project, want)
+	}
+	if got := project.ProtoPackage(); got != "api.v1" {
+		t.Errorf("ProtoPackage = %q, want api.v1", got)
+	}
+}
+
+func TestDetectProjectInvalid(t *testing.T) {
+	_, err := DetectProject(setupProject(t, "invalid"))
+	if err == nil || !strings.HasPrefix(err.Error(), "sqlc.yaml: ") {
+		t.Errorf("DetectProject error = %v, want an error for sqlc.yaml", err)
+	}
+}
+
+func TestIsGoPlugin(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"go", true},
+		{"protoc-gen-go", true},
+		{"/usr/local/bin/protoc-gen-go", true},
+		{"buf.build/protocolbuffers/go", true},
+		{"buf.build/protocolbuffers/go:v1.34.2", true},
+		{"buf.build/grpc/go", false},
+		{"buf.build/grpc/go:v1.5.1", false},
+		{"buf.build/connectrpc/go", false},
+		{"connect-go", false},
+		{"protoc-gen-connect-go", false},
+		{"go-grpc", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isGoPlugin(tt.name); got != tt.want {
+			t.Errorf("isGoPlugin(%q) = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGoImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		project  Project
+		protoDir string
+		want     string
+	}{
+		{
+			name:     "no buf.gen.yaml",
+			project:  Project{ModuleName: "example.com/library", BufRoot: "."},
+			protoDir: "./proto/gen",
+			want:     "example.com/library/proto/gen",
+		},
+		{
+			name:     "no module",
+			project:  Project{BufRoot: "."},
+			protoDir: "./proto/gen",
+			want:     "",
+		},
+		{
+			name:     "source relative",
+			project:  Project{ModuleName: "example.com/library", BufGenConfig: "buf.gen.yaml", BufRoot: "proto", BufGoOut: "./gen", BufSourceRelative: true},
+			protoDir: "proto/library/v1/",
+			want:     "example.com/library/gen/library/v1",
+		},
+		{
+			name:     "source relative at the module root",
+			project:  Project{ModuleName: "example.com/library", BufGenConfig: "buf.gen.yaml", BufRoot: ".", BufGoOut: ".", BufSourceRelative: true},
+			protoDir: ".",
+			want:     "example.com/library",
+		},
+		{
+			name:     "not source relative",
+			project:  Project{ModuleName: "example.com/library", BufGenConfig: "buf.gen.yaml", BufRoot: ".", BufGoOut: "./gen"},
+			protoDir: "./proto",
+			want:     "",
+		},
+		{
+			name:     "managed mode without a module",
+			project:  Project{BufGenConfig: "buf.gen.yaml", BufRoot: "proto", BufGoPackagePrefix: "example.com/apis/gen"},
+			protoDir: "./proto/library/v1",
+			want:     "example.com/apis/gen/library/v1",
+		},
+		{
+			name:     "outside the buf module",
+			project:  Project{ModuleName: "example.com/library", BufGenConfig: "buf.gen.yaml", BufRoot: "proto", BufGoOut: ".", BufSourceRelative: true},
+			protoDir: "./api/library/v1",
+			want:     "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.project.GoImport(tt.protoDir); got != tt.want {
+				t.Errorf("GoImport(%q) = %q, want %q", tt.protoDir, got, tt.want)
+			}
+		})
+	}
+}
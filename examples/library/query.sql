@@ -25,6 +25,16 @@ INSERT INTO books (
 )
 RETURNING *;
 
+-- name: UpdateBook :one
+UPDATE books
+SET title = $2, author = $3, genre = $4, summary = $5, in_stock = $6, page_count = $7
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteBook :exec
+DELETE FROM books
+WHERE id = $1;
+
 -- name: GetMember :one
 SELECT * FROM members
 WHERE id = $1;
@@ -0,0 +1,120 @@
+// Package cache stores results between runs in a directory, keyed by a hash
+// of the inputs that produced them.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a directory of JSON entries. Every key is mixed with a salt
+// identifying the tool version and configuration, so changing either
+// invalidates all entries. It is safe for concurrent use.
+type Cache struct {
+	dir  string
+	salt string
+
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+// Open returns a cache stored in dir, creating the directory if needed
+func Open(dir, salt string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, salt: salt}, nil
+}
+
+// Hash returns the hex-encoded SHA-256 hash of data, for use in keys
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get loads the entry for key into v, reporting whether it was found
+func (c *Cache) Get(key string, v any) bool {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.misses++
+		return false
+	}
+	c.hits++
+
+	// Mark the entry as used, so Prune keeps it
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return true
+}
+
+// Put stores v as the entry for key. Failures are ignored, since the cache
+// only saves work.
+func (c *Cache) Put(key string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	// Write to a temporary file first, so readers never see partial entries
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// Stats returns the number of cache hits and misses so far
+func (c *Cache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Prune removes entries that haven't been used for longer than maxAge
+func (c *Cache) Prune(maxAge time.Duration) error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(filepath.Join(c.dir, entry.Name()))
+		}
+	}
+	return nil
+}
+
+// path returns the file storing the entry for key
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, Hash([]byte(c.salt+"\x00"+key))+".json")
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type entry struct {
+	Name   string
+	Fields []string
+}
+
+func TestCacheGetPut(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := Open(dir, "v1")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	var got entry
+	if c.Get("models", &got) {
+		t.Fatal("Expected a miss for a missing entry")
+	}
+
+	want := entry{Name: "Book", Fields: []string{"id", "title"}}
+	c.Put("models", want)
+	if !c.Get("models", &got) {
+		t.Fatal("Expected a hit after Put")
+	}
+	if got.Name != want.Name || len(got.Fields) != 2 {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if hits, misses := c.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %d and %d", hits, misses)
+	}
+
+	// A different salt doesn't see the entry
+	other, err := Open(dir, "v2")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if other.Get("models", &got) {
+		t.Error("Expected a miss with a different salt")
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir, "v1")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	c.Put("old", entry{Name: "old"})
+	c.Put("new", entry{Name: "new"})
+
+	// Age the old entry
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(c.path("old"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Prune(24 * time.Hour); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	var got entry
+	if c.Get("old", &got) {
+		t.Error("Expected the old entry to be pruned")
+	}
+	if !c.Get("new", &got) {
+		t.Error("Expected the new entry to be kept")
+	}
+}
@@ -0,0 +1,318 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DefaultConfigPaths contains the default paths to look for configuration files
+var DefaultConfigPaths = []string{
+	"sqlc2proto.yaml",
+	"sqlc2proto.yml",
+	".sqlc2proto.yaml",
+	".sqlc2proto.yml",
+}
+
+// SchemaURL is where the JSON Schema of the config file is published
+const SchemaURL = "https://raw.githubusercontent.com/boomskats/sqlc2proto/main/sqlc2proto.schema.json"
+
+// LoadConfigFile loads configuration from a YAML file. The options the
+// file sets, including false booleans, override those in cfg. Progress
+// messages are printed to w.
+func LoadConfigFile(w io.Writer, path string, cfg *Config) error {
+	fmt.Fprintf(w, "Loading config from %s\n", path)
+
+	layers := NewLayers()
+	if err := layers.LoadFile(path); err != nil {
+		return err
+	}
+	layers.file.apply(cfg, Sources{})
+	if len(layers.targets) > 0 {
+		cfg.Targets = layers.targets
+	}
+	return nil
+}
+
+// FindConfigFile returns the first default config path that exists, or ""
+// if there is none
+func FindConfigFile() string {
+	for _, path := range DefaultConfigPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// mergeMappings returns a copy of base with the given mappings added
+func mergeMappings(base, mappings map[string]string) map[string]string {
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]string, len(mappings))
+	}
+	maps.Copy(merged, mappings)
+	return merged
+}
+
+// InferGoPackage creates a reasonable default Go package path
+func InferGoPackage(protoPackage string, moduleName string) string {
+	// If moduleName is provided, use it as the base
+	if moduleName != "" {
+		return filepath.Join(moduleName, "proto")
+	}
+
+	// Fallback to a default pattern
+	return fmt.Sprintf("github.com/yourusername/yourproject/gen/%s", protoPackage)
+}
+
+// ResourceDomain returns the service name used in AIP resource types,
+// falling back to the proto package name
+func ResourceDomain(cfg Config) string {
+	if cfg.ServiceOptions.ResourceDomain != "" {
+		return cfg.ServiceOptions.ResourceDomain
+	}
+	return cfg.ProtoPackageName
+}
+
+// GetModuleNameFromGoMod reads the first line of the go.mod file and extracts the module name
+func GetModuleNameFromGoMod() (string, error) {
+	// Check if go.mod exists
+	if _, err := os.Stat("go.mod"); os.IsNotExist(err) {
+		return "", fmt.Errorf("go.mod file not found")
+	}
+
+	// Open the go.mod file
+	file, err := os.Open("go.mod")
+	if err != nil {
+		return "", fmt.Errorf("failed to open go.mod file: %w", err)
+	}
+	defer file.Close()
+
+	// Read the first line
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return "", fmt.Errorf("go.mod file is empty")
+	}
+
+	// Parse the module name
+	line := scanner.Text()
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "module ") {
+		return "", fmt.Errorf("module declaration not found in go.mod")
+	}
+
+	moduleName := strings.TrimSpace(strings.TrimPrefix(line, "module "))
+	if moduleName == "" {
+		return "", fmt.Errorf("empty module name in go.mod")
+	}
+
+	return moduleName, nil
+}
+
+// PrintConfig prints the current configuration to w. With sources, options that
+// don't have their default value are annotated with where they came from,
+// and other options set outside the defaults are listed as well.
+func PrintConfig(w io.Writer, cfg Config, sources Sources) {
+	defaults := DefaultConfig()
+	printed := make(map[string]bool)
+	from := func(key string) string {
+		printed[key] = true
+		source, ok := sources[key]
+		if !ok {
+			return ""
+		}
+		if source == "default" {
+			if reflect.DeepEqual(optionField(&cfg, key).Interface(), optionField(&defaults, key).Interface()) {
+				return ""
+			}
+			// Filled in from other options, e.g. the Go package
+			source = "derived"
+		}
+		return "  (" + source + ")"
+	}
+
+	fmt.Fprintln(w, "Using configuration:")
+	fmt.Fprintf(w, "  SQLC Directory:    %s%s\n", cfg.SQLCDir, from("sqlcDir"))
+	fmt.Fprintf(w, "  Proto Directory:   %s%s\n", cfg.ProtoOutputDir, from("protoDir"))
+	fmt.Fprintf(w, "  Proto Package:     %s%s\n", cfg.ProtoPackageName, from("protoPackage"))
+	fmt.Fprintf(w, "  Proto Go Import:   %s%s\n", cfg.ProtoGoImport, from("protoGoImport"))
+	fmt.Fprintf(w, "  Go Package:        %s%s\n", cfg.GoPackagePath, from("goPackage"))
+	fmt.Fprintf(w, "  Module Name:       %s%s\n", cfg.ModuleName, from("moduleName"))
+	fmt.Fprintf(w, "  Generate Mappers:  %t%s\n", cfg.GenerateMappers, from("withMappers"))
+	if cfg.GenerateMappers {
+		fmt.Fprintf(w, "  Strict Mappers:    %t%s\n", cfg.MapperOptions.Strict, from("mapperOptions.strict"))
+		fmt.Fprintf(w, "  Empty Slices:      %t%s\n", cfg.MapperOptions.EmitEmptySlices, from("mapperOptions.emitEmptySlices"))
+		fmt.Fprintf(w, "  Mapper Tests:      %t%s\n", cfg.MapperOptions.GenerateTests, from("mapperOptions.generateTests"))
+	}
+	fmt.Fprintf(w, "  Generate Services: %t%s\n", cfg.GenerateServices, from("withServices"))
+	if cfg.GenerateServices {
+		fmt.Fprintf(w, "  Service Naming:    %s%s\n", cfg.ServiceNaming, from("serviceNaming"))
+		if cfg.ServicePrefix != "" {
+			fmt.Fprintf(w, "  Service Prefix:    %s%s\n", cfg.ServicePrefix, from("servicePrefix"))
+		}
+		fmt.Fprintf(w, "  Service Suffix:    %s%s\n", cfg.ServiceSuffix, from("serviceSuffix"))
+		fmt.Fprintf(w, "  Service Style:     %s%s\n", cfg.ServiceStyle, from("serviceStyle"))
+		if cfg.ServiceStyle == "aip" {
+			fmt.Fprintf(w, "  Resource Domain:   %s%s\n", ResourceDomain(cfg), from("serviceOptions.resourceDomain"))
+		}
+		fmt.Fprintf(w, "  Split Services:    %t%s\n", cfg.ServiceOptions.SplitServices, from("serviceOptions.splitServices"))
+		fmt.Fprintf(w, "  HTTP Annotations:  %t%s\n", cfg.ServiceOptions.HTTPAnnotations, from("serviceOptions.httpAnnotations"))
+		fmt.Fprintf(w, "  Idempotent Reads:  %t%s\n", cfg.ServiceOptions.IdempotentReads, from("serviceOptions.idempotentReads"))
+		// Note: Generate Impl has been removed as Connect-RPC tooling
+		// will generate the service implementation code from the proto definitions.
+	}
+	fmt.Fprintf(w, "  Field Style:       %s%s\n", cfg.FieldStyle, from("fieldStyle"))
+	fmt.Fprintf(w, "  Layout:            %s%s\n", cfg.Layout, from("layout"))
+	if cfg.IncludeFile != "" {
+		fmt.Fprintf(w, "  Include File:      %s%s\n", cfg.IncludeFile, from("includeFile"))
+	}
+
+	for _, key := range optionKeys() {
+		if source, ok := sources[key]; ok && source != "default" && !printed[key] {
+			fmt.Fprintf(w, "  %s: %v  (%s)\n", key, optionField(&cfg, key).Interface(), source)
+		}
+	}
+}
+
+// WriteConfigWithComments writes the configuration to a YAML file with comments
+func WriteConfigWithComments(config Config, path string) error {
+	// Create the content with comments
+	content := `# yaml-language-server: $schema=` + SchemaURL + `
+
+# sqlcDir is the directory containing sqlc-generated models.go
+sqlcDir: "` + config.SQLCDir + `"
+# protoDir is the target directory for the generated protobuf files
+protoDir: "` + config.ProtoOutputDir + `"
+# protoPackage is the package name for the generated protobuf files
+protoPackage: "` + config.ProtoPackageName + `"
+# goPackage is optional and will be derived from moduleName if not specified
+`
+	if config.GoPackagePath != "" {
+		content += `goPackage: "` + config.GoPackagePath + `"
+`
+	} else {
+		content += `# goPackage: "github.com/yourusername/yourproject/proto"
+`
+	}
+
+	content += `withMappers: ` + fmt.Sprintf("%t", config.GenerateMappers) + `
+# mapperOptions.strict makes FromProto mappers return an error for invalid input
+# (e.g. a malformed UUID) instead of silently using a zero value
+mapperOptions:
+  strict: ` + fmt.Sprintf("%t", config.MapperOptions.Strict) + `
+  # emitEmptySlices should match sqlc's emit_empty_slices option
+  emitEmptySlices: ` + fmt.Sprintf("%t", config.MapperOptions.EmitEmptySlices) + `
+  # generateTests emits mappers_test.go with round-trip tests for the mappers
+  generateTests: ` + fmt.Sprintf("%t", config.MapperOptions.GenerateTests) + `
+
+# Service generation options
+# withServices enables generation of service definitions from sqlc queries
+withServices: ` + fmt.Sprintf("%t", config.GenerateServices) + `
+# serviceNaming controls how services are named and organized
+# Options: "entity" (group by entity), "flat" (one service), or "custom"
+serviceNaming: "` + config.ServiceNaming + `"
+# servicePrefix is an optional prefix for service names
+` + (func() string {
+		if config.ServicePrefix != "" {
+			return `servicePrefix: "` + config.ServicePrefix + `"`
+		}
+		return `# servicePrefix: "API"`
+	})() + `
+# serviceSuffix is a suffix for service names (default: "Service")
+serviceSuffix: "` + config.ServiceSuffix + `"
+# serviceStyle controls the shape of the generated services
+# Options: "rpc" (one request/response pair per query) or "aip" (Google AIP standard methods)
+serviceStyle: "` + config.ServiceStyle + `"
+serviceOptions:
+  # httpAnnotations adds google.api.http annotations for HTTP/JSON transcoding
+  httpAnnotations: ` + fmt.Sprintf("%t", config.ServiceOptions.HTTPAnnotations) + `
+# Note: Service implementation generation has been removed as Connect-RPC tooling
+# will generate the service implementation code from the proto definitions.
+# moduleName is used to derive import paths for the generated code
+`
+	if config.ModuleName != "" {
+		content += `moduleName: "` + config.ModuleName + `"
+`
+	} else {
+		content += `# moduleName: "github.com/yourusername/yourproject"
+`
+	}
+
+	content += `# protoGoImport specifies the import path for the protobuf-generated Go code
+# This should match the go_package option in your buf.yaml or the output of buf generate
+`
+	if config.ProtoGoImport != "" {
+		content += `protoGoImport: "` + config.ProtoGoImport + `"
+`
+	} else {
+		content += `# protoGoImport: "github.com/yourusername/yourproject/proto"
+`
+	}
+
+	content += `# fieldStyle controls how field names are generated in protobuf
+# Options: "json" (use json tags), "snake_case" (convert to snake_case), or "original" (keep original casing)
+fieldStyle: "` + config.FieldStyle + `"
+# layout controls how the generated files are organised
+# Options: "single" (models.proto, service.proto and mappers.go) or "split" (one file per entity)
+layout: "` + config.Layout + `"
+
+# includeFile specifies the path to a file that lists which models and queries to include
+# If not specified or the file doesn't exist, all models and queries will be included
+` + (func() string {
+		if config.IncludeFile != "" {
+			return `includeFile: "` + config.IncludeFile + `"`
+		}
+		return `# includeFile: "sqlc2proto.includes.yaml"`
+	})() + `
+
+# typeMappings is a map of SQLC type names to protobuf type names
+typeMappings:
+`
+	if len(config.TypeMappings) > 0 {
+		for _, k := range sortedKeys(config.TypeMappings) {
+			content += `  "` + k + `": "` + config.TypeMappings[k] + `"
+`
+		}
+	} else {
+		content += `#  "CustomType": "string"
+#  "time.Time": "string"  # Override default
+#  "uuid.UUID": "bytes"   # Use bytes instead of string for UUIDs
+`
+	}
+
+	content += `
+# nullableTypeMappings is a map of SQLC nullable type names to protobuf type names
+nullableTypeMappings:
+`
+	if len(config.NullableTypeMappings) > 0 {
+		for _, k := range sortedKeys(config.NullableTypeMappings) {
+			content += `  "` + k + `": "` + config.NullableTypeMappings[k] + `"
+`
+		}
+	} else {
+		content += `#  "sql.NullString": "google.protobuf.StringValue"  # Use wrapper types
+#  "sql.NullInt64": "int64"
+#  "uuid.NullUUID": "string"
+`
+	}
+
+	// Write the content to the file
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,339 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables that set options,
+// e.g. SQLC2PROTO_WITH_MAPPERS or SQLC2PROTO_SERVICE_OPTIONS_HTTP_ANNOTATIONS
+const EnvPrefix = "SQLC2PROTO_"
+
+// FlagKeys maps the command-line flags that set options to their keys
+var FlagKeys = map[string]string{
+	"sqlc-dir":          "sqlcDir",
+	"proto-dir":         "protoDir",
+	"package":           "protoPackage",
+	"go-package":        "goPackage",
+	"module":            "moduleName",
+	"proto-go-import":   "protoGoImport",
+	"with-mappers":      "withMappers",
+	"strict-mappers":    "mapperOptions.strict",
+	"with-mapper-tests": "mapperOptions.generateTests",
+	"with-services":     "withServices",
+	"service-style":     "serviceStyle",
+	"layout":            "layout",
+	"field-style":       "fieldStyle",
+	"include-file":      "includeFile",
+}
+
+// Sources maps option keys (e.g. "serviceOptions.httpAnnotations") to where
+// their values came from: "default", a file and line such as
+// "sqlc2proto.yaml:4", an environment variable such as
+// "$SQLC2PROTO_LAYOUT", or a flag such as "--layout"
+type Sources map[string]string
+
+// layer is a set of options from one source
+type layer struct {
+	config  Config
+	sources Sources // The keys set by this layer
+}
+
+// apply copies the options set by the layer to cfg and records their sources
+func (l layer) apply(cfg *Config, sources Sources) {
+	for key, source := range l.sources {
+		copyOption(cfg, &l.config, key)
+		sources[key] = source
+	}
+}
+
+// Layers builds a configuration from, in increasing order of precedence,
+// the defaults, a config file, environment variables and command-line flags.
+// Within the config file, the options of a target override the top-level
+// ones. Only options that a layer explicitly sets override lower layers, so
+// e.g. --with-mappers=false disables mappers enabled in the config file.
+type Layers struct {
+	file    layer
+	targets []Target
+	target  []layer // The options set by each target in the config file
+	env     layer
+	flags   layer
+}
+
+// NewLayers returns layers that only contain the defaults
+func NewLayers() *Layers {
+	return &Layers{}
+}
+
+// LoadFile adds a config file, including the settings of all its targets
+func (l *Layers) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	config, doc, err := decodeConfig(path, data)
+	if err != nil {
+		return err
+	}
+
+	l.file = layer{config: config, sources: fileSources(path, doc, reflect.TypeOf(config), nil)}
+	l.targets = config.Targets
+	l.target = make([]layer, len(config.Targets))
+	if targets := lookupNode(doc, []string{"targets"}); targets != nil {
+		for i, target := range config.Targets {
+			if i < len(targets.Content) {
+				l.target[i] = layer{config: target.Config, sources: fileSources(path, targets.Content[i], reflect.TypeOf(config), nil)}
+			}
+		}
+	}
+	return nil
+}
+
+// LoadEnv adds the SQLC2PROTO_* variables of an environment in the format
+// of os.Environ. Booleans are parsed with strconv.ParseBool, and type
+// mappings are given as "uuid.UUID=bytes,time.Time=string". A variable that
+// doesn't name an option is returned as a warning, so that typos aren't
+// silently ignored, without breaking commands in environments that happen
+// to set one.
+func (l *Layers) LoadEnv(environ []string) ([]string, error) {
+	env := make(map[string]string)
+	for _, entry := range environ {
+		if name, value, ok := strings.Cut(entry, "="); ok && strings.HasPrefix(name, EnvPrefix) {
+			env[name] = value
+		}
+	}
+
+	l.env = layer{sources: Sources{}}
+	known := make(map[string]reflect.Type)
+	for _, key := range optionKeys() {
+		name := EnvVar(key)
+		known[name] = nil
+		value, ok := env[name]
+		if !ok {
+			continue
+		}
+		if err := setOption(&l.env.config, key, value); err != nil {
+			return nil, fmt.Errorf("$%s: %w", name, err)
+		}
+		l.env.sources[key] = "$" + name
+	}
+
+	var warnings []string
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		if _, ok := known[name]; ok {
+			continue
+		}
+		msg := fmt.Sprintf("$%s: unknown option", name)
+		if suggestion := closestKey(name, known); suggestion != "" {
+			msg += fmt.Sprintf(", did you mean $%s?", suggestion)
+		}
+		warnings = append(warnings, msg)
+	}
+	return warnings, nil
+}
+
+// LoadFlags adds the flags of FlagKeys that were set on the command line
+func (l *Layers) LoadFlags(flags *pflag.FlagSet) error {
+	l.flags = layer{sources: Sources{}}
+	var err error
+	flags.Visit(func(flag *pflag.Flag) {
+		key, ok := FlagKeys[flag.Name]
+		if !ok || err != nil {
+			return
+		}
+		if err = setOption(&l.flags.config, key, flag.Value.String()); err != nil {
+			err = fmt.Errorf("--%s: %w", flag.Name, err)
+			return
+		}
+		l.flags.sources[key] = "--" + flag.Name
+	})
+	return err
+}
+
+// Targets resolves the configuration of each target. Without targets, the
+// top-level configuration is the only target. If name is set, only that
+// target is returned.
+func (l *Layers) Targets(name string) ([]Target, error) {
+	if len(l.targets) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("target %q not found: the configuration has no targets", name)
+		}
+		config, sources := l.resolve(nil)
+		return []Target{{Config: config, Sources: sources}}, nil
+	}
+
+	var targets []Target
+	var names []string
+	seen := make(map[string]bool)
+	for i, target := range l.targets {
+		if target.Name == "" {
+			return nil, fmt.Errorf("target %d has no name", i+1)
+		}
+		if seen[target.Name] {
+			return nil, fmt.Errorf("duplicate target %q", target.Name)
+		}
+		if len(target.Targets) > 0 {
+			return nil, fmt.Errorf("target %q: targets cannot be nested", target.Name)
+		}
+		seen[target.Name] = true
+		names = append(names, target.Name)
+
+		if name != "" && target.Name != name {
+			continue
+		}
+		config, sources := l.resolve(&l.target[i])
+		targets = append(targets, Target{Name: target.Name, Config: config, Sources: sources})
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("target %q not found, available targets: %s", name, strings.Join(names, ", "))
+	}
+	return targets, nil
+}
+
+// resolve applies the layers, with the options of a target between the
+// top-level options of the config file and the environment
+func (l *Layers) resolve(target *layer) (Config, Sources) {
+	config := DefaultConfig()
+	sources := Sources{}
+	for _, key := range optionKeys() {
+		sources[key] = "default"
+	}
+
+	l.file.apply(&config, sources)
+	if target != nil {
+		target.apply(&config, sources)
+	}
+	l.env.apply(&config, sources)
+	l.flags.apply(&config, sources)
+	return config, sources
+}
+
+// EnvVar returns the environment variable for an option key, e.g.
+// SQLC2PROTO_SERVICE_OPTIONS_HTTP_ANNOTATIONS for
+// serviceOptions.httpAnnotations
+func EnvVar(key string) string {
+	var parts []string
+	for _, segment := range strings.Split(key, ".") {
+		parts = append(parts, strcase.ToScreamingSnake(segment))
+	}
+	return EnvPrefix + strings.Join(parts, "_")
+}
+
+// optionKeys returns the keys of all options except targets, in the order of
+// the Config fields
+func optionKeys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "" || name == "-" || name == "targets" {
+				continue
+			}
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, prefix+name+".")
+				continue
+			}
+			keys = append(keys, prefix+name)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return keys
+}
+
+// optionField returns the field of an option key in cfg
+func optionField(cfg *Config, key string) reflect.Value {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, segment := range strings.Split(key, ".") {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); name == segment {
+				v = v.Field(i)
+				break
+			}
+		}
+	}
+	return v
+}
+
+// copyOption copies an option from src to dst. Type mappings are merged.
+func copyOption(dst, src *Config, key string) {
+	to, from := optionField(dst, key), optionField(src, key)
+	if from.Kind() == reflect.Map {
+		merged := mergeMappings(to.Interface().(map[string]string), from.Interface().(map[string]string))
+		to.Set(reflect.ValueOf(merged))
+		return
+	}
+	to.Set(from)
+}
+
+// setOption sets an option of cfg from its string form
+func setOption(cfg *Config, key, value string) error {
+	field := optionField(cfg, key)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Map:
+		mappings := make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			goType, protoType, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid type mapping %q, want GoType=protoType", pair)
+			}
+			mappings[strings.TrimSpace(goType)] = strings.TrimSpace(protoType)
+		}
+		field.Set(reflect.ValueOf(mappings))
+	default:
+		return fmt.Errorf("unsupported option type %s", field.Type())
+	}
+	return nil
+}
+
+// fileSources returns the options set in a mapping node of a config file,
+// with the file and line that set them
+func fileSources(file string, node *yaml.Node, t reflect.Type, prefix []string) Sources {
+	sources := Sources{}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return sources
+	}
+
+	fields := yamlFields(t)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		field, ok := fields[key.Value]
+		if !ok || key.Value == "targets" || key.Value == "name" {
+			continue
+		}
+		path := append(slices.Clone(prefix), key.Value)
+		if field.Kind() == reflect.Struct {
+			maps.Copy(sources, fileSources(file, value, field, path))
+			continue
+		}
+		if value.Tag == "!!null" {
+			// An empty key sets nothing
+			continue
+		}
+		sources[strings.Join(path, ".")] = fmt.Sprintf("%s:%d", file, key.Line)
+	}
+	return sources
+}
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// testFlags returns a flag set with the flags of FlagKeys, parsed from args
+func testFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	defaults := DefaultConfig()
+	for name, key := range FlagKeys {
+		switch value := optionField(&defaults, key).Interface().(type) {
+		case bool:
+			flags.Bool(name, value, "")
+		case string:
+			flags.String(name, value, "")
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+// loadLayers loads a config file with the given content, the environment
+// and the flags, and resolves the named target
+func loadLayers(t *testing.T, file string, environ []string, args []string, target string) Target {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+	layers := NewLayers()
+	if file != "" {
+		if err := os.WriteFile("sqlc2proto.yaml", []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := layers.LoadFile("sqlc2proto.yaml"); err != nil {
+			t.Fatalf("LoadFile failed: %v", err)
+		}
+	}
+	if _, err := layers.LoadEnv(environ); err != nil {
+		t.Fatalf("LoadEnv failed: %v", err)
+	}
+	if err := layers.LoadFlags(testFlags(t, args...)); err != nil {
+		t.Fatalf("LoadFlags failed: %v", err)
+	}
+	targets, err := layers.Targets(target)
+	if err != nil {
+		t.Fatalf("Targets failed: %v", err)
+	}
+	if len(targets) != 1 {
+		t.Fatalf("got %d targets, want 1", len(targets))
+	}
+	return targets[0]
+}
+
+func TestLayersPrecedence(t *testing.T) {
+	const file = `protoPackage: file.v1
+layout: split
+withMappers: true
+targets:
+  - name: billing
+    protoPackage: target.v1
+`
+
+	tests := []struct {
+		name       string
+		file       string
+		env        []string
+		args       []string
+		key        string
+		wantValue  any
+		wantSource string
+	}{
+		{
+			name:       "default",
+			key:        "layout",
+			wantValue:  "single",
+			wantSource: "default",
+		},
+		{
+			name:       "file over default",
+			file:       "layout: split\n",
+			key:        "layout",
+			wantValue:  "split",
+			wantSource: "sqlc2proto.yaml:1",
+		},
+		{
+			name:       "target over file",
+			file:       file,
+			key:        "protoPackage",
+			wantValue:  "target.v1",
+			wantSource: "sqlc2proto.yaml:6",
+		},
+		{
+			name:       "file option not set by the target",
+			file:       file,
+			key:        "layout",
+			wantValue:  "split",
+			wantSource: "sqlc2proto.yaml:2",
+		},
+		{
+			name:       "env over target",
+			file:       file,
+			env:        []string{"SQLC2PROTO_PROTO_PACKAGE=env.v1"},
+			key:        "protoPackage",
+			wantValue:  "env.v1",
+			wantSource: "$SQLC2PROTO_PROTO_PACKAGE",
+		},
+		{
+			name:       "flag over env",
+			file:       file,
+			env:        []string{"SQLC2PROTO_PROTO_PACKAGE=env.v1"},
+			args:       []string{"--package=flag.v1"},
+			key:        "protoPackage",
+			wantValue:  "flag.v1",
+			wantSource: "--package",
+		},
+		{
+			name:       "false flag over true file option",
+			file:       file,
+			args:       []string{"--with-mappers=false"},
+			key:        "withMappers",
+			wantValue:  false,
+			wantSource: "--with-mappers",
+		},
+		{
+			name:       "false env over true file option",
+			file:       file,
+			env:        []string{"SQLC2PROTO_WITH_MAPPERS=0"},
+			key:        "withMappers",
+			wantValue:  false,
+			wantSource: "$SQLC2PROTO_WITH_MAPPERS",
+		},
+		{
+			name:       "unset flag keeps the file option",
+			file:       file,
+			args:       []string{"--layout=single"},
+			key:        "withMappers",
+			wantValue:  true,
+			wantSource: "sqlc2proto.yaml:3",
+		},
+		{
+			name:       "nested option from env",
+			env:        []string{"SQLC2PROTO_SERVICE_OPTIONS_HTTP_ANNOTATIONS=true"},
+			key:        "serviceOptions.httpAnnotations",
+			wantValue:  true,
+			wantSource: "$SQLC2PROTO_SERVICE_OPTIONS_HTTP_ANNOTATIONS",
+		},
+		{
+			name:       "type mappings merged over the file",
+			file:       "typeMappings:\n  uuid.UUID: bytes\n  time.Time: string\n",
+			env:        []string{"SQLC2PROTO_TYPE_MAPPINGS=time.Time=int64"},
+			key:        "typeMappings",
+			wantValue:  map[string]string{"uuid.UUID": "bytes", "time.Time": "int64"},
+			wantSource: "$SQLC2PROTO_TYPE_MAPPINGS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := ""
+			if strings.Contains(tt.file, "targets:") {
+				target = "billing"
+			}
+			resolved := loadLayers(t, tt.file, tt.env, tt.args, target)
+
+			if got := optionField(&resolved.Config, tt.key).Interface(); !reflect.DeepEqual(got, tt.wantValue) {
+				t.Errorf("%s = %v, want %v", tt.key, got, tt.wantValue)
+			}
+			if got := resolved.Sources[tt.key]; got != tt.wantSource {
+				t.Errorf("source of %s = %q, want %q", tt.key, got, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		key     string
+		want    any
+		wantErr string
+		warning string
+	}{
+		{name: "true", environ: []string{"SQLC2PROTO_WITH_SERVICES=true"}, key: "withServices", want: true},
+		{name: "one", environ: []string{"SQLC2PROTO_WITH_SERVICES=1"}, key: "withServices", want: true},
+		{name: "upper case false", environ: []string{"SQLC2PROTO_WITH_SERVICES=FALSE"}, key: "withServices", want: false},
+		{name: "invalid boolean", environ: []string{"SQLC2PROTO_WITH_SERVICES=yes"}, wantErr: `$SQLC2PROTO_WITH_SERVICES: invalid boolean "yes"`},
+		{name: "string", environ: []string{"SQLC2PROTO_SQLC_DIR=./internal/db"}, key: "sqlcDir", want: "./internal/db"},
+		{name: "empty string", environ: []string{"SQLC2PROTO_SERVICE_PREFIX="}, key: "servicePrefix", want: ""},
+		{
+			name:    "map",
+			environ: []string{"SQLC2PROTO_NULLABLE_TYPE_MAPPINGS= sql.NullTime = string ,pgtype.UUID=bytes,"},
+			key:     "nullableTypeMappings",
+			want:    map[string]string{"sql.NullTime": "string", "pgtype.UUID": "bytes"},
+		},
+		{name: "empty map", environ: []string{"SQLC2PROTO_TYPE_MAPPINGS="}, key: "typeMappings", want: map[string]string{}},
+		{name: "invalid map", environ: []string{"SQLC2PROTO_TYPE_MAPPINGS=uuid.UUID:bytes"}, wantErr: `$SQLC2PROTO_TYPE_MAPPINGS: invalid type mapping "uuid.UUID:bytes", want GoType=protoType`},
+		{name: "unknown option", environ: []string{"SQLC2PROTO_WITH_MAPPER=true"}, key: "withMappers", want: false, warning: "$SQLC2PROTO_WITH_MAPPER: unknown option, did you mean $SQLC2PROTO_WITH_MAPPERS?"},
+		{name: "unknown option without suggestion", environ: []string{"SQLC2PROTO_TOKEN=secret", "SQLC2PROTO_LAYOUT=split"}, key: "layout", want: "split", warning: "$SQLC2PROTO_TOKEN: unknown option"},
+		{name: "other variables", environ: []string{"HOME=/root", "SQLC2PROTO", "XSQLC2PROTO_LAYOUT=split"}, key: "layout", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layers := NewLayers()
+			warnings, err := layers.LoadEnv(tt.environ)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("LoadEnv error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadEnv failed: %v", err)
+			}
+			if got := strings.Join(warnings, "\n"); got != tt.warning {
+				t.Errorf("LoadEnv warnings = %q, want %q", got, tt.warning)
+			}
+			if got := optionField(&layers.env.config, tt.key).Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %#v, want %#v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFlagsInvalid(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("with-mappers", "", "")
+	if err := flags.Parse([]string{"--with-mappers=maybe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := NewLayers().LoadFlags(flags)
+	if want := `--with-mappers: invalid boolean "maybe"`; err == nil || err.Error() != want {
+		t.Errorf("LoadFlags error = %v, want %q", err, want)
+	}
+}
+
+func TestPrintConfigSources(t *testing.T) {
+	const file = `protoPackage: library.v1
+withServices: true
+serviceOptions:
+  splitServices: true
+`
+	resolved := loadLayers(t, file,
+		[]string{"SQLC2PROTO_LAYOUT=split", "SQLC2PROTO_SERVICE_OPTIONS_ENABLE_STREAMING=true"},
+		[]string{"--with-mappers=false", "--field-style=json"}, "")
+	resolved.Config.GoPackagePath = "example.com/library/proto/gen"
+
+	var out bytes.Buffer
+	PrintConfig(&out, resolved.Config, resolved.Sources)
+
+	for _, want := range []string{
+		"Proto Package:     library.v1  (sqlc2proto.yaml:1)\n",
+		"Generate Mappers:  false  (--with-mappers)\n",
+		"Generate Services: true  (sqlc2proto.yaml:2)\n",
+		"Split Services:    true  (sqlc2proto.yaml:4)\n",
+		"Layout:            split  ($SQLC2PROTO_LAYOUT)\n",
+		// Options without a line of their own are listed by key
+		"serviceOptions.enableStreaming: true  ($SQLC2PROTO_SERVICE_OPTIONS_ENABLE_STREAMING)\n",
+		// A flag set to the default still overrides lower layers
+		"Field Style:       json  (--field-style)\n",
+		// Values filled in by code rather than a source
+		"Go Package:        example.com/library/proto/gen  (derived)\n",
+		// Defaults are shown without a source
+		"Service Suffix:    Service\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("PrintConfig output is missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -1,9 +1,10 @@
-package common
+// Package config defines the sqlc2proto configuration and resolves it from
+// the defaults, config files, SQLC2PROTO_* environment variables and
+// command-line flags.
+package config
 
 import (
 	"os"
-
-	"gopkg.in/yaml.v3"
 )
 
 // Config holds the configuration for code generation
@@ -32,11 +33,33 @@ type Config struct {
 	ServicePrefix string `yaml:"servicePrefix"` // Prefix for service names (e.g., "API")
 	ServiceSuffix string `yaml:"serviceSuffix"` // Suffix for service names (e.g., "Service")
 
+	// Service style configuration
+	ServiceStyle string `yaml:"serviceStyle"` // "rpc" (one request/response per query) or "aip" (resource-oriented standard methods)
+
+	// Output layout configuration
+	Layout string `yaml:"layout"` // "single" (models.proto, service.proto, mappers.go) or "split" (one file per entity)
+
 	// Extended service options
 	ServiceOptions ServiceOptions `yaml:"serviceOptions"`
 
+	// Mapper generation options
+	MapperOptions MapperOptions `yaml:"mapperOptions"`
+
 	// Includes file for selective generation
 	IncludeFile string `yaml:"includeFile"` // Path to file specifying which models and queries to include
+
+	// Targets for repositories with several sqlc packages. Each target
+	// inherits the options above and overrides the ones it sets.
+	Targets []Target `yaml:"targets"`
+}
+
+// Target is a sqlc package generated with its own inputs, outputs and options
+type Target struct {
+	Name   string `yaml:"name"`
+	Config `yaml:",inline"`
+
+	// Sources records where each option of a resolved target came from
+	Sources Sources `yaml:"-"`
 }
 
 // ServiceOptions contains configuration options for service generation
@@ -55,6 +78,34 @@ type ServiceOptions struct {
 	PageTokenField     string `yaml:"pageTokenField"`     // Default: "page_token"
 	NextPageTokenField string `yaml:"nextPageTokenField"` // Default: "next_page_token"
 	TotalSizeField     string `yaml:"totalSizeField"`     // Default: "total_size"
+
+	// Whether to add google.api.http annotations for REST transcoding (gRPC-Gateway)
+	HTTPAnnotations bool `yaml:"httpAnnotations"`
+
+	// Path prefix for HTTP rules (e.g. "/v1"), derived from the proto package version if empty
+	HTTPPathPrefix string `yaml:"httpPathPrefix"`
+
+	// Whether to mark read methods with idempotency_level = NO_SIDE_EFFECTS,
+	// which lets Connect clients call them with HTTP GET
+	IdempotentReads bool `yaml:"idempotentReads"`
+
+	// Service name used in AIP resource types (e.g. "library.example.com")
+	// Defaults to the proto package name
+	ResourceDomain string `yaml:"resourceDomain"`
+}
+
+// MapperOptions contains configuration options for mapper generation
+type MapperOptions struct {
+	// Whether FromProto mappers return an error for invalid input (e.g. a
+	// malformed UUID) instead of silently using a zero value
+	Strict bool `yaml:"strict"`
+
+	// Whether slice conversions return empty slices instead of nil, matching
+	// sqlc's emit_empty_slices option
+	EmitEmptySlices bool `yaml:"emitEmptySlices"`
+
+	// Whether to generate mappers_test.go with round-trip tests for the mappers
+	GenerateTests bool `yaml:"generateTests"`
 }
 
 // DefaultConfig returns a default configuration
@@ -69,6 +120,8 @@ func DefaultConfig() Config {
 		ServiceNaming:        "entity",
 		ServicePrefix:        "",
 		ServiceSuffix:        "Service",
+		ServiceStyle:         "rpc",
+		Layout:               "single",
 		ModuleName:           "",
 		ProtoGoImport:        "",
 		FieldStyle:           "json",
@@ -92,13 +145,13 @@ func DefaultServiceOptions() ServiceOptions {
 	}
 }
 
-// LoadConfig loads configuration from a YAML file
+// LoadConfig loads configuration from a YAML file. Unknown keys and invalid
+// values are errors, reported with the line that sets them.
 func LoadConfig(path string) (Config, error) {
-	var config Config
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return config, err
+		return Config{}, err
 	}
-	err = yaml.Unmarshal(data, &config)
+	config, _, err := decodeConfig(path, data)
 	return config, err
 }
@@ -0,0 +1,354 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Allowed values of the enum options
+var (
+	fieldStyles    = []string{"json", "snake_case", "original"}
+	serviceNamings = []string{"entity", "flat", "custom"}
+	serviceStyles  = []string{"rpc", "aip"}
+	layouts        = []string{"single", "split"}
+)
+
+// protoScalarTypes are the scalar value types of proto3
+var protoScalarTypes = []string{
+	"double", "float", "int32", "int64", "uint32", "uint64", "sint32", "sint64",
+	"fixed32", "fixed64", "sfixed32", "sfixed64", "bool", "string", "bytes",
+}
+
+var (
+	// protoPackagePattern matches a proto package name, e.g. "library.v1"
+	protoPackagePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+	// protoMessagePattern matches a fully qualified message type, e.g.
+	// "google.protobuf.Timestamp"
+	protoMessagePattern = regexp.MustCompile(`^\.?([A-Za-z_][A-Za-z0-9_]*\.)+[A-Z][A-Za-z0-9_]*$`)
+
+	// protoIdentPattern matches a proto field name
+	protoIdentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// yamlLinePattern matches the line number in yaml.v3 error messages
+	yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+)
+
+// ConfigError is an invalid option in a configuration file
+type ConfigError struct {
+	File string // Empty if the configuration doesn't come from a file
+	Line int    // Zero if unknown
+
+	// Path is the key path of the option, e.g. ["serviceOptions", "httpPathPrefix"]
+	Path []string
+
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	msg := e.Message
+	if key := e.Key(); key != "" {
+		msg = key + ": " + msg
+	}
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, msg)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, msg)
+	}
+	return msg
+}
+
+// Key returns the key path in the notation of the config file, e.g.
+// targets[0].fieldStyle
+func (e *ConfigError) Key() string {
+	var key strings.Builder
+	for _, segment := range e.Path {
+		if _, err := strconv.Atoi(segment); err == nil {
+			fmt.Fprintf(&key, "[%s]", segment)
+			continue
+		}
+		if key.Len() > 0 {
+			key.WriteByte('.')
+		}
+		key.WriteString(segment)
+	}
+	return key.String()
+}
+
+// Validate checks the option values of a configuration. Empty options are
+// valid, since they fall back to their defaults. All problems are reported
+// at once, as ConfigErrors joined with errors.Join.
+func (c Config) Validate() error {
+	var errs []error
+	c.validate(nil, &errs)
+	return errors.Join(errs...)
+}
+
+// validate appends the problems of c to errs, with paths below prefix
+func (c Config) validate(prefix []string, errs *[]error) {
+	fail := func(message string, path ...string) {
+		*errs = append(*errs, &ConfigError{Path: append(slices.Clone(prefix), path...), Message: message})
+	}
+	oneOf := func(value string, allowed []string, path ...string) {
+		if value != "" && !slices.Contains(allowed, value) {
+			fail(fmt.Sprintf("invalid value %q, must be one of %s", value, strings.Join(allowed, ", ")), path...)
+		}
+	}
+
+	oneOf(c.FieldStyle, fieldStyles, "fieldStyle")
+	oneOf(c.ServiceNaming, serviceNamings, "serviceNaming")
+	oneOf(c.ServiceStyle, serviceStyles, "serviceStyle")
+	oneOf(c.Layout, layouts, "layout")
+
+	if c.ProtoPackageName != "" && !protoPackagePattern.MatchString(c.ProtoPackageName) {
+		fail(fmt.Sprintf("invalid proto package %q, must be identifiers separated by dots, e.g. \"api.v1\"", c.ProtoPackageName), "protoPackage")
+	}
+
+	for _, mappings := range []struct {
+		key    string
+		values map[string]string
+	}{
+		{"typeMappings", c.TypeMappings},
+		{"nullableTypeMappings", c.NullableTypeMappings},
+	} {
+		for _, goType := range sortedKeys(mappings.values) {
+			if protoType := mappings.values[goType]; !isProtoType(protoType) {
+				fail(fmt.Sprintf("invalid proto type %q, must be a scalar type such as int64 or string, or a fully qualified message type such as google.protobuf.Timestamp", protoType), mappings.key, goType)
+			}
+		}
+	}
+
+	options := c.ServiceOptions
+	for _, field := range []struct{ key, value string }{
+		{"pageSizeField", options.PageSizeField},
+		{"pageTokenField", options.PageTokenField},
+		{"nextPageTokenField", options.NextPageTokenField},
+		{"totalSizeField", options.TotalSizeField},
+	} {
+		if field.value != "" && !protoIdentPattern.MatchString(field.value) {
+			fail(fmt.Sprintf("invalid field name %q", field.value), "serviceOptions", field.key)
+		}
+	}
+	if options.HTTPPathPrefix != "" && !strings.HasPrefix(options.HTTPPathPrefix, "/") {
+		fail(fmt.Sprintf("invalid path prefix %q, must start with /", options.HTTPPathPrefix), "serviceOptions", "httpPathPrefix")
+	}
+
+	for i, target := range c.Targets {
+		path := append(slices.Clone(prefix), "targets", strconv.Itoa(i))
+		if target.Name == "" {
+			*errs = append(*errs, &ConfigError{Path: path, Message: "target has no name"})
+		}
+		target.Config.validate(path, errs)
+	}
+}
+
+// isProtoType reports whether t is a scalar type or a fully qualified
+// message type
+func isProtoType(t string) bool {
+	return slices.Contains(protoScalarTypes, t) || protoMessagePattern.MatchString(t)
+}
+
+// decodeConfig strictly decodes a configuration file. Syntax errors, unknown
+// keys, values of the wrong type and invalid values are reported with their
+// line numbers. It also returns the document node, which is nil for an
+// empty file.
+func decodeConfig(file string, data []byte) (Config, *yaml.Node, error) {
+	var config Config
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return config, nil, errors.Join(yamlErrors(file, err)...)
+	}
+	if len(root.Content) == 0 {
+		// An empty file sets nothing
+		return config, nil, nil
+	}
+	doc := root.Content[0]
+
+	var errs []error
+	checkKeys(file, doc, reflect.TypeOf(config), nil, &errs)
+	if err := doc.Decode(&config); err != nil {
+		errs = append(errs, yamlErrors(file, err)...)
+	} else if err := config.Validate(); err != nil {
+		// Point each problem at the line that sets the option
+		for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+			if configErr, ok := err.(*ConfigError); ok {
+				configErr.File = file
+				if node := lookupNode(doc, configErr.Path); node != nil {
+					configErr.Line = node.Line
+				}
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	// Report the problems in the order of the file
+	slices.SortStableFunc(errs, func(a, b error) int {
+		return errorLine(a) - errorLine(b)
+	})
+	return config, doc, errors.Join(errs...)
+}
+
+// errorLine returns the line of a ConfigError, or zero for other errors
+func errorLine(err error) int {
+	if configErr, ok := err.(*ConfigError); ok {
+		return configErr.Line
+	}
+	return 0
+}
+
+// checkKeys reports the keys of a mapping node that don't correspond to a
+// field of the struct type t, recursing into nested structs and lists
+func checkKeys(file string, node *yaml.Node, t reflect.Type, path []string, errs *[]error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		checkKeys(file, node, t.Elem(), path, errs)
+		return
+	case reflect.Slice:
+		if node.Kind == yaml.SequenceNode {
+			for i, item := range node.Content {
+				checkKeys(file, item, t.Elem(), append(slices.Clone(path), strconv.Itoa(i)), errs)
+			}
+		}
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	fields := yamlFields(t)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		field, ok := fields[key.Value]
+		if !ok {
+			msg := fmt.Sprintf("unknown key %q", key.Value)
+			if suggestion := closestKey(key.Value, fields); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			*errs = append(*errs, &ConfigError{File: file, Line: key.Line, Path: path, Message: msg})
+			continue
+		}
+		checkKeys(file, value, field, append(slices.Clone(path), key.Value), errs)
+	}
+}
+
+// yamlFields maps the YAML keys of a struct type to their field types,
+// including the fields of inlined structs
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for key, typ := range yamlFields(field.Type) {
+				fields[key] = typ
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// closestKey returns the known key closest to an unknown one, or "" if none
+// is close enough to be a likely typo
+func closestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for candidate := range fields {
+		if strings.EqualFold(candidate, key) {
+			return candidate
+		}
+		if d := editDistance(strings.ToLower(key), strings.ToLower(candidate)); d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// lookupNode returns the value node at the given key path
+func lookupNode(node *yaml.Node, path []string) *yaml.Node {
+	for _, segment := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					next = node.Content[i+1]
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			node = next
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[i]
+		default:
+			return nil
+		}
+	}
+	return node
+}
+
+// yamlErrors converts the errors of yaml.v3, which embed "line N" in their
+// messages, to ConfigErrors with a file and line
+func yamlErrors(file string, err error) []error {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	var errs []error
+	for _, msg := range messages {
+		configErr := &ConfigError{File: file, Message: strings.TrimPrefix(msg, "yaml: ")}
+		if m := yamlLinePattern.FindStringSubmatch(msg); m != nil {
+			configErr.Line, _ = strconv.Atoi(m[1])
+			configErr.Message = m[2]
+		}
+		errs = append(errs, configErr)
+	}
+	return errs
+}
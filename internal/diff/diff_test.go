@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	new := "a\nb\nc\nD\ne\nf\ng\nh\ni\nj\nk\nl\n"
+
+	got := Unified("a/file", "b/file", []byte(old), []byte(new))
+	want := `--- a/file
++++ b/file
+@@ -1,7 +1,7 @@
+ a
+ b
+ c
+-d
++D
+ e
+ f
+ g
+@@ -9,3 +9,4 @@
+ i
+ j
+ k
++l
+`
+	if got != want {
+		t.Errorf("Expected diff:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestUnifiedEqual(t *testing.T) {
+	if got := Unified("a", "b", []byte("same\n"), []byte("same\n")); got != "" {
+		t.Errorf("Expected no diff for equal texts, got:\n%s", got)
+	}
+}
+
+func TestUnifiedNewAndRemovedFiles(t *testing.T) {
+	got := Unified("/dev/null", "b/file", nil, []byte("x\ny\n"))
+	if !strings.Contains(got, "@@ -0,0 +1,2 @@\n+x\n+y\n") {
+		t.Errorf("Expected an added file hunk, got:\n%s", got)
+	}
+
+	got = Unified("a/file", "/dev/null", []byte("x\n"), nil)
+	if !strings.Contains(got, "@@ -1 +0,0 @@\n-x\n") {
+		t.Errorf("Expected a removed file hunk, got:\n%s", got)
+	}
+}
+
+func TestUnifiedNoTrailingNewline(t *testing.T) {
+	got := Unified("a", "b", []byte("x"), []byte("y"))
+	if !strings.Contains(got, "-x\n\\ No newline at end of file\n+y\n\\ No newline at end of file\n") {
+		t.Errorf("Expected missing newlines to be marked, got:\n%s", got)
+	}
+}
+
+func TestColorize(t *testing.T) {
+	got := Colorize(Unified("a/file", "b/file", []byte("x\nsame\n"), []byte("y\nsame\n")))
+	want := "\x1b[1m--- a/file\x1b[0m\n" +
+		"\x1b[1m+++ b/file\x1b[0m\n" +
+		"\x1b[36m@@ -1,2 +1,2 @@\x1b[0m\n" +
+		"\x1b[31m-x\x1b[0m\n" +
+		"\x1b[32m+y\x1b[0m\n" +
+		" same\n"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,266 @@
+package generator
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// sqlcTestDir contains sqlc output for the library example
+var sqlcTestDir = filepath.Join("testdata", "library", "sqlc")
+
+// testConfig returns the configuration used for the golden files
+func testConfig() config.Config {
+	config := config.DefaultConfig()
+	config.SQLCDir = "./db/sqlc"
+	config.ProtoOutputDir = "./proto/gen"
+	config.ProtoPackageName = "library.v1"
+	config.ModuleName = "example.com/library"
+	config.GoPackagePath = "example.com/library/proto/gen"
+	config.GenerateMappers = true
+	config.GenerateServices = true
+	return config
+}
+
+// generateAll runs the full generation pipeline for the library example
+// and returns the contents of the generated files
+func generateAll(t *testing.T, cfg config.Config) map[string][]byte {
+	t.Helper()
+
+	messages, err := parser.ProcessSQLCDirectory(sqlcTestDir, cfg.FieldStyle)
+	if err != nil {
+		t.Fatalf("ProcessSQLCDirectory failed: %v", err)
+	}
+
+	queryMethods, err := parser.ParseSQLCQuerierInterface(sqlcTestDir)
+	if err != nil {
+		t.Fatalf("ParseSQLCQuerierInterface failed: %v", err)
+	}
+
+	var services []parser.ServiceDefinition
+	if cfg.ServiceStyle == "aip" {
+		services = parser.GenerateAIPServiceDefinitions(queryMethods, messages, parser.GetTypeMapConfig(), config.ResourceDomain(cfg), nil)
+		parser.ApplyResourceAnnotations(messages, services)
+	} else {
+		services = parser.GenerateServiceDefinitions(queryMethods, messages, parser.GetTypeMapConfig(), nil)
+	}
+
+	outDir := t.TempDir()
+	files := map[string]func(string) error{
+		"models.proto": func(path string) error {
+			return GenerateProtoFile(messages, cfg, path)
+		},
+		"mappers.go": func(path string) error {
+			return GenerateMapperFile(messages, cfg, path)
+		},
+		"service.proto": func(path string) error {
+			return GenerateServiceFile(services, cfg, path)
+		},
+		"mappers_test.go": func(path string) error {
+			return GenerateMapperTestFile(messages, cfg, path)
+		},
+	}
+
+	output := make(map[string][]byte)
+	for name, generate := range files {
+		path := filepath.Join(outDir, name)
+		if err := generate(path); err != nil {
+			t.Fatalf("Failed to generate %s: %v", name, err)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		output[name] = content
+	}
+
+	return output
+}
+
+func TestGenerateGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func() config.Config
+	}{
+		{
+			name: "rpc",
+			config: func() config.Config {
+				config := testConfig()
+				config.ServiceOptions.HTTPAnnotations = true
+				return config
+			},
+		},
+		{
+			name: "aip",
+			config: func() config.Config {
+				config := testConfig()
+				config.ServiceStyle = "aip"
+				config.ServiceOptions.ResourceDomain = "library.example.com"
+				config.ServiceOptions.HTTPAnnotations = true
+				config.ServiceOptions.IdempotentReads = true
+				return config
+			},
+		},
+		{
+			name: "aip-json",
+			config: func() config.Config {
+				config := testConfig()
+				config.FieldStyle = "json"
+				config.ServiceStyle = "aip"
+				config.ServiceOptions.ResourceDomain = "library.example.com"
+				config.ServiceOptions.HTTPAnnotations = true
+				return config
+			},
+		},
+		{
+			name: "strict",
+			config: func() config.Config {
+				config := testConfig()
+				config.MapperOptions.Strict = true
+				return config
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := generateAll(t, tt.config())
+			second := generateAll(t, tt.config())
+
+			for name, content := range first {
+				// Generation must be deterministic
+				if !bytes.Equal(content, second[name]) {
+					t.Errorf("%s differs between runs", name)
+				}
+
+				goldenPath := filepath.Join("testdata", "library", "golden", tt.name, name+".golden")
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+						t.Fatalf("Failed to create golden directory: %v", err)
+					}
+					if err := os.WriteFile(goldenPath, content, 0o644); err != nil {
+						t.Fatalf("Failed to update golden file: %v", err)
+					}
+					continue
+				}
+
+				golden, err := os.ReadFile(goldenPath)
+				if err != nil {
+					t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+				}
+				if !bytes.Equal(content, golden) {
+					t.Errorf("%s does not match %s (run with -update to refresh)\n--- got ---\n%s", name, goldenPath, content)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderSplitFiles(t *testing.T) {
+	config := testConfig()
+
+	messages, err := parser.ProcessSQLCDirectory(sqlcTestDir, config.FieldStyle)
+	if err != nil {
+		t.Fatalf("ProcessSQLCDirectory failed: %v", err)
+	}
+	queryMethods, err := parser.ParseSQLCQuerierInterface(sqlcTestDir)
+	if err != nil {
+		t.Fatalf("ParseSQLCQuerierInterface failed: %v", err)
+	}
+	services := parser.GenerateServiceDefinitions(queryMethods, messages, parser.GetTypeMapConfig(), nil)
+
+	protoFiles, err := RenderSplitProtoFiles(messages, config, "out")
+	if err != nil {
+		t.Fatalf("RenderSplitProtoFiles failed: %v", err)
+	}
+	serviceFiles, err := RenderSplitServiceFiles(services, ModelFiles(messages, true), config, "out")
+	if err != nil {
+		t.Fatalf("RenderSplitServiceFiles failed: %v", err)
+	}
+	mapperFiles, err := RenderSplitMapperFiles(messages, config, filepath.Join("out", "mappers"))
+	if err != nil {
+		t.Fatalf("RenderSplitMapperFiles failed: %v", err)
+	}
+
+	rendered := make(map[string]string)
+	for _, files := range [][]File{protoFiles, serviceFiles, mapperFiles} {
+		for _, file := range files {
+			rendered[filepath.ToSlash(file.Path)] = string(file.Content)
+		}
+	}
+
+	for _, path := range []string{"out/book.proto", "out/loan.proto", "out/book_service.proto", "out/mappers/helpers.go", "out/mappers/book.go"} {
+		if _, ok := rendered[path]; !ok {
+			t.Errorf("Expected %s to be generated", path)
+		}
+	}
+
+	// Each message is defined exactly once across the model files
+	for _, msg := range messages {
+		if msg.Name == "Queries" {
+			continue
+		}
+		count := 0
+		for _, file := range protoFiles {
+			count += bytes.Count(file.Content, []byte("message "+msg.Name+" {"))
+		}
+		if count != 1 {
+			t.Errorf("Expected message %s to be defined once, got %d", msg.Name, count)
+		}
+	}
+
+	// Services import the model files they use
+	if !strings.Contains(rendered["out/book_service.proto"], `import "book.proto";`) {
+		t.Errorf("book_service.proto should import book.proto:\n%s", rendered["out/book_service.proto"])
+	}
+
+	// Helpers are only emitted once, and converters live with their entity
+	if !strings.Contains(rendered["out/mappers/book.go"], "func BookToProto(") {
+		t.Errorf("book.go should contain BookToProto")
+	}
+	if strings.Contains(rendered["out/mappers/helpers.go"], "ToProto(in *db.") {
+		t.Errorf("helpers.go should not contain converters")
+	}
+}
+
+func TestFormatGoSource(t *testing.T) {
+	src := []byte(`package mappers
+func convert(in *db.Book) *pb.Book {
+	id := uuid.Must(uuid.Parse("00000000-0000-0000-0000-000000000000"))
+	return &pb.Book{Id: id.String(), Data: string(json.RawMessage("{}"))}
+}
+`)
+	out, err := formatGoSource(src, map[string]string{"pb": "example.com/proto", "db": "example.com/db", "unused": "example.com/unused"})
+	if err != nil {
+		t.Fatalf("formatGoSource failed: %v", err)
+	}
+
+	for _, imp := range []string{`"encoding/json"`, `"github.com/google/uuid"`, `pb "example.com/proto"`, `db "example.com/db"`} {
+		if !bytes.Contains(out, []byte(imp)) {
+			t.Errorf("Expected import %s, got:\n%s", imp, out)
+		}
+	}
+	for _, imp := range []string{`"example.com/unused"`, `"time"`, `"fmt"`} {
+		if bytes.Contains(out, []byte(imp)) {
+			t.Errorf("Expected %s not to be imported, got:\n%s", imp, out)
+		}
+	}
+
+	// Generated code that does not parse reports the offending line
+	_, err = formatGoSource([]byte("package mappers\n\nfunc broken( {\n}\n"), nil)
+	if err == nil {
+		t.Fatal("Expected an error for invalid code")
+	}
+	if !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "func broken( {") {
+		t.Errorf("Expected error to mention line 3 and its source, got: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	goparser "go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// goPackages maps the package names used by generated Go code to their
+// import paths
+var goPackages = map[string]string{
+	"big":         "math/big",
+	"fmt":         "fmt",
+	"json":        "encoding/json",
+	"math":        "math",
+	"rand":        "math/rand",
+	"reflect":     "reflect",
+	"sql":         "database/sql",
+	"strconv":     "strconv",
+	"testing":     "testing",
+	"time":        "time",
+	"pgconn":      "github.com/jackc/pgx/v5/pgconn",
+	"pgtype":      "github.com/jackc/pgx/v5/pgtype",
+	"timestamppb": "google.golang.org/protobuf/types/known/timestamppb",
+	"uuid":        "github.com/google/uuid",
+}
+
+// formatGoSource adds the imports used by generated Go code and formats it
+// like gofmt. Aliased imports (e.g. pb and db) are given by name; only the
+// packages the code actually references are imported.
+func formatGoSource(src []byte, aliases map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := goparser.ParseFile(fset, "", src, goparser.ParseComments)
+	if err != nil {
+		return nil, syntaxError(src, err)
+	}
+	if len(file.Imports) > 0 {
+		return nil, fmt.Errorf("generated code must not declare its own imports")
+	}
+
+	// Collect the packages referenced by unresolved selectors, e.g. uuid.Parse
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+			used[ident.Name] = true
+		}
+		return true
+	})
+
+	// Standard library imports first, then third-party packages
+	var std, thirdParty []string
+	for name := range used {
+		if path, ok := aliases[name]; ok {
+			thirdParty = append(thirdParty, fmt.Sprintf("%s %q", name, path))
+		} else if path, ok := goPackages[name]; ok {
+			if strings.Contains(strings.Split(path, "/")[0], ".") {
+				thirdParty = append(thirdParty, fmt.Sprintf("%q", path))
+			} else {
+				std = append(std, fmt.Sprintf("%q", path))
+			}
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(thirdParty)
+
+	var imports bytes.Buffer
+	if len(std)+len(thirdParty) > 0 {
+		imports.WriteString("\n\nimport (\n")
+		for _, imp := range std {
+			imports.WriteString("\t" + imp + "\n")
+		}
+		if len(std) > 0 && len(thirdParty) > 0 {
+			imports.WriteString("\n")
+		}
+		for _, imp := range thirdParty {
+			imports.WriteString("\t" + imp + "\n")
+		}
+		imports.WriteString(")")
+	}
+
+	// Insert the import block after the package clause
+	offset := fset.Position(file.Name.End()).Offset
+	var out bytes.Buffer
+	out.Write(src[:offset])
+	out.Write(imports.Bytes())
+	out.Write(src[offset:])
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, syntaxError(out.Bytes(), err)
+	}
+	return formatted, nil
+}
+
+// syntaxError describes a parse error in generated code, quoting the
+// offending line
+func syntaxError(src []byte, err error) error {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		pos := list[0].Pos
+		lines := bytes.Split(src, []byte("\n"))
+		if pos.Line >= 1 && pos.Line <= len(lines) {
+			return fmt.Errorf("generated Go code does not parse: line %d: %s\n\t%s",
+				pos.Line, list[0].Msg, strings.TrimSpace(string(lines[pos.Line-1])))
+		}
+	}
+	return fmt.Errorf("generated Go code does not parse: %w", err)
+}
@@ -0,0 +1,447 @@
+package generator
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+//go:embed mapper_test.tmpl
+var mapperTestTemplate string
+
+// valueGenerator produces random values of a sqlc Go type in the generated
+// round-trip tests
+type valueGenerator struct {
+	Func string // Name of the generator function
+	Code string // Implementation of the generator function
+}
+
+// baseGenerators are the generators other generators build on, which are
+// always emitted
+var baseGenerators = []string{`
+// randInt64 returns a random int64, zero one time in eight
+func randInt64(rng *rand.Rand) int64 {
+	if rng.Intn(8) == 0 {
+		return 0
+	}
+	return int64(rng.Uint64())
+}`, `
+// randString returns a random string, empty one time in sixteen
+func randString(rng *rand.Rand) string {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 "
+	b := make([]byte, rng.Intn(16))
+	for i := range b {
+		b[i] = letters[rng.Intn(len(letters))]
+	}
+	return string(b)
+}`, `
+// randBool returns a random bool
+func randBool(rng *rand.Rand) bool {
+	return rng.Intn(2) == 1
+}`, `
+// randValid returns false one time in four, to generate NULL values
+func randValid(rng *rand.Rand) bool {
+	return rng.Intn(4) != 0
+}`, `
+// randSlice returns a non-empty slice of random values
+func randSlice[T any](rng *rand.Rand, gen func(*rand.Rand) T) []T {
+	out := make([]T, 1+rng.Intn(4))
+	for i := range out {
+		out[i] = gen(rng)
+	}
+	return out
+}`,
+}
+
+// valueGenerators maps sqlc Go types to their random value generators
+var valueGenerators = map[string]valueGenerator{
+	"string": {Func: "randString"},
+	"int64":  {Func: "randInt64"},
+	"bool":   {Func: "randBool"},
+	"int": {Func: "randInt", Code: `
+// randInt returns a random int in the int32 range
+func randInt(rng *rand.Rand) int {
+	return int(int32(randInt64(rng)))
+}`},
+	"int16": {Func: "randInt16", Code: `
+// randInt16 returns a random int16
+func randInt16(rng *rand.Rand) int16 {
+	return int16(randInt64(rng))
+}`},
+	"int32": {Func: "randInt32", Code: `
+// randInt32 returns a random int32
+func randInt32(rng *rand.Rand) int32 {
+	return int32(randInt64(rng))
+}`},
+	"float32": {Func: "randFloat32", Code: `
+// randFloat32 returns a random float32, zero one time in eight
+func randFloat32(rng *rand.Rand) float32 {
+	if rng.Intn(8) == 0 {
+		return 0
+	}
+	return float32(rng.NormFloat64() * 1e6)
+}`},
+	"float64": {Func: "randFloat64", Code: `
+// randFloat64 returns a random float64, zero one time in eight
+func randFloat64(rng *rand.Rand) float64 {
+	if rng.Intn(8) == 0 {
+		return 0
+	}
+	return rng.NormFloat64() * 1e6
+}`},
+	"[]byte": {Func: "randBytes", Code: `
+// randBytes returns a non-empty random byte slice
+func randBytes(rng *rand.Rand) []byte {
+	b := make([]byte, 1+rng.Intn(16))
+	rng.Read(b)
+	return b
+}`},
+	"time.Time": {Func: "randTime", Code: `
+// randTime returns a random UTC time
+func randTime(rng *rand.Rand) time.Time {
+	return time.Unix(rng.Int63n(1<<34), rng.Int63n(1e9)).UTC()
+}`},
+	"sql.NullString": {Func: "randNullString", Code: `
+// randNullString returns a random sql.NullString
+func randNullString(rng *rand.Rand) sql.NullString {
+	if !randValid(rng) {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: randString(rng), Valid: true}
+}`},
+	"sql.NullInt16": {Func: "randNullInt16", Code: `
+// randNullInt16 returns a random sql.NullInt16
+func randNullInt16(rng *rand.Rand) sql.NullInt16 {
+	if !randValid(rng) {
+		return sql.NullInt16{}
+	}
+	return sql.NullInt16{Int16: int16(randInt64(rng)), Valid: true}
+}`},
+	"sql.NullInt32": {Func: "randNullInt32", Code: `
+// randNullInt32 returns a random sql.NullInt32
+func randNullInt32(rng *rand.Rand) sql.NullInt32 {
+	if !randValid(rng) {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(randInt64(rng)), Valid: true}
+}`},
+	"sql.NullInt64": {Func: "randNullInt64", Code: `
+// randNullInt64 returns a random sql.NullInt64
+func randNullInt64(rng *rand.Rand) sql.NullInt64 {
+	if !randValid(rng) {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: randInt64(rng), Valid: true}
+}`},
+	"sql.NullFloat64": {Func: "randNullFloat64", Code: `
+// randNullFloat64 returns a random sql.NullFloat64
+func randNullFloat64(rng *rand.Rand) sql.NullFloat64 {
+	if !randValid(rng) {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: rng.NormFloat64(), Valid: true}
+}`},
+	"sql.NullBool": {Func: "randNullBool", Code: `
+// randNullBool returns a random sql.NullBool
+func randNullBool(rng *rand.Rand) sql.NullBool {
+	if !randValid(rng) {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: randBool(rng), Valid: true}
+}`},
+	"sql.NullTime": {Func: "randNullTime", Code: `
+// randNullTime returns a random sql.NullTime
+func randNullTime(rng *rand.Rand) sql.NullTime {
+	if !randValid(rng) {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: time.Unix(rng.Int63n(1<<34), rng.Int63n(1e9)).UTC(), Valid: true}
+}`},
+	"pgtype.Date": {Func: "randDate", Code: `
+// randDate returns a random pgtype.Date
+func randDate(rng *rand.Rand) pgtype.Date {
+	if !randValid(rng) {
+		return pgtype.Date{}
+	}
+	return pgtype.Date{Time: time.Date(1900+rng.Intn(300), time.Month(1+rng.Intn(12)), 1+rng.Intn(28), 0, 0, 0, 0, time.UTC), Valid: true}
+}`},
+	"pgtype.Timestamptz": {Func: "randTimestamptz", Code: `
+// randTimestamptz returns a random pgtype.Timestamptz
+func randTimestamptz(rng *rand.Rand) pgtype.Timestamptz {
+	if !randValid(rng) {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: time.Unix(rng.Int63n(1<<34), rng.Int63n(1e9)).UTC(), Valid: true}
+}`},
+	"pgtype.Text": {Func: "randText", Code: `
+// randText returns a random pgtype.Text
+func randText(rng *rand.Rand) pgtype.Text {
+	if !randValid(rng) {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: randString(rng), Valid: true}
+}`},
+	"pgtype.Numeric": {Func: "randNumeric", Code: `
+// randNumeric returns a random pgtype.Numeric
+func randNumeric(rng *rand.Rand) pgtype.Numeric {
+	if !randValid(rng) {
+		return pgtype.Numeric{}
+	}
+	return pgtype.Numeric{Int: big.NewInt(randInt64(rng)), Exp: -int32(rng.Intn(4)), Valid: true}
+}`},
+	"pgtype.Interval": {Func: "randInterval", Code: `
+// randInterval returns a random pgtype.Interval
+func randInterval(rng *rand.Rand) pgtype.Interval {
+	return pgtype.Interval{Microseconds: randInt64(rng), Days: rng.Int31n(30), Months: rng.Int31n(12), Valid: true}
+}`},
+	"uuid.UUID": {Func: "randUUID", Code: `
+// randUUID returns a random uuid.UUID
+func randUUID(rng *rand.Rand) uuid.UUID {
+	var u uuid.UUID
+	rng.Read(u[:])
+	return u
+}`},
+	"uuid.NullUUID": {Func: "randNullUUID", Code: `
+// randNullUUID returns a random uuid.NullUUID
+func randNullUUID(rng *rand.Rand) uuid.NullUUID {
+	if !randValid(rng) {
+		return uuid.NullUUID{}
+	}
+	var u uuid.UUID
+	rng.Read(u[:])
+	return uuid.NullUUID{UUID: u, Valid: true}
+}`},
+	"json.RawMessage": {Func: "randJSON", Code: `
+// randJSON returns a random JSON object
+func randJSON(rng *rand.Rand) json.RawMessage {
+	return json.RawMessage(` + "`" + `{"n":` + "`" + ` + strconv.FormatInt(randInt64(rng), 10) + "}")
+}`},
+}
+
+// lossyRestore undoes the known loss of a conversion before the round-trip
+// comparison, so only the values the conversion can't represent are ignored
+type lossyRestore struct {
+	Code   string // Statement restoring the field, with %[1]s for its name
+	Helper string // Function the statement uses, if any
+}
+
+// lossyRestores maps sqlc Go types to the statements restoring what their
+// conversion loses
+var lossyRestores = map[string]lossyRestore{
+	"sql.NullString": {Code: `if want.%[1]s.Valid && want.%[1]s.String == "" {
+		got.%[1]s = want.%[1]s
+	}`},
+	"pgtype.Text": {Code: `if want.%[1]s.Valid && want.%[1]s.String == "" {
+		got.%[1]s = want.%[1]s
+	}`},
+	"sql.NullInt16": {Code: `if want.%[1]s.Valid && want.%[1]s.Int16 == 0 {
+		got.%[1]s = want.%[1]s
+	}`},
+	"sql.NullInt32": {Code: `if want.%[1]s.Valid && want.%[1]s.Int32 == 0 {
+		got.%[1]s = want.%[1]s
+	}`},
+	"sql.NullInt64": {Code: `if want.%[1]s.Valid && want.%[1]s.Int64 == 0 {
+		got.%[1]s = want.%[1]s
+	}`},
+	"sql.NullFloat64": {Code: `if want.%[1]s.Valid && want.%[1]s.Float64 == 0 {
+		got.%[1]s = want.%[1]s
+	}`},
+	"sql.NullBool": {Code: `if !want.%[1]s.Valid {
+		got.%[1]s = want.%[1]s
+	}`},
+	"pgtype.Date": {Code: `if !want.%[1]s.Valid {
+		got.%[1]s = want.%[1]s
+	}`},
+	"pgtype.Timestamptz": {Code: `if !want.%[1]s.Valid {
+		got.%[1]s = want.%[1]s
+	}`},
+	"pgtype.Interval": {Code: `got.%[1]s.Days, got.%[1]s.Months = want.%[1]s.Days, want.%[1]s.Months`},
+	"pgtype.Numeric": {Code: `if numericEqual(got.%[1]s, want.%[1]s) {
+		got.%[1]s = want.%[1]s
+	}`, Helper: `
+// numericEqual reports whether two pgtype.Numeric hold the same number,
+// whatever their exponent
+func numericEqual(a, b pgtype.Numeric) bool {
+	if !a.Valid || !b.Valid {
+		return a.Valid == b.Valid
+	}
+	x, y := new(big.Int).Set(a.Int), new(big.Int).Set(b.Int)
+	ten := big.NewInt(10)
+	for exp := a.Exp; exp > b.Exp; exp-- {
+		x.Mul(x, ten)
+	}
+	for exp := b.Exp; exp > a.Exp; exp-- {
+		y.Mul(y, ten)
+	}
+	return x.Cmp(y) == 0
+}`},
+}
+
+// restoreStatement returns the statement restoring what the conversion of a
+// lossy field loses. Types without a known restore, such as custom type
+// mappings, are excluded from the comparison altogether.
+func restoreStatement(field parser.ProtoField, helpers map[string]bool) string {
+	restore, ok := lossyRestores[field.GoType]
+	if !ok {
+		return fmt.Sprintf("got.%[1]s = want.%[1]s", field.SQLCName)
+	}
+	if restore.Helper != "" {
+		helpers[field.GoType] = true
+	}
+	return fmt.Sprintf(restore.Code, field.SQLCName)
+}
+
+// roundTripField is a field populated in a round-trip test
+type roundTripField struct {
+	Name      string
+	Generator string
+}
+
+// lossyField is a field whose lost values are restored before the round-trip
+// comparison
+type lossyField struct {
+	Name    string
+	Reason  string
+	Restore string
+}
+
+// roundTripTest is the round-trip test of a single message
+type roundTripTest struct {
+	Struct    string
+	Fields    []roundTripField
+	Lossy     []lossyField
+	HasValues bool
+}
+
+// GenerateMapperTestFile generates a mappers_test.go file with round-trip
+// tests for the mappers of the given messages
+func GenerateMapperTestFile(messages []parser.ProtoMessage, config config.Config, outputPath string) error {
+	file, err := MapperTestFile(messages, config, outputPath)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(file.Path, file.Content)
+}
+
+// MapperTestFile renders the round-trip tests for the mappers in memory
+func MapperTestFile(messages []parser.ProtoMessage, config config.Config, outputPath string) (File, error) {
+	content, err := renderMapperTestFile(messages, config)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Path: outputPath, Content: content}, nil
+}
+
+// renderMapperTestFile renders the round-trip tests for the given messages
+func renderMapperTestFile(messages []parser.ProtoMessage, config config.Config) ([]byte, error) {
+	tmpl, err := template.New("mapper_test").Parse(mapperTestTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	usedGenerators := make(map[string]bool)
+	usedHelpers := make(map[string]bool)
+
+	var tests []roundTripTest
+	for _, msg := range messages {
+		if msg.Name == "Queries" {
+			continue
+		}
+
+		test := roundTripTest{Struct: msg.SQLCStruct}
+		for _, field := range msg.Fields {
+			if field.Lossy != "" {
+				test.Lossy = append(test.Lossy, lossyField{
+					Name:    field.SQLCName,
+					Reason:  field.Lossy,
+					Restore: restoreStatement(field, usedHelpers),
+				})
+			}
+
+			// Fields of types without a generator keep their zero value
+			expr, ok := generatorExpr(field.GoType, usedGenerators)
+			if !ok {
+				continue
+			}
+			test.Fields = append(test.Fields, roundTripField{Name: field.SQLCName, Generator: expr})
+			test.HasValues = true
+		}
+		tests = append(tests, test)
+	}
+
+	// Emit the generators used by the tests, sorted by name
+	generators := append([]string{}, baseGenerators...)
+	for _, goType := range sortedGeneratorTypes(usedGenerators) {
+		gen := valueGenerators[goType]
+		if gen.Code != "" {
+			generators = append(generators, gen.Code)
+		}
+	}
+	for _, goType := range slices.Sorted(maps.Keys(usedHelpers)) {
+		generators = append(generators, lossyRestores[goType].Helper)
+	}
+	for i := range generators {
+		generators[i] = strings.TrimPrefix(generators[i], "\n")
+	}
+
+	data := struct {
+		PackageName string
+		Tests       []roundTripTest
+		Generators  []string
+		Strict      bool
+	}{
+		PackageName: "mappers",
+		Tests:       tests,
+		Generators:  generators,
+		Strict:      config.MapperOptions.Strict,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return formatGoSource(buf.Bytes(), map[string]string{"db": dbImportPath(config)})
+}
+
+// generatorExpr returns the expression producing a random value of a Go type,
+// recording the generators it uses
+func generatorExpr(goType string, used map[string]bool) (string, bool) {
+	if elem, ok := strings.CutPrefix(goType, "[]"); ok && goType != "[]byte" {
+		gen, ok := valueGenerators[elem]
+		if !ok {
+			return "", false
+		}
+		used[elem] = true
+		return fmt.Sprintf("randSlice(rng, %s)", gen.Func), true
+	}
+
+	gen, ok := valueGenerators[goType]
+	if !ok {
+		return "", false
+	}
+	used[goType] = true
+	return fmt.Sprintf("%s(rng)", gen.Func), true
+}
+
+// sortedGeneratorTypes returns the used generator types ordered by function name
+func sortedGeneratorTypes(used map[string]bool) []string {
+	types := make([]string, 0, len(used))
+	for goType := range used {
+		types = append(types, goType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return valueGenerators[types[i]].Func < valueGenerators[types[j]].Func
+	})
+	return types
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
-	"github.com/boomskats/sqlc2proto/cmd/common"
+	"github.com/boomskats/sqlc2proto/internal/config"
 	"github.com/boomskats/sqlc2proto/internal/parser"
 	"github.com/iancoleman/strcase"
 )
@@ -33,14 +35,37 @@ func init() {
 }
 
 // GenerateProtoFile generates a .proto file from message definitions
-func GenerateProtoFile(messages []parser.ProtoMessage, config common.Config, outputPath string) error {
+func GenerateProtoFile(messages []parser.ProtoMessage, config config.Config, outputPath string) error {
+	file, err := ProtoFile(messages, config, outputPath)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(file.Path, file.Content)
+}
+
+// ProtoFile renders a .proto file from message definitions in memory
+func ProtoFile(messages []parser.ProtoMessage, config config.Config, outputPath string) (File, error) {
+	content, err := renderProtoFile(messages, config, nil)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Path: outputPath, Content: content}, nil
+}
+
+// renderProtoFile renders a .proto file for the given messages, importing the
+// given proto files for messages defined elsewhere
+func renderProtoFile(messages []parser.ProtoMessage, config config.Config, localImports []string) ([]byte, error) {
 	tmpl, err := template.New("proto").Funcs(template.FuncMap{
-		"camelCase":  strcase.ToLowerCamel,
-		"pascalCase": strcase.ToCamel,
-		"snakeCase":  strcase.ToSnake,
+		"camelCase":    strcase.ToLowerCamel,
+		"pascalCase":   strcase.ToCamel,
+		"snakeCase":    strcase.ToSnake,
+		"fieldOptions": fieldOptions,
+		"joinInts":     joinInts,
 	}).Parse(protoTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
 	// Set ProtoPackage for each message
@@ -50,66 +75,180 @@ func GenerateProtoFile(messages []parser.ProtoMessage, config common.Config, out
 
 	// Create template data
 	data := struct {
-		Messages        []parser.ProtoMessage
-		PackageName     string
-		GoPackagePath   string
-		HasTimestampMsg bool
+		Messages      []parser.ProtoMessage
+		PackageName   string
+		GoPackagePath string
+		Imports       []string
 	}{
-		Messages:    messages,
-		PackageName: config.ProtoPackageName,
-		GoPackagePath: func() string {
-			// If GoPackagePath is explicitly set, use it
-			if config.GoPackagePath != "" {
-				return config.GoPackagePath
-			}
+		Messages:      messages,
+		PackageName:   config.ProtoPackageName,
+		GoPackagePath: goPackagePath(config),
+	}
 
-			// Otherwise, derive it from moduleName and protoDir
-			moduleName := config.ModuleName
-			if moduleName == "" {
-				moduleName = "github.com/boomskats/sqlc2proto"
-			}
+	// Collect the imports needed by field types and options
+	var fields []parser.ProtoField
+	var options []string
+	for _, msg := range messages {
+		fields = append(fields, msg.Fields...)
+		options = append(options, msg.Options...)
+	}
+	data.Imports = append(sortedImports(localImports), protoImports(fields, options)...)
+
+	// Execute template
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
 
-			protoDir := strings.TrimPrefix(config.ProtoOutputDir, "./")
+	return buf.Bytes(), nil
+}
 
-			return filepath.Join(moduleName, protoDir)
-		}(),
+// goPackagePath returns the go_package option for generated proto files
+func goPackagePath(config config.Config) string {
+	// If GoPackagePath is explicitly set, use it
+	if config.GoPackagePath != "" {
+		return config.GoPackagePath
 	}
 
-	// Check if any message uses Timestamp
-	for _, msg := range messages {
-		for _, field := range msg.Fields {
-			if field.Type == "google.protobuf.Timestamp" {
-				data.HasTimestampMsg = true
-				break
-			}
-		}
-		if data.HasTimestampMsg {
-			break
-		}
+	// Otherwise, derive it from moduleName and protoDir
+	moduleName := config.ModuleName
+	if moduleName == "" {
+		moduleName = "github.com/boomskats/sqlc2proto"
 	}
 
+	protoDir := strings.TrimPrefix(config.ProtoOutputDir, "./")
+
+	return filepath.Join(moduleName, protoDir)
+}
+
+// dbImportPath returns the import path of the sqlc-generated package
+func dbImportPath(config config.Config) string {
+	// Use the module name from config or default to github.com/boomskats/sqlc2proto
+	moduleName := config.ModuleName
+	if moduleName == "" {
+		moduleName = "github.com/boomskats/sqlc2proto"
+	}
+
+	// Remove leading "./" if present in SQLCDir
+	sqlcDir := strings.TrimPrefix(config.SQLCDir, "./")
+
+	return filepath.Join(moduleName, sqlcDir)
+}
+
+// writeFile writes generated content to a file, creating its parent directory
+func writeFile(path string, content []byte) error {
 	// Ensure the parent directory exists
-	if err = os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Create output file
-	f, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
 	}
-	defer f.Close()
 
-	// Execute template
-	if err := tmpl.Execute(f, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+	return nil
+}
+
+// sortedImports returns a sorted, de-duplicated copy of the given imports
+func sortedImports(imports []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, imp := range imports {
+		if !seen[imp] {
+			seen[imp] = true
+			result = append(result, imp)
+		}
 	}
+	sort.Strings(result)
+	return result
+}
 
-	return nil
+// wellKnownImports maps well-known proto types and option names to the file defining them
+var wellKnownImports = map[string]string{
+	"google.protobuf.Timestamp":       "google/protobuf/timestamp.proto",
+	"google.protobuf.FieldMask":       "google/protobuf/field_mask.proto",
+	"google.protobuf.Empty":           "google/protobuf/empty.proto",
+	"google.protobuf.StringValue":     "google/protobuf/wrappers.proto",
+	"google.protobuf.Int32Value":      "google/protobuf/wrappers.proto",
+	"google.protobuf.Int64Value":      "google/protobuf/wrappers.proto",
+	"google.protobuf.DoubleValue":     "google/protobuf/wrappers.proto",
+	"google.protobuf.BoolValue":       "google/protobuf/wrappers.proto",
+	"(google.api.resource)":           "google/api/resource.proto",
+	"(google.api.resource_reference)": "google/api/resource.proto",
+	"(google.api.field_behavior)":     "google/api/field_behavior.proto",
+	"(google.api.http)":               "google/api/annotations.proto",
+}
+
+// protoImports returns the sorted list of imports needed by the given field types and options
+func protoImports(fields []parser.ProtoField, options []string) []string {
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		if file, ok := wellKnownImports[field.Type]; ok {
+			seen[file] = true
+		}
+		options = append(options, field.Options...)
+	}
+	for _, option := range options {
+		for name, file := range wellKnownImports {
+			if strings.HasPrefix(option, name) {
+				seen[file] = true
+			}
+		}
+	}
+
+	imports := make([]string, 0, len(seen))
+	for file := range seen {
+		imports = append(imports, file)
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// fieldOptions renders the bracketed option list of a proto field, including its json_name
+func fieldOptions(field parser.ProtoField) string {
+	var options []string
+	if field.JSONName != "" {
+		options = append(options, fmt.Sprintf("json_name=%q", field.JSONName))
+	}
+	options = append(options, field.Options...)
+	if len(options) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(options, ", ") + "]"
+}
+
+// joinInts renders numbers as a comma-separated list, e.g. for reserved fields
+func joinInts(numbers []int) string {
+	parts := make([]string, len(numbers))
+	for i, n := range numbers {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ", ")
 }
 
 // GenerateMapperFile generates a Go file with conversion functions
-func GenerateMapperFile(messages []parser.ProtoMessage, config common.Config, outputPath string) error {
+func GenerateMapperFile(messages []parser.ProtoMessage, config config.Config, outputPath string) error {
+	file, err := MapperFile(messages, config, outputPath)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(file.Path, file.Content)
+}
+
+// MapperFile renders a Go file with conversion functions in memory
+func MapperFile(messages []parser.ProtoMessage, config config.Config, outputPath string) (File, error) {
+	content, err := renderMapperFile(messages, config, true, true)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Path: outputPath, Content: content}, nil
+}
+
+// renderMapperFile renders a Go file with the helper functions and/or the
+// converters for the given messages
+func renderMapperFile(messages []parser.ProtoMessage, config config.Config, withHelpers bool, withConverters bool) ([]byte, error) {
 	tmpl, err := template.New("mapper").Funcs(template.FuncMap{
 		"camelCase":  strcase.ToLowerCamel,
 		"pascalCase": strcase.ToCamel,
@@ -117,9 +256,12 @@ func GenerateMapperFile(messages []parser.ProtoMessage, config common.Config, ou
 		"replace": func(s, old, new string) string {
 			return strings.ReplaceAll(s, old, new)
 		},
+		"hasStrictFields": hasStrictFields,
+		"sliceName":       parser.SliceName,
+		"fieldPath":       fieldPath,
 	}).Parse(mapperTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
 	// Create template data
@@ -129,15 +271,14 @@ func GenerateMapperFile(messages []parser.ProtoMessage, config common.Config, ou
 		ProtoPackage    string
 		ProtoImport     string
 		DBImport        string
-		HasTimestamp    bool
-		HasPgType       bool
-		HasPgConn       bool
 		HelperFunctions string
+		Strict          bool
+		EmitEmptySlices bool
 	}{
-		Messages:        messages,
 		PackageName:     "mappers", // Use a different package name to avoid circular imports
+		Strict:          config.MapperOptions.Strict,
+		EmitEmptySlices: config.MapperOptions.EmitEmptySlices,
 		ProtoPackage:    config.ProtoPackageName,
-		HelperFunctions: parser.GenerateHelperFunctions(messages),
 		ProtoImport: func() string {
 			// If ProtoGoImport is explicitly set, use it
 			if config.ProtoGoImport != "" {
@@ -156,74 +297,44 @@ func GenerateMapperFile(messages []parser.ProtoMessage, config common.Config, ou
 			// Use a relative import path to go up one directory level
 			return ".."
 		}(),
-		// Use the module name from config or default to github.com/boomskats/sqlc2proto
-		DBImport: func() string {
-			// Get module name
-			moduleName := config.ModuleName
-			if moduleName == "" {
-				moduleName = "github.com/boomskats/sqlc2proto"
-			}
-
-			// Remove leading "./" if present in SQLCDir
-			sqlcDir := strings.TrimPrefix(config.SQLCDir, "./")
-
-			return filepath.Join(moduleName, sqlcDir)
-		}(),
+		DBImport: dbImportPath(config),
 	}
 
-	// Check if any message uses Timestamp, pgtype, or pgconn
-	for _, msg := range messages {
-		for _, field := range msg.Fields {
-			// Check for Timestamp
-			if field.Type == "google.protobuf.Timestamp" {
-				data.HasTimestamp = true
-			}
-
-			// Check for pgtype
-			if strings.HasPrefix(field.OriginalTag, "pgtype.") {
-				data.HasPgType = true
-			}
-
-			// Check for pgconn
-			if strings.HasPrefix(field.OriginalTag, "pgconn.") {
-				data.HasPgConn = true
-			}
-
-			// If we've found all types, we can break early
-			if data.HasTimestamp && data.HasPgType && data.HasPgConn {
-				break
-			}
+	if withHelpers {
+		if data.Strict {
+			data.HelperFunctions = parser.GenerateStrictHelperFunctions(messages)
+		} else {
+			data.HelperFunctions = parser.GenerateHelperFunctions(messages)
 		}
-
-		// If we've found all types, we can break early
-		if data.HasTimestamp && data.HasPgType && data.HasPgConn {
-			break
-		}
-	}
-
-	// Ensure the parent directory exists
-	if err = os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
 	}
-
-	// Create output file
-	f, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+	if withConverters {
+		data.Messages = messages
 	}
-	defer f.Close()
 
 	// Execute template
 	var buf bytes.Buffer
 	if err = tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	// Write to file
-	_, err = f.Write(buf.Bytes())
-	if err != nil {
-		return fmt.Errorf("failed to write to file: %w", err)
+	return formatGoSource(buf.Bytes(), map[string]string{
+		"pb": data.ProtoImport,
+		"db": data.DBImport,
+	})
+}
+
+// hasStrictFields reports whether a message has fields whose conversion from
+// proto can fail in strict mappers
+func hasStrictFields(msg parser.ProtoMessage) bool {
+	for _, field := range msg.Fields {
+		if field.StrictConversionCode != "" && !field.OutputOnly {
+			return true
+		}
 	}
+	return false
+}
 
-	return nil
+// fieldPath returns the path of a field used in strict mapper errors, e.g. book.isbn
+func fieldPath(msg parser.ProtoMessage, field parser.ProtoField) string {
+	return strcase.ToSnake(msg.Name) + "." + field.Name
 }
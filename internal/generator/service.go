@@ -1,14 +1,13 @@
 package generator
 
 import (
+	"bytes"
 	_ "embed"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
 	"text/template"
 
-	"github.com/boomskats/sqlc2proto/cmd/common"
+	"github.com/boomskats/sqlc2proto/internal/config"
 	"github.com/boomskats/sqlc2proto/internal/parser"
 	"github.com/iancoleman/strcase"
 )
@@ -17,8 +16,30 @@ import (
 var serviceTemplate string
 
 // GenerateServiceFile generates a service.proto file based on the configuration
-func GenerateServiceFile(services []parser.ServiceDefinition, config common.Config, outputPath string) error {
+func GenerateServiceFile(services []parser.ServiceDefinition, config config.Config, outputPath string) error {
+	file, err := ServiceFile(services, config, outputPath)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(file.Path, file.Content)
+}
+
+// ServiceFile renders a service.proto file in memory
+func ServiceFile(services []parser.ServiceDefinition, config config.Config, outputPath string) (File, error) {
+	applyServiceOptions(services, config)
+
+	content, err := renderServiceFile(services, config, []string{"models.proto"})
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Path: outputPath, Content: content}, nil
+}
 
+// applyServiceOptions applies the naming, streaming, HTTP and pagination
+// options from the configuration to the service definitions
+func applyServiceOptions(services []parser.ServiceDefinition, config config.Config) {
 	// Apply service naming configuration
 	for i := range services {
 		// Default name from entity (previously set)
@@ -55,20 +76,35 @@ func GenerateServiceFile(services []parser.ServiceDefinition, config common.Conf
 			for j := range services[i].Methods {
 				method := &services[i].Methods[j]
 
-				// Add streaming for list methods
-				if strings.HasPrefix(method.Name, "List") {
+				// Add streaming for list methods (AIP standard List methods stay unary,
+				// and explicitly configured methods keep their streaming mode)
+				if strings.HasPrefix(method.Name, "List") && method.StandardMethod == "" && !method.StreamingSet {
 					method.StreamingServer = true
 				}
 			}
 		}
 
+		// Apply HTTP transcoding and idempotency options
+		for j := range services[i].Methods {
+			method := &services[i].Methods[j]
+
+			if config.ServiceOptions.HTTPAnnotations {
+				method.Options = append(method.Options, parser.DeriveHTTPRule(services[i], *method, httpPathPrefix(config)))
+			}
+
+			// Read methods can be called with HTTP GET by Connect clients
+			if config.ServiceOptions.IdempotentReads && parser.IsReadMethod(*method) && !method.StreamingServer {
+				method.Options = append(method.Options, "idempotency_level = NO_SIDE_EFFECTS")
+			}
+		}
+
 		// Apply pagination options
 		if config.ServiceOptions.IncludePagination {
 			for j := range services[i].Methods {
 				method := &services[i].Methods[j]
 
 				// Add pagination fields to list methods
-				if strings.HasPrefix(method.Name, "List") {
+				if method.Paginated {
 					// Update request field names
 					for k, field := range method.RequestFields {
 						if field.Name == "limit" {
@@ -91,86 +127,69 @@ func GenerateServiceFile(services []parser.ServiceDefinition, config common.Conf
 		}
 	}
 
+}
+
+// renderServiceFile renders a proto file for the given services, importing
+// the given proto files that define the models they use
+func renderServiceFile(services []parser.ServiceDefinition, config config.Config, localImports []string) ([]byte, error) {
 	// Parse the template
 	tmpl, err := template.New("service").Funcs(template.FuncMap{
-		"camelCase":  strcase.ToLowerCamel,
-		"pascalCase": strcase.ToCamel,
-		"snakeCase":  strcase.ToSnake,
+		"camelCase":    strcase.ToLowerCamel,
+		"pascalCase":   strcase.ToCamel,
+		"snakeCase":    strcase.ToSnake,
+		"fieldOptions": fieldOptions,
 	}).Parse(serviceTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to parse service template: %w", err)
+		return nil, fmt.Errorf("failed to parse service template: %w", err)
 	}
 
-	// Check if any service method uses Timestamp
-	hasTimestamp := false
-	for _, service := range services {
-		for _, method := range service.Methods {
-			for _, field := range method.RequestFields {
-				if field.Type == "google.protobuf.Timestamp" {
-					hasTimestamp = true
-					break
-				}
-			}
-			if hasTimestamp {
-				break
-			}
-			for _, field := range method.ResponseFields {
-				if field.Type == "google.protobuf.Timestamp" {
-					hasTimestamp = true
-					break
-				}
-			}
-			if hasTimestamp {
-				break
-			}
-		}
-		if hasTimestamp {
-			break
-		}
-	}
+	// Collect the imports needed by request and response messages
+	fields, options := serviceFields(services)
+	imports := append(sortedImports(localImports), protoImports(fields, options)...)
 
 	// Create template data
 	data := struct {
-		Services       []parser.ServiceDefinition
-		PackageName    string
-		GoPackagePath  string
-		ModelsProtoRef string
-		HasTimestamp   bool
+		Services      []parser.ServiceDefinition
+		PackageName   string
+		GoPackagePath string
+		Imports       []string
 	}{
 		Services:      services,
 		PackageName:   config.ProtoPackageName,
 		GoPackagePath: config.GoPackagePath,
-		ModelsProtoRef: func() string {
-			// For buf compatibility, we need to use a path that works with buf's import resolution
-			// Buf typically looks for imports relative to the root of the buf module
-
-			// Use the proto output directory directly, just ensure it's in the correct format
-			// by removing any leading "./" and using forward slashes
-			// protoDir := strings.TrimPrefix(config.ProtoOutputDir, "./")
-			protoDir := ""
-
-			// Join with models.proto to get the full import path
-			return filepath.Join(protoDir, "models.proto")
-		}(),
-		HasTimestamp: hasTimestamp,
+		Imports:       imports,
 	}
 
-	// Ensure the parent directory exists
-	if err = os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+	// Execute template
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	// Create output file
-	f, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer f.Close()
+	return buf.Bytes(), nil
+}
 
-	// Execute template
-	if err := tmpl.Execute(f, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+// serviceFields returns the fields, including the request and response
+// types, and the options used by the methods of the given services
+func serviceFields(services []parser.ServiceDefinition) ([]parser.ProtoField, []string) {
+	var fields []parser.ProtoField
+	var options []string
+	for _, service := range services {
+		for _, method := range service.Methods {
+			fields = append(fields, method.RequestFields...)
+			fields = append(fields, method.ResponseFields...)
+			fields = append(fields, parser.ProtoField{Type: method.RequestType}, parser.ProtoField{Type: method.ResponseType})
+			options = append(options, method.Options...)
+		}
 	}
+	return fields, options
+}
 
-	return nil
+// httpPathPrefix returns the configured HTTP path prefix, derived from the
+// proto package version if not set
+func httpPathPrefix(config config.Config) string {
+	if config.ServiceOptions.HTTPPathPrefix != "" {
+		return config.ServiceOptions.HTTPPathPrefix
+	}
+	return parser.DefaultHTTPPathPrefix(config.ProtoPackageName)
 }
@@ -0,0 +1,181 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/parser"
+	"github.com/iancoleman/strcase"
+)
+
+// File is a generated file rendered in memory
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// GeneratedHeader is the first line of every file sqlc2proto generates
+const GeneratedHeader = "// Code generated by sqlc2proto; DO NOT EDIT."
+
+// IsGenerated reports whether content was generated by sqlc2proto, so that
+// hand-written files are never replaced or removed
+func IsGenerated(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(GeneratedHeader+"\n"))
+}
+
+// WriteFiles writes rendered files to disk
+func WriteFiles(files []File) error {
+	for _, file := range files {
+		if err := writeFile(file.Path, file.Content); err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// ModelFiles maps each message name to the proto file that defines it. With
+// the split layout every entity gets its own file (e.g. Book -> book.proto),
+// otherwise all messages live in models.proto.
+func ModelFiles(messages []parser.ProtoMessage, split bool) map[string]string {
+	files := make(map[string]string)
+	if !split {
+		for _, msg := range messages {
+			files[msg.Name] = "models.proto"
+		}
+		return files
+	}
+
+	for _, group := range parser.GroupMessagesByEntity(messages) {
+		for _, msg := range group.Messages {
+			files[msg.Name] = entityFileName(group.Entity, ".proto")
+		}
+	}
+	return files
+}
+
+// RenderSplitProtoFiles renders one models proto file per entity, importing
+// the files of other entities referenced by its fields
+func RenderSplitProtoFiles(messages []parser.ProtoMessage, config config.Config, outputDir string) ([]File, error) {
+	modelFiles := ModelFiles(messages, true)
+
+	var files []File
+	for _, group := range parser.GroupMessagesByEntity(messages) {
+		fileName := entityFileName(group.Entity, ".proto")
+
+		var fields []parser.ProtoField
+		for _, msg := range group.Messages {
+			fields = append(fields, msg.Fields...)
+		}
+
+		content, err := renderProtoFile(group.Messages, config, localImports(fields, modelFiles, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		files = append(files, File{Path: filepath.Join(outputDir, fileName), Content: content})
+	}
+
+	return files, nil
+}
+
+// RenderSplitServiceFiles renders one proto file per service (e.g.
+// book_service.proto), importing the model files its messages reference
+func RenderSplitServiceFiles(services []parser.ServiceDefinition, modelFiles map[string]string, config config.Config, outputDir string) ([]File, error) {
+	applyServiceOptions(services, config)
+
+	var files []File
+	for _, service := range services {
+		fileName := entityFileName(service.Name, ".proto")
+		serviceGroup := []parser.ServiceDefinition{service}
+
+		fields, _ := serviceFields(serviceGroup)
+		content, err := renderServiceFile(serviceGroup, config, localImports(fields, modelFiles, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		files = append(files, File{Path: filepath.Join(outputDir, fileName), Content: content})
+	}
+
+	return files, nil
+}
+
+// RenderSplitMapperFiles renders the shared helper functions to helpers.go
+// and the converters of each entity to their own file (e.g. book.go)
+func RenderSplitMapperFiles(messages []parser.ProtoMessage, config config.Config, mappersDir string) ([]File, error) {
+	helpers, err := renderMapperFile(messages, config, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("helpers.go: %w", err)
+	}
+	files := []File{{Path: filepath.Join(mappersDir, "helpers.go"), Content: helpers}}
+
+	for _, group := range parser.GroupMessagesByEntity(messages) {
+		fileName := mapperFileName(group.Entity)
+		content, err := renderMapperFile(group.Messages, config, false, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		files = append(files, File{Path: filepath.Join(mappersDir, fileName), Content: content})
+	}
+
+	return files, nil
+}
+
+// SplitProtoPaths returns the paths of the files RenderSplitProtoFiles
+// renders
+func SplitProtoPaths(messages []parser.ProtoMessage, outputDir string) []string {
+	var paths []string
+	for _, group := range parser.GroupMessagesByEntity(messages) {
+		paths = append(paths, filepath.Join(outputDir, entityFileName(group.Entity, ".proto")))
+	}
+	return paths
+}
+
+// SplitServicePaths returns the paths of the files RenderSplitServiceFiles
+// renders
+func SplitServicePaths(services []parser.ServiceDefinition, outputDir string) []string {
+	var paths []string
+	for _, service := range services {
+		paths = append(paths, filepath.Join(outputDir, entityFileName(service.Name, ".proto")))
+	}
+	return paths
+}
+
+// SplitMapperPaths returns the paths of the files RenderSplitMapperFiles
+// renders
+func SplitMapperPaths(messages []parser.ProtoMessage, mappersDir string) []string {
+	paths := []string{filepath.Join(mappersDir, "helpers.go")}
+	for _, group := range parser.GroupMessagesByEntity(messages) {
+		paths = append(paths, filepath.Join(mappersDir, mapperFileName(group.Entity)))
+	}
+	return paths
+}
+
+// mapperFileName returns the mapper file of an entity, keeping clear of
+// the shared helpers.go
+func mapperFileName(entity string) string {
+	fileName := entityFileName(entity, ".go")
+	if fileName == "helpers.go" {
+		return "helpers_mapper.go"
+	}
+	return fileName
+}
+
+// entityFileName returns the file name for an entity, e.g. BookService -> book_service.proto
+func entityFileName(entity, ext string) string {
+	return strcase.ToSnake(entity) + ext
+}
+
+// localImports returns the model files defining the message types used by
+// the given fields, excluding the file being generated
+func localImports(fields []parser.ProtoField, modelFiles map[string]string, self string) []string {
+	var imports []string
+	for _, field := range fields {
+		typeName := strings.TrimPrefix(field.Type, "repeated ")
+		if file, ok := modelFiles[typeName]; ok && file != self {
+			imports = append(imports, file)
+		}
+	}
+	return sortedImports(imports)
+}
@@ -0,0 +1,193 @@
+package graph
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+// ForeignKey is a foreign key constraint of the SQL schema
+type ForeignKey struct {
+	Table      string   // Table with the constraint, e.g. "loans"
+	Columns    []string // Referencing columns, e.g. ["book_id"]
+	RefTable   string   // Referenced table, e.g. "books"
+	RefColumns []string // Referenced columns, empty for the primary key
+}
+
+// String describes the foreign key, e.g. "loans(book_id) → books(id)"
+func (k ForeignKey) String() string {
+	return fmt.Sprintf("%s(%s) → %s(%s)", k.Table, strings.Join(k.Columns, ", "), k.RefTable, strings.Join(k.RefColumns, ", "))
+}
+
+var (
+	// commentPattern matches SQL comments
+	commentPattern = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/`)
+
+	// createTablePattern matches the start of a CREATE TABLE statement
+	createTablePattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)\s*\((.*)\)`)
+
+	// alterTablePattern matches an ALTER TABLE statement adding a foreign key
+	alterTablePattern = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)\s+ADD\s+(.*)$`)
+
+	// tableConstraintPattern matches a FOREIGN KEY table constraint
+	tableConstraintPattern = regexp.MustCompile(`(?is)^(?:CONSTRAINT\s+[\w"]+\s+)?FOREIGN\s+KEY\s*\(([^)]*)\)\s*REFERENCES\s+([\w."]+)\s*(?:\(([^)]*)\))?`)
+
+	// columnReferencePattern matches a REFERENCES column constraint
+	columnReferencePattern = regexp.MustCompile(`(?is)^([\w"]+)\s.*?\bREFERENCES\s+([\w."]+)\s*(?:\(([^)]*)\))?`)
+)
+
+// ParseForeignKeys returns the foreign keys declared in SQL statements, in
+// CREATE TABLE statements or added by ALTER TABLE
+func ParseForeignKeys(sql string) []ForeignKey {
+	sql = commentPattern.ReplaceAllString(sql, "")
+
+	var keys []ForeignKey
+	for _, statement := range strings.Split(sql, ";") {
+		if m := createTablePattern.FindStringSubmatch(statement); m != nil {
+			table := unquote(m[1])
+			for _, definition := range splitDefinitions(m[2]) {
+				if key, ok := tableConstraint(table, definition); ok {
+					keys = append(keys, key)
+				} else if m := columnReferencePattern.FindStringSubmatch(definition); m != nil && !isConstraint(m[1]) {
+					keys = append(keys, ForeignKey{
+						Table:      table,
+						Columns:    []string{unquote(m[1])},
+						RefTable:   unquote(m[2]),
+						RefColumns: splitColumns(m[3]),
+					})
+				}
+			}
+			continue
+		}
+		if m := alterTablePattern.FindStringSubmatch(statement); m != nil {
+			if key, ok := tableConstraint(unquote(m[1]), strings.TrimSpace(m[2])); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// LoadForeignKeys reads the foreign keys of schema files, or of the .sql
+// files in schema directories, such as the schema paths of sqlc.yaml
+func LoadForeignKeys(paths []string) ([]ForeignKey, error) {
+	var keys []ForeignKey
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{p}
+		if info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(p, "*.sql")); err != nil {
+				return nil, err
+			}
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, ParseForeignKeys(string(data))...)
+		}
+	}
+	return keys, nil
+}
+
+// AddForeignKeys adds the foreign keys between the models of their tables.
+// Tables are matched to models the way sqlc names them, e.g. "book_loans"
+// to BookLoan. Keys of tables without a model are skipped.
+func (g *Graph) AddForeignKeys(keys []ForeignKey) {
+	models := make(map[string]Node)
+	for _, node := range g.nodes {
+		if node.Kind == Model {
+			models[strings.ToLower(node.Name)] = node
+			models[strings.ToLower(parser.Pluralize(node.Name))] = node
+		}
+	}
+	model := func(table string) (Node, bool) {
+		// Drop the schema, e.g. "public.books"
+		table = table[strings.LastIndex(table, ".")+1:]
+		node, ok := models[strings.ToLower(strings.ReplaceAll(table, "_", ""))]
+		return node, ok
+	}
+
+	for _, key := range keys {
+		from, ok := model(key.Table)
+		if !ok {
+			continue
+		}
+		to, ok := model(key.RefTable)
+		if !ok || from == to {
+			continue
+		}
+		g.addEdge(Edge{From: from, To: to, Label: "fk " + strings.Join(key.Columns, ", ")})
+	}
+}
+
+// tableConstraint parses a FOREIGN KEY table constraint
+func tableConstraint(table, definition string) (ForeignKey, bool) {
+	m := tableConstraintPattern.FindStringSubmatch(definition)
+	if m == nil {
+		return ForeignKey{}, false
+	}
+	return ForeignKey{
+		Table:      table,
+		Columns:    splitColumns(m[1]),
+		RefTable:   unquote(m[2]),
+		RefColumns: splitColumns(m[3]),
+	}, true
+}
+
+// splitDefinitions splits the body of a CREATE TABLE statement into its
+// column and constraint definitions, at the commas outside of parentheses
+func splitDefinitions(body string) []string {
+	var definitions []string
+	depth, start := 0, 0
+	for i, r := range body {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(definitions, strings.TrimSpace(body[start:]))
+}
+
+// splitColumns splits a list of column names, e.g. `book_id, "member_id"`
+func splitColumns(list string) []string {
+	var columns []string
+	for _, column := range strings.Split(list, ",") {
+		if column = unquote(strings.TrimSpace(column)); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// isConstraint reports whether the first word of a definition starts a
+// table constraint rather than a column
+func isConstraint(word string) bool {
+	switch strings.ToUpper(word) {
+	case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "EXCLUDE":
+		return true
+	}
+	return false
+}
+
+// unquote removes the double quotes of SQL identifiers
+func unquote(name string) string {
+	return strings.ReplaceAll(name, `"`, "")
+}
@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+func TestParseForeignKeys(t *testing.T) {
+	sql := `-- Library schema
+CREATE TABLE books (
+  id SERIAL PRIMARY KEY,
+  title TEXT NOT NULL, -- REFERENCES in a comment
+  price NUMERIC(10, 2)
+);
+
+CREATE TABLE IF NOT EXISTS public.loans (
+  id SERIAL PRIMARY KEY,
+  book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+  "member_id" INT NOT NULL,
+  CONSTRAINT loans_member_fk FOREIGN KEY ("member_id") REFERENCES members
+);
+
+ALTER TABLE ONLY book_loans ADD CONSTRAINT book_loans_fk FOREIGN KEY (book_id, copy) REFERENCES book_copies (book_id, copy);
+`
+
+	want := []ForeignKey{
+		{Table: "public.loans", Columns: []string{"book_id"}, RefTable: "books", RefColumns: []string{"id"}},
+		{Table: "public.loans", Columns: []string{"member_id"}, RefTable: "members"},
+		{Table: "book_loans", Columns: []string{"book_id", "copy"}, RefTable: "book_copies", RefColumns: []string{"book_id", "copy"}},
+	}
+	got := ParseForeignKeys(sql)
+	if len(got) != len(want) {
+		t.Fatalf("ParseForeignKeys() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i].String() {
+			t.Errorf("Key %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAddForeignKeys(t *testing.T) {
+	g := New([]parser.ProtoMessage{
+		{Name: "Book", SQLCStruct: "Book"},
+		{Name: "BookCategory", SQLCStruct: "BookCategory"},
+		{Name: "Loan", SQLCStruct: "Loan"},
+	}, nil)
+	g.AddForeignKeys([]ForeignKey{
+		{Table: "public.loans", Columns: []string{"book_id"}, RefTable: "books"},
+		{Table: "book_categories", Columns: []string{"book_id"}, RefTable: "book"},
+		{Table: "loans", Columns: []string{"member_id"}, RefTable: "members"}, // No model
+	})
+
+	want := []Edge{
+		{From: Node{Model, "BookCategory"}, To: Node{Model, "Book"}, Label: "fk book_id"},
+		{From: Node{Model, "Loan"}, To: Node{Model, "Book"}, Label: "fk book_id"},
+	}
+	if got := g.Edges(); !slices.Equal(got, want) {
+		t.Errorf("Edges() = %v, want %v", got, want)
+	}
+}
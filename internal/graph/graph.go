@@ -0,0 +1,269 @@
+// Package graph builds the dependency graph between the sqlc models, the
+// parameter and result structs of the queries, the queries and the services
+// generated from them.
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+// Kind is the kind of a node in the graph
+type Kind string
+
+const (
+	Model   Kind = "model"   // sqlc model, e.g. of a table
+	Params  Kind = "params"  // Parameter struct of a query, e.g. CreateBookParams
+	Row     Kind = "row"     // Result struct of a query, e.g. ListBooksByAuthorRow
+	Query   Kind = "query"   // Method of the sqlc Querier interface
+	Service Kind = "service" // Generated proto service
+	Message Kind = "message" // Request or response message of a service method
+)
+
+// Node is a message, query or service
+type Node struct {
+	Kind Kind
+	Name string
+}
+
+// String returns the kind and name of the node, e.g. "query GetBook"
+func (n Node) String() string {
+	return string(n.Kind) + " " + n.Name
+}
+
+// IsMessage reports whether the node is a sqlc struct, which is generated
+// as a proto message
+func (n Node) IsMessage() bool {
+	return n.Kind == Model || n.Kind == Params || n.Kind == Row
+}
+
+// Edge records that From depends on To
+type Edge struct {
+	From  Node
+	To    Node
+	Label string // How From uses To, e.g. "params", "returns" or "field author"
+}
+
+// Graph is a directed graph of the dependencies between messages, queries
+// and services. Nodes and edges keep the order they were added in.
+type Graph struct {
+	nodes    []Node
+	messages map[string]Node // Message nodes by sqlc struct name
+	known    map[Node]bool
+	out      map[Node][]Edge
+	in       map[Node][]Edge
+}
+
+// New builds the graph of the messages and the queries using them. Queries
+// depend on their parameter and result structs, and messages depend on the
+// messages of their nested struct fields.
+func New(messages []parser.ProtoMessage, queries []parser.QueryMethod) *Graph {
+	g := newGraph()
+	for _, msg := range messages {
+		node := MessageNode(msg.Name)
+		g.messages[structName(msg)] = node
+		g.addNode(node)
+	}
+
+	// Nested structs, e.g. of sqlc.embed, are mapped to strings in the proto
+	// message, so their Go type tells which message they refer to
+	for _, msg := range messages {
+		from := g.messages[structName(msg)]
+		for _, field := range msg.Fields {
+			if to, ok := g.message(field.GoType); ok {
+				g.addEdge(Edge{From: from, To: to, Label: "field " + field.Name})
+			}
+		}
+	}
+
+	for _, method := range queries {
+		from := Node{Kind: Query, Name: method.Name}
+		g.addNode(from)
+		for _, param := range method.ParamTypes {
+			if to, ok := g.message(param.Type); ok {
+				g.addEdge(Edge{From: from, To: to, Label: "params"})
+			}
+		}
+		if to, ok := g.message(method.ReturnType); ok {
+			g.addEdge(Edge{From: from, To: to, Label: "returns"})
+		}
+	}
+	return g
+}
+
+// newGraph returns an empty graph
+func newGraph() *Graph {
+	return &Graph{
+		messages: make(map[string]Node),
+		known:    make(map[Node]bool),
+		out:      make(map[Node][]Edge),
+		in:       make(map[Node][]Edge),
+	}
+}
+
+// AddServices adds the services, their request and response messages and
+// their dependencies on the queries of their methods and on the messages of
+// their resources
+func (g *Graph) AddServices(services []parser.ServiceDefinition) {
+	for _, service := range services {
+		from := Node{Kind: Service, Name: service.Name}
+		g.addNode(from)
+		if service.Resource != nil {
+			if to, ok := g.message(service.Resource.Message); ok {
+				g.addEdge(Edge{From: from, To: to, Label: "resource"})
+			}
+		}
+		for _, method := range service.Methods {
+			if method.OriginalQuery != nil {
+				to := Node{Kind: Query, Name: method.OriginalQuery.Name}
+				if g.known[to] {
+					g.addEdge(Edge{From: from, To: to, Label: "rpc " + method.Name})
+				}
+			}
+			g.addMethodMessage(from, method.RequestType, method.RequestFields, method.OmitRequestMessage, method.Name+" request")
+			g.addMethodMessage(from, method.ResponseType, method.ResponseFields, method.OmitResponseMessage, method.Name+" response")
+		}
+	}
+}
+
+// addMethodMessage adds the request or response message of a method. Models
+// used as the message directly are linked to the service, and well-known
+// types are skipped.
+func (g *Graph) addMethodMessage(service Node, name string, fields []parser.ProtoField, omitted bool, label string) {
+	if to, ok := g.message(name); ok {
+		g.addEdge(Edge{From: service, To: to, Label: label})
+		return
+	}
+	if name == "" || omitted {
+		return
+	}
+
+	node := Node{Kind: Message, Name: name}
+	g.addNode(node)
+	g.addEdge(Edge{From: service, To: node, Label: label})
+	for _, field := range fields {
+		if to, ok := g.message(field.Type); ok {
+			g.addEdge(Edge{From: node, To: to, Label: "field " + field.Name})
+		}
+	}
+}
+
+// MessageNode returns the node of a message, whose kind follows from the
+// sqlc naming of parameter and result structs
+func MessageNode(name string) Node {
+	switch {
+	case strings.HasSuffix(name, "Params"):
+		return Node{Kind: Params, Name: name}
+	case strings.HasSuffix(name, "Row"):
+		return Node{Kind: Row, Name: name}
+	default:
+		return Node{Kind: Model, Name: name}
+	}
+}
+
+// Nodes returns the nodes of the graph
+func (g *Graph) Nodes() []Node {
+	return g.nodes
+}
+
+// Edges returns the edges of the graph, grouped by the node they start at
+func (g *Graph) Edges() []Edge {
+	var edges []Edge
+	for _, node := range g.nodes {
+		edges = append(edges, g.out[node]...)
+	}
+	return edges
+}
+
+// Dependencies returns the edges to the nodes a node depends on
+func (g *Graph) Dependencies(node Node) []Edge {
+	return g.out[node]
+}
+
+// Dependents returns the edges from the nodes depending on a node
+func (g *Graph) Dependents(node Node) []Edge {
+	return g.in[node]
+}
+
+// Walk visits the nodes reachable from the roots breadth first, so each node
+// is visited once with the shortest path leading to it. The path of a root
+// is empty. Returning false from visit stops the walk from following the
+// dependencies of that node.
+func (g *Graph) Walk(roots []Node, visit func(node Node, path []Edge) bool) {
+	type item struct {
+		node Node
+		path []Edge
+	}
+
+	seen := make(map[Node]bool)
+	var queue []item
+	for _, root := range roots {
+		if g.known[root] && !seen[root] {
+			seen[root] = true
+			queue = append(queue, item{node: root})
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if !visit(current.node, current.path) {
+			continue
+		}
+		for _, edge := range g.out[current.node] {
+			if seen[edge.To] {
+				continue
+			}
+			seen[edge.To] = true
+			path := append(append([]Edge(nil), current.path...), edge)
+			queue = append(queue, item{node: edge.To, path: path})
+		}
+	}
+}
+
+// Explain describes a path, e.g. "query ListLoans → ListLoansRow (returns)
+// → Book (field book)"
+func Explain(path []Edge) string {
+	if len(path) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(path[0].From.String())
+	for _, edge := range path {
+		fmt.Fprintf(&b, " → %s (%s)", edge.To.Name, edge.Label)
+	}
+	return b.String()
+}
+
+// message returns the message node of a Go type, e.g. "[]Book"
+func (g *Graph) message(goType string) (Node, bool) {
+	node, ok := g.messages[strings.TrimPrefix(goType, "[]")]
+	return node, ok
+}
+
+func (g *Graph) addNode(node Node) {
+	if !g.known[node] {
+		g.known[node] = true
+		g.nodes = append(g.nodes, node)
+	}
+}
+
+func (g *Graph) addEdge(edge Edge) {
+	for _, existing := range g.out[edge.From] {
+		if existing.To == edge.To {
+			return // Keep the first reason, e.g. of several params
+		}
+	}
+	g.out[edge.From] = append(g.out[edge.From], edge)
+	g.in[edge.To] = append(g.in[edge.To], edge)
+}
+
+// structName returns the name of the sqlc struct of a message
+func structName(msg parser.ProtoMessage) string {
+	if msg.SQLCStruct != "" {
+		return msg.SQLCStruct
+	}
+	return msg.Name
+}
@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+// library returns messages and queries where a row embeds a model, which
+// embeds another one in turn
+func library() ([]parser.ProtoMessage, []parser.QueryMethod) {
+	messages := []parser.ProtoMessage{
+		{Name: "Author", SQLCStruct: "Author", Fields: []parser.ProtoField{{Name: "id", GoType: "int32"}}},
+		{Name: "Book", SQLCStruct: "Book", Fields: []parser.ProtoField{
+			{Name: "id", GoType: "int32"},
+			{Name: "authors", GoType: "[]Author"},
+		}},
+		{Name: "GetLoanRow", SQLCStruct: "GetLoanRow", Fields: []parser.ProtoField{
+			{Name: "id", GoType: "int32"},
+			{Name: "book", GoType: "Book"},
+		}},
+		{Name: "CreateBookParams", SQLCStruct: "CreateBookParams", Fields: []parser.ProtoField{{Name: "title", GoType: "string"}}},
+	}
+	queries := []parser.QueryMethod{
+		{Name: "GetLoan", ParamTypes: []parser.ParamType{{Name: "id", Type: "int32"}}, ReturnType: "GetLoanRow"},
+		{Name: "CreateBook", ParamTypes: []parser.ParamType{{Name: "arg", Type: "CreateBookParams"}}, ReturnType: "Book"},
+	}
+	return messages, queries
+}
+
+func TestNew(t *testing.T) {
+	g := New(library())
+
+	want := []Edge{
+		{From: Node{Model, "Book"}, To: Node{Model, "Author"}, Label: "field authors"},
+		{From: Node{Row, "GetLoanRow"}, To: Node{Model, "Book"}, Label: "field book"},
+		{From: Node{Query, "GetLoan"}, To: Node{Row, "GetLoanRow"}, Label: "returns"},
+		{From: Node{Query, "CreateBook"}, To: Node{Params, "CreateBookParams"}, Label: "params"},
+		{From: Node{Query, "CreateBook"}, To: Node{Model, "Book"}, Label: "returns"},
+	}
+	if got := g.Edges(); !slices.Equal(got, want) {
+		t.Errorf("Edges() = %v, want %v", got, want)
+	}
+
+	var dependents []string
+	for _, edge := range g.Dependents(Node{Model, "Book"}) {
+		dependents = append(dependents, edge.From.String())
+	}
+	if want := []string{"row GetLoanRow", "query CreateBook"}; !slices.Equal(dependents, want) {
+		t.Errorf("Dependents(Book) = %v, want %v", dependents, want)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	g := New(library())
+
+	paths := make(map[string]string)
+	g.Walk([]Node{{Query, "GetLoan"}}, func(node Node, path []Edge) bool {
+		paths[node.Name] = Explain(path)
+		return node.Name != "Book"
+	})
+
+	want := map[string]string{
+		"GetLoan":    "",
+		"GetLoanRow": "query GetLoan → GetLoanRow (returns)",
+		"Book":       "query GetLoan → GetLoanRow (returns) → Book (field book)",
+	}
+	if len(paths) != len(want) {
+		t.Errorf("Walk visited %v, want %v", paths, want)
+	}
+	for name, path := range want {
+		if paths[name] != path {
+			t.Errorf("Path to %s = %q, want %q", name, paths[name], path)
+		}
+	}
+}
+
+func TestAddServices(t *testing.T) {
+	messages, queries := library()
+	g := New(messages, queries)
+	g.AddServices([]parser.ServiceDefinition{{
+		Name:     "BookService",
+		Resource: &parser.ResourceDefinition{Message: "Book"},
+		Methods: []parser.ServiceMethod{{
+			Name:                "CreateBook",
+			OriginalQuery:       &queries[1],
+			RequestType:         "CreateBookRequest",
+			RequestFields:       []parser.ProtoField{{Name: "title", Type: "string"}, {Name: "author", Type: "Author"}},
+			ResponseType:        "Book",
+			OmitResponseMessage: true,
+		}},
+	}})
+
+	want := []Edge{
+		{From: Node{Service, "BookService"}, To: Node{Model, "Book"}, Label: "resource"},
+		{From: Node{Service, "BookService"}, To: Node{Query, "CreateBook"}, Label: "rpc CreateBook"},
+		{From: Node{Service, "BookService"}, To: Node{Message, "CreateBookRequest"}, Label: "CreateBook request"},
+	}
+	if got := g.Dependencies(Node{Service, "BookService"}); !slices.Equal(got, want) {
+		t.Errorf("Dependencies(BookService) = %v, want %v", got, want)
+	}
+
+	want = []Edge{{From: Node{Message, "CreateBookRequest"}, To: Node{Model, "Author"}, Label: "field author"}}
+	if got := g.Dependencies(Node{Message, "CreateBookRequest"}); !slices.Equal(got, want) {
+		t.Errorf("Dependencies(CreateBookRequest) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// dotShapes are the DOT node attributes of each kind
+var dotShapes = map[Kind]string{
+	Model:   `shape=box`,
+	Params:  `shape=box, style=dashed`,
+	Row:     `shape=box, style=rounded`,
+	Query:   `shape=ellipse`,
+	Service: `shape=component`,
+	Message: `shape=note`,
+}
+
+// mermaidShapes are the Mermaid node shapes of each kind, as the brackets
+// around the label
+var mermaidShapes = map[Kind][2]string{
+	Model:   {"[", "]"},
+	Params:  {"[/", "/]"},
+	Row:     {"(", ")"},
+	Query:   {"([", "])"},
+	Service: {"{{", "}}"},
+	Message: {">", "]"},
+}
+
+// mermaidIDPattern matches the characters that can't be used in Mermaid
+// node IDs
+var mermaidIDPattern = regexp.MustCompile(`\W`)
+
+// Find returns the nodes with a name, e.g. a query and the service method
+// messages named after it
+func (g *Graph) Find(name string) []Node {
+	var nodes []Node
+	for _, node := range g.nodes {
+		if node.Name == name {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Focus returns the subgraph of the nodes a node depends on and the nodes
+// depending on it, transitively, e.g. to see why a model is generated
+func (g *Graph) Focus(focus Node) *Graph {
+	keep := make(map[Node]bool)
+	g.Walk([]Node{focus}, func(node Node, path []Edge) bool {
+		keep[node] = true
+		return true
+	})
+	queue := []Node{focus}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, edge := range g.in[node] {
+			if !keep[edge.From] {
+				keep[edge.From] = true
+				queue = append(queue, edge.From)
+			}
+		}
+	}
+
+	sub := newGraph()
+	for name, node := range g.messages {
+		if keep[node] {
+			sub.messages[name] = node
+		}
+	}
+	for _, node := range g.nodes {
+		if keep[node] {
+			sub.addNode(node)
+		}
+	}
+	for _, edge := range g.Edges() {
+		if keep[edge.From] && keep[edge.To] {
+			sub.addEdge(edge)
+		}
+	}
+	return sub
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language
+func WriteDOT(w io.Writer, g *Graph) error {
+	var b bytes.Buffer
+	b.WriteString("digraph sqlc2proto {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, node := range g.nodes {
+		fmt.Fprintf(&b, "  %q [label=%q, %s];\n", node.String(), node.Name, dotShapes[node.Kind])
+	}
+	for _, edge := range g.Edges() {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", edge.From.String(), edge.To.String(), edge.Label)
+	}
+	b.WriteString("}\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart
+func WriteMermaid(w io.Writer, g *Graph) error {
+	var b bytes.Buffer
+	b.WriteString("flowchart LR\n")
+	for _, node := range g.nodes {
+		shape := mermaidShapes[node.Kind]
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s\n", mermaidID(node), shape[0], node.Name, shape[1])
+	}
+	for _, edge := range g.Edges() {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", mermaidID(edge.From), edge.Label, mermaidID(edge.To))
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// mermaidID returns the Mermaid ID of a node, e.g. "query_GetBook"
+func mermaidID(node Node) string {
+	return string(node.Kind) + "_" + mermaidIDPattern.ReplaceAllString(node.Name, "_")
+}
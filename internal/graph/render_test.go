@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteDOT(&b, New(library()).Focus(Node{Row, "GetLoanRow"})); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `digraph sqlc2proto {
+  rankdir=LR;
+  "model Author" [label="Author", shape=box];
+  "model Book" [label="Book", shape=box];
+  "row GetLoanRow" [label="GetLoanRow", shape=box, style=rounded];
+  "query GetLoan" [label="GetLoan", shape=ellipse];
+  "model Book" -> "model Author" [label="field authors"];
+  "row GetLoanRow" -> "model Book" [label="field book"];
+  "query GetLoan" -> "row GetLoanRow" [label="returns"];
+}
+`
+	if b.String() != want {
+		t.Errorf("WriteDOT() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteMermaid(&b, New(library()).Focus(Node{Params, "CreateBookParams"})); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `flowchart LR
+  params_CreateBookParams[/"CreateBookParams"/]
+  query_CreateBook(["CreateBook"])
+  query_CreateBook -->|params| params_CreateBookParams
+`
+	if b.String() != want {
+		t.Errorf("WriteMermaid() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	g := New(library())
+	if nodes := g.Find("GetLoan"); len(nodes) != 1 || nodes[0] != (Node{Query, "GetLoan"}) {
+		t.Errorf("Find(GetLoan) = %v", nodes)
+	}
+	if nodes := g.Find("Missing"); len(nodes) != 0 {
+		t.Errorf("Find(Missing) = %v, want none", nodes)
+	}
+}
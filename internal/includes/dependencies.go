@@ -1,103 +1,101 @@
 package includes
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/boomskats/sqlc2proto/internal/graph"
 	"github.com/boomskats/sqlc2proto/internal/parser"
 )
 
-// ResolveDependencies resolves dependencies for the included queries
-func ResolveDependencies(includes IncludesFile, queryMethods []parser.QueryMethod, messages []parser.ProtoMessage) IncludesFile {
-	// Create a map of all messages for quick lookup
-	messageMap := make(map[string]parser.ProtoMessage)
-	for _, msg := range messages {
-		messageMap[msg.Name] = msg
-	}
+// Dependency explains why a model the includes file doesn't select is
+// generated
+type Dependency struct {
+	Model string
+	Path  []graph.Edge // From the selected query or model needing it
+}
 
-	// Create a set of included models
-	includedModels := make(map[string]bool)
-	for _, model := range includes.Models {
-		includedModels[model] = true
-	}
+// String describes the dependency, e.g. "Author: query GetBook →
+// GetBookRow (returns) → Author (field author)"
+func (d Dependency) String() string {
+	return d.Model + ": " + graph.Explain(d.Path)
+}
 
-	// For each included query, add its dependencies
-	for _, queryName := range includes.Queries {
-		// Find the query method
-		for _, method := range queryMethods {
-			if method.Name == queryName {
-				// Add parameter types as dependencies
-				for _, param := range method.ParamTypes {
-					addModelAndDependencies(param.Type, includedModels, messageMap)
-				}
+// ResolveDependencies resolves the dependencies of the selected queries and
+// models transitively, through their parameter and result structs and the
+// nested structs of their fields. The returned file lists the included
+// models by name, followed by the models that were added and why. Selected
+// queries or models needing an excluded model are an error.
+func ResolveDependencies(includes IncludesFile, queryMethods []parser.QueryMethod, messages []parser.ProtoMessage) (IncludesFile, []Dependency, error) {
+	g := graph.New(messages, queryMethods)
 
-				// Add return type as dependency
-				if method.ReturnType != "" {
-					addModelAndDependencies(method.ReturnType, includedModels, messageMap)
-				}
+	// Selected queries come first, so dependencies are explained by the
+	// query needing them where possible
+	var roots []graph.Node
+	for _, method := range queryMethods {
+		if IsQueryIncluded(includes, method.Name) {
+			roots = append(roots, graph.Node{Kind: graph.Query, Name: method.Name})
+		}
+	}
+	included := make(map[string]bool)
+	for _, msg := range messages {
+		if IsModelIncluded(includes, msg.Name) {
+			included[msg.Name] = true
+			roots = append(roots, graph.MessageNode(msg.Name))
+		}
+	}
 
-				break
+	// Walk from each root on its own, to report every query that an
+	// exclusion breaks
+	var dependencies []Dependency
+	var errs []error
+	for _, root := range roots {
+		g.Walk([]graph.Node{root}, func(node graph.Node, path []graph.Edge) bool {
+			if !node.IsMessage() || len(path) == 0 {
+				return true
 			}
-		}
+			if entry, ok := excludedBy(includes, node.Name); ok {
+				errs = append(errs, fmt.Errorf("%s needs %s, which is excluded by exclude.models entry %s: %s",
+					root, node.Name, entry, graph.Explain(path)))
+				return false
+			}
+			if !included[node.Name] {
+				included[node.Name] = true
+				dependencies = append(dependencies, Dependency{Model: node.Name, Path: path})
+			}
+			return true
+		})
+	}
+	if len(errs) > 0 {
+		return IncludesFile{}, nil, errors.Join(errs...)
 	}
 
-	// Convert the set back to a slice
+	// Convert the set back to a sorted slice
 	var resolvedModels []string
-	for model := range includedModels {
+	for model := range included {
 		resolvedModels = append(resolvedModels, model)
 	}
+	sort.Strings(resolvedModels)
+	sort.Slice(dependencies, func(i, j int) bool { return dependencies[i].Model < dependencies[j].Model })
 
 	// Create a new includes file with the resolved dependencies
 	return IncludesFile{
-		Models:  resolvedModels,
-		Queries: includes.Queries,
-	}
+		Models:       resolvedModels,
+		Queries:      includes.Queries,
+		Exclude:      includes.Exclude,
+		FieldRules:   includes.FieldRules,
+		QueryConfigs: includes.QueryConfigs,
+		patterns:     includes.patterns,
+	}, dependencies, nil
 }
 
-// addModelAndDependencies recursively adds a model and its dependencies to the included models set
-func addModelAndDependencies(modelName string, includedModels map[string]bool, messageMap map[string]parser.ProtoMessage) {
-	// Check if this is a known model
-	model, exists := messageMap[modelName]
-	if !exists {
-		return // Not a model, might be a primitive type
-	}
-
-	// Add this model if not already included
-	if !includedModels[modelName] {
-		includedModels[modelName] = true
-
-		// Recursively add dependencies from fields
-		for _, field := range model.Fields {
-			// Skip primitive types
-			if !isPrimitiveType(field.Type) {
-				addModelAndDependencies(field.Type, includedModels, messageMap)
-			}
+// excludedBy returns the exclude.models entry matching a model
+func excludedBy(includes IncludesFile, model string) (string, bool) {
+	for _, entry := range includes.Exclude.Models {
+		if includes.pattern(entry).Match(model) {
+			return entry, true
 		}
 	}
-}
-
-// isPrimitiveType checks if a type is a primitive Protocol Buffer type
-func isPrimitiveType(typeName string) bool {
-	primitiveTypes := map[string]bool{
-		"string": true, "int32": true, "int64": true, "float": true,
-		"double": true, "bool": true, "bytes": true,
-		"google.protobuf.Timestamp": true, // Consider Timestamp as primitive
-	}
-	return primitiveTypes[typeName]
-}
-
-// GetDependencyAdditions returns a list of models that were added due to dependencies
-func GetDependencyAdditions(original IncludesFile, resolved IncludesFile) []string {
-	// Create a set of original models
-	originalModels := make(map[string]bool)
-	for _, model := range original.Models {
-		originalModels[model] = true
-	}
-
-	// Find models in resolved that are not in original
-	var additions []string
-	for _, model := range resolved.Models {
-		if !originalModels[model] {
-			additions = append(additions, model)
-		}
-	}
-
-	return additions
+	return "", false
 }
@@ -0,0 +1,94 @@
+package includes
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+// nestedLibrary returns a query whose row embeds a model, which embeds
+// another model in turn
+func nestedLibrary() ([]parser.QueryMethod, []parser.ProtoMessage) {
+	messages := []parser.ProtoMessage{
+		{Name: "Author", SQLCStruct: "Author"},
+		{Name: "Book", SQLCStruct: "Book", Fields: []parser.ProtoField{{Name: "author", GoType: "Author", Type: "string"}}},
+		{Name: "GetLoanRow", SQLCStruct: "GetLoanRow", Fields: []parser.ProtoField{{Name: "book", GoType: "Book", Type: "string"}}},
+		{Name: "Member", SQLCStruct: "Member"},
+	}
+	queries := []parser.QueryMethod{
+		{Name: "GetLoan", ParamTypes: []parser.ParamType{{Name: "id", Type: "int32"}}, ReturnType: "GetLoanRow"},
+		{Name: "GetMember", ReturnType: "Member"},
+	}
+	return queries, messages
+}
+
+func TestResolveDependencies_Nested(t *testing.T) {
+	queries, messages := nestedLibrary()
+
+	resolved, dependencies, err := ResolveDependencies(IncludesFile{Queries: []string{"GetLoan"}}, queries, messages)
+	if err != nil {
+		t.Fatalf("ResolveDependencies failed: %v", err)
+	}
+
+	if want := []string{"Author", "Book", "GetLoanRow"}; !slices.Equal(resolved.Models, want) {
+		t.Errorf("Models = %v, want %v", resolved.Models, want)
+	}
+
+	var got []string
+	for _, dependency := range dependencies {
+		got = append(got, dependency.String())
+	}
+	want := []string{
+		"Author: query GetLoan → GetLoanRow (returns) → Book (field book) → Author (field author)",
+		"Book: query GetLoan → GetLoanRow (returns) → Book (field book)",
+		"GetLoanRow: query GetLoan → GetLoanRow (returns)",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Dependencies = %q, want %q", got, want)
+	}
+}
+
+func TestResolveDependencies_SelectedModel(t *testing.T) {
+	queries, messages := nestedLibrary()
+
+	// Models need their nested models too, and selected models aren't
+	// reported as dependencies
+	resolved, dependencies, err := ResolveDependencies(IncludesFile{Models: []string{"Book", "Author"}}, queries, messages)
+	if err != nil {
+		t.Fatalf("ResolveDependencies failed: %v", err)
+	}
+	if want := []string{"Author", "Book"}; !slices.Equal(resolved.Models, want) {
+		t.Errorf("Models = %v, want %v", resolved.Models, want)
+	}
+	if len(dependencies) != 0 {
+		t.Errorf("Dependencies = %v, want none", dependencies)
+	}
+}
+
+func TestResolveDependencies_ExcludedDependency(t *testing.T) {
+	queries, messages := nestedLibrary()
+
+	includes := IncludesFile{Exclude: Exclusions{Models: []string{"Auth*"}}}
+	_, _, err := ResolveDependencies(includes, queries, messages)
+	if err == nil {
+		t.Fatal("Expected an error for a query needing an excluded model")
+	}
+	want := "query GetLoan needs Author, which is excluded by exclude.models entry Auth*: query GetLoan → GetLoanRow (returns) → Book (field book) → Author (field author)"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Error = %q, want it to contain %q", err, want)
+	}
+
+	// Excluding the query and the models leading to Author resolves the
+	// conflict
+	includes.Exclude.Queries = []string{"GetLoan"}
+	includes.Exclude.Models = append(includes.Exclude.Models, "Book", "GetLoanRow")
+	resolved, _, err := ResolveDependencies(includes, queries, messages)
+	if err != nil {
+		t.Fatalf("ResolveDependencies failed: %v", err)
+	}
+	if want := []string{"Member"}; !slices.Equal(resolved.Models, want) {
+		t.Errorf("Models = %v, want %v", resolved.Models, want)
+	}
+}
@@ -0,0 +1,337 @@
+package includes
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// FieldRule changes how the matching fields of the matching messages are
+// exposed. Rules are written per message in the fields section of the
+// includes file, either as a mapping or as the name of a single flag:
+//
+//	fields:
+//	  Member:
+//	    password_hash: exclude
+//	    email:
+//	      rename: email_address
+//	      deprecated: true
+//	  "*":
+//	    created_at: outputOnly
+//
+// Message and field keys can be names or patterns. Fields are matched by
+// their proto name or by the name of the sqlc struct field. The rules of a
+// model also apply to the query and service messages named after it, e.g.
+// to GetMemberRow, CreateMemberParams and GetMemberRequest for Member, so
+// that an excluded column doesn't leak through them.
+type FieldRule struct {
+	Message string `yaml:"-"` // Name or pattern of the message
+	Field   string `yaml:"-"` // Name or pattern of the field
+	Line    int    `yaml:"-"` // Line of the rule in the includes file
+
+	// message and field are the compiled Message and Field
+	message, field Pattern
+
+	Exclude    bool   `yaml:"exclude"`    // Leave the field out of the message
+	Rename     string `yaml:"rename"`     // New proto name of the field
+	OutputOnly bool   `yaml:"outputOnly"` // Mark as OUTPUT_ONLY and ignore in FromProto
+	Deprecated bool   `yaml:"deprecated"` // Mark as deprecated
+}
+
+// fieldNamePattern matches a valid proto field name
+var fieldNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// decodeFieldRules decodes the fields section of the includes file
+func decodeFieldRules(node *yaml.Node) ([]FieldRule, error) {
+	if node.Kind == 0 {
+		return nil, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: fields must map message names to field rules", node.Line)
+	}
+
+	var rules []FieldRule
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		message, fields := node.Content[i], node.Content[i+1]
+		messagePattern, err := compilePatternNode(message)
+		if err != nil {
+			return nil, err
+		}
+		if fields.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("line %d: fields of %s must map field names to rules", fields.Line, message.Value)
+		}
+
+		for j := 0; j+1 < len(fields.Content); j += 2 {
+			field, value := fields.Content[j], fields.Content[j+1]
+			fieldPattern, err := compilePatternNode(field)
+			if err != nil {
+				return nil, err
+			}
+			rule, err := decodeFieldRule(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s.%s: %w", value.Line, message.Value, field.Value, err)
+			}
+			rule.Message, rule.Field, rule.Line = message.Value, field.Value, field.Line
+			rule.message, rule.field = messagePattern, fieldPattern
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// decodeFieldRule decodes a rule given as a mapping or as a single flag
+func decodeFieldRule(node *yaml.Node) (FieldRule, error) {
+	var rule FieldRule
+	switch node.Kind {
+	case yaml.ScalarNode:
+		switch node.Value {
+		case "exclude":
+			rule.Exclude = true
+		case "outputOnly":
+			rule.OutputOnly = true
+		case "deprecated":
+			rule.Deprecated = true
+		default:
+			return rule, fmt.Errorf("unknown rule %q, expected exclude, outputOnly, deprecated or a mapping", node.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			switch key := node.Content[i].Value; key {
+			case "exclude", "rename", "outputOnly", "deprecated":
+			default:
+				return rule, fmt.Errorf("unknown key %q, expected exclude, rename, outputOnly or deprecated", key)
+			}
+		}
+		if err := node.Decode(&rule); err != nil {
+			return rule, err
+		}
+	default:
+		return rule, fmt.Errorf("rule must be a flag or a mapping")
+	}
+
+	if rule.Rename != "" && !fieldNamePattern.MatchString(rule.Rename) {
+		return rule, fmt.Errorf("invalid field name %q", rule.Rename)
+	}
+	if rule.Exclude && (rule.Rename != "" || rule.OutputOnly || rule.Deprecated) {
+		return rule, fmt.Errorf("an excluded field can't have other rules")
+	}
+	return rule, nil
+}
+
+// matches reports whether the rule applies to a field of a message, given
+// its proto name and the name of its sqlc struct field
+func (r FieldRule) matches(message, name, sqlcName string) bool {
+	return r.message.Match(message) && (r.field.Match(name) || (sqlcName != "" && r.field.Match(sqlcName)))
+}
+
+// FieldRuleSet applies field rules to messages and records which rules
+// matched a field
+type FieldRuleSet struct {
+	rules   []FieldRule
+	matched []bool
+
+	// models are the messages of the sqlc models
+	models []string
+	// excluded maps the sqlc names of the excluded model fields to the
+	// model fields, e.g. PasswordHash to Member.password_hash
+	excluded map[string][]string
+	// kept maps the sqlc names of the fields of the other messages to the
+	// messages that still have them
+	kept map[string][]string
+}
+
+// NewFieldRuleSet returns a rule set for the field rules of an includes
+// file. Rules that weren't decoded from the file are compiled, and match
+// nothing if their patterns are invalid.
+func NewFieldRuleSet(rules []FieldRule) *FieldRuleSet {
+	rules = slices.Clone(rules)
+	for i := range rules {
+		if rules[i].message.String() != rules[i].Message {
+			rules[i].message, _ = CompilePattern(rules[i].Message)
+		}
+		if rules[i].field.String() != rules[i].Field {
+			rules[i].field, _ = CompilePattern(rules[i].Field)
+		}
+	}
+	return &FieldRuleSet{
+		rules:    rules,
+		matched:  make([]bool, len(rules)),
+		excluded: make(map[string][]string),
+		kept:     make(map[string][]string),
+	}
+}
+
+// ApplyToMessages applies the rules to the fields of the messages. The
+// numbers of excluded fields are reserved, so they are never reused. Rules
+// must be applied before parser.GenerateConversionCode, so that mappers use
+// the renamed fields.
+func (s *FieldRuleSet) ApplyToMessages(messages []parser.ProtoMessage) error {
+	for _, msg := range messages {
+		if !isQueryStruct(msg.Name) {
+			s.models = append(s.models, msg.Name)
+		}
+	}
+	for i := range messages {
+		fields, excluded, err := s.apply(messages[i].Name, messages[i].Fields)
+		if err != nil {
+			return err
+		}
+		messages[i].Fields = fields
+		messages[i].Reserved = append(messages[i].Reserved, excluded...)
+	}
+	return nil
+}
+
+// ApplyToServices applies the rules to the request and response messages
+// generated for the service methods
+func (s *FieldRuleSet) ApplyToServices(services []parser.ServiceDefinition) error {
+	for i := range services {
+		for j := range services[i].Methods {
+			method := &services[i].Methods[j]
+			var err error
+			if !method.OmitRequestMessage {
+				if method.RequestFields, _, err = s.apply(method.RequestType, method.RequestFields); err != nil {
+					return err
+				}
+			}
+			if !method.OmitResponseMessage {
+				if method.ResponseFields, _, err = s.apply(method.ResponseType, method.ResponseFields); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Leaked returns a warning for each field excluded from a model that still
+// appears in another message, e.g. in the row of a query that joins the
+// model's table. The rules must have been applied to the messages and the
+// services first.
+func (s *FieldRuleSet) Leaked() []string {
+	var warnings []string
+	for _, sqlcName := range slices.Sorted(maps.Keys(s.excluded)) {
+		messages := s.kept[sqlcName]
+		if len(messages) == 0 {
+			continue
+		}
+		for _, field := range s.excluded[sqlcName] {
+			warnings = append(warnings, fmt.Sprintf("%s is excluded, but %s still appears in %s", field, sqlcName, strings.Join(messages, ", ")))
+		}
+	}
+	return warnings
+}
+
+// Unmatched returns a warning for each rule that matched no field
+func (s *FieldRuleSet) Unmatched() []string {
+	var warnings []string
+	for i, rule := range s.rules {
+		if !s.matched[i] {
+			warnings = append(warnings, fmt.Sprintf("field rule %s.%s on line %d matches nothing", rule.Message, rule.Field, rule.Line))
+		}
+	}
+	return warnings
+}
+
+// apply applies the rules to the fields of a message, returning the
+// remaining fields and the numbers of the excluded ones
+func (s *FieldRuleSet) apply(message string, fields []parser.ProtoField) ([]parser.ProtoField, []int, error) {
+	var result []parser.ProtoField
+	var excluded []int
+	isModel := slices.Contains(s.models, message)
+	carried := s.carriedModels(message)
+	for _, field := range fields {
+		original := field.Name
+		exclude := false
+		for i, rule := range s.rules {
+			if !rule.matches(message, original, field.SQLCName) && !slices.ContainsFunc(carried, func(model string) bool {
+				return rule.matches(model, original, field.SQLCName)
+			}) {
+				continue
+			}
+			s.matched[i] = true
+
+			switch {
+			case rule.Exclude:
+				exclude = true
+			case rule.Rename != "":
+				// The conversion code is built from the new name later
+				if field.JSONName != "" {
+					field.JSONName = rule.Rename
+				}
+				field.Name = rule.Rename
+			}
+			if rule.OutputOnly && !field.OutputOnly {
+				field.OutputOnly = true
+				field.Options = append(slices.Clone(field.Options), "(google.api.field_behavior) = OUTPUT_ONLY")
+				if field.Lossy == "" {
+					field.Lossy = "output only, not read by FromProto"
+				}
+			}
+			if rule.Deprecated && !slices.Contains(field.Options, "deprecated = true") {
+				field.Options = append(slices.Clone(field.Options), "deprecated = true")
+			}
+		}
+
+		switch {
+		case exclude && isModel && field.SQLCName != "":
+			s.excluded[field.SQLCName] = append(s.excluded[field.SQLCName], message+"."+original)
+		case !exclude && !isModel && field.SQLCName != "" && !slices.Contains(s.kept[field.SQLCName], message):
+			s.kept[field.SQLCName] = append(s.kept[field.SQLCName], message)
+		}
+		if exclude {
+			excluded = append(excluded, field.Number)
+			continue
+		}
+		result = append(result, field)
+	}
+
+	// Renamed fields must not clash with other fields
+	seen := make(map[string]bool, len(result))
+	for _, field := range result {
+		if seen[field.Name] {
+			return nil, nil, fmt.Errorf("message %s: more than one field is named %s after applying the field rules", message, field.Name)
+		}
+		seen[field.Name] = true
+	}
+	return result, excluded, nil
+}
+
+// carriedModels returns the models a query or service message is named
+// after, e.g. Member for GetMemberRow and ListMembersRequest
+func (s *FieldRuleSet) carriedModels(message string) []string {
+	var models []string
+	for _, model := range s.models {
+		if model != message && (containsWord(message, model) || containsWord(message, parser.Pluralize(model))) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// isQueryStruct reports whether a message was generated from the params or
+// the row struct of a query rather than from a model
+func isQueryStruct(name string) bool {
+	return strings.HasSuffix(name, "Params") || strings.HasSuffix(name, "Row")
+}
+
+// containsWord reports whether a PascalCase name contains word as whole
+// words, e.g. GetMemberRow contains Member but MembershipRow doesn't
+func containsWord(name, word string) bool {
+	for i := 0; i+len(word) <= len(name); i++ {
+		if !strings.HasPrefix(name[i:], word) {
+			continue
+		}
+		end := i + len(word)
+		if (i == 0 || unicode.IsUpper(rune(name[i]))) && (end == len(name) || unicode.IsUpper(rune(name[end]))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package includes
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+func TestLoadIncludesFile_FieldRules(t *testing.T) {
+	content := `fields:
+  Member:
+    phone: exclude
+    Email:
+      rename: email_address
+      deprecated: true
+  "*":
+    created_at: outputOnly
+`
+	path := filepath.Join(t.TempDir(), "includes.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write includes file: %v", err)
+	}
+
+	includesFile, err := LoadIncludesFile(path)
+	if err != nil {
+		t.Fatalf("LoadIncludesFile failed: %v", err)
+	}
+
+	want := []FieldRule{
+		{Message: "Member", Field: "phone", Line: 3, Exclude: true},
+		{Message: "Member", Field: "Email", Line: 4, Rename: "email_address", Deprecated: true},
+		{Message: "*", Field: "created_at", Line: 8, OutputOnly: true},
+	}
+	// Decoded rules are compiled like those passed to NewFieldRuleSet
+	want = NewFieldRuleSet(want).rules
+	if !slices.Equal(includesFile.FieldRules, want) {
+		t.Errorf("FieldRules = %+v, want %+v", includesFile.FieldRules, want)
+	}
+	// Field rules don't select models or queries
+	if !includesFile.IsEmpty() {
+		t.Error("An includes file with only field rules should select everything")
+	}
+}
+
+func TestLoadIncludesFile_InvalidFieldRules(t *testing.T) {
+	tests := map[string]string{
+		"unknown flag":     "fields:\n  Member:\n    phone: hidden\n",
+		"unknown key":      "fields:\n  Member:\n    phone:\n      hide: true\n",
+		"invalid rename":   "fields:\n  Member:\n    phone:\n      rename: 2phone\n",
+		"exclude combined": "fields:\n  Member:\n    phone:\n      exclude: true\n      rename: mobile\n",
+		"fields list":      "fields:\n  Member:\n  - phone\n",
+		"invalid pattern":  "fields:\n  \"Member[\":\n    phone: exclude\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "includes.yaml")
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write includes file: %v", err)
+			}
+
+			if _, err := LoadIncludesFile(path); err == nil {
+				t.Errorf("Expected an error for %s", name)
+			}
+		})
+	}
+}
+
+func TestFieldRuleSet(t *testing.T) {
+	messages := []parser.ProtoMessage{{
+		Name: "Member",
+		Fields: []parser.ProtoField{
+			{Name: "id", Number: 1, SQLCName: "ID"},
+			{Name: "email", Number: 2, SQLCName: "Email", JSONName: "email", GoType: "sql.NullString"},
+			{Name: "phone", Number: 3, SQLCName: "Phone"},
+			{Name: "created_at", Number: 4, SQLCName: "CreatedAt"},
+		},
+	}}
+
+	rules := NewFieldRuleSet([]FieldRule{
+		{Message: "Member", Field: "Phone", Line: 3, Exclude: true},
+		{Message: "Member", Field: "email", Line: 4, Rename: "email_address"},
+		{Message: "*", Field: "created_at", Line: 5, OutputOnly: true},
+		{Message: "Book", Field: "*", Line: 6, Deprecated: true},
+	})
+	if err := rules.ApplyToMessages(messages); err != nil {
+		t.Fatalf("ApplyToMessages failed: %v", err)
+	}
+
+	msg := messages[0]
+	var names []string
+	for _, field := range msg.Fields {
+		names = append(names, field.Name)
+	}
+	if want := []string{"id", "email_address", "created_at"}; !slices.Equal(names, want) {
+		t.Errorf("Fields = %v, want %v", names, want)
+	}
+	if !slices.Equal(msg.Reserved, []int{3}) {
+		t.Errorf("Reserved = %v, want [3]", msg.Reserved)
+	}
+	if got := msg.Fields[1].JSONName; got != "email_address" {
+		t.Errorf("JSONName = %q, want email_address", got)
+	}
+	parser.GenerateConversionCode(messages, parser.GetTypeMapConfig())
+	if got := msg.Fields[1].ReverseConversionCode; got != "stringToNullString(in.EmailAddress)" {
+		t.Errorf("ReverseConversionCode = %q, want stringToNullString(in.EmailAddress)", got)
+	}
+	if field := msg.Fields[2]; !field.OutputOnly || !slices.Contains(field.Options, "(google.api.field_behavior) = OUTPUT_ONLY") {
+		t.Errorf("created_at is not output only: %+v", field)
+	}
+
+	warnings := rules.Unmatched()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Book.* on line 6") {
+		t.Errorf("Unmatched() = %v, want a warning for Book.*", warnings)
+	}
+}
+
+func TestFieldRuleSet_RenameClash(t *testing.T) {
+	messages := []parser.ProtoMessage{{
+		Name: "Member",
+		Fields: []parser.ProtoField{
+			{Name: "email", Number: 1},
+			{Name: "email_address", Number: 2},
+		},
+	}}
+
+	rules := NewFieldRuleSet([]FieldRule{{Message: "Member", Field: "email", Rename: "email_address"}})
+	if err := rules.ApplyToMessages(messages); err == nil {
+		t.Error("Expected an error for two fields named email_address")
+	}
+}
+
+func TestFieldRuleSet_QueryMessages(t *testing.T) {
+	messages := []parser.ProtoMessage{
+		{Name: "Member", Fields: []parser.ProtoField{
+			{Name: "id", Number: 1, SQLCName: "ID"},
+			{Name: "password_hash", Number: 2, SQLCName: "PasswordHash"},
+		}},
+		{Name: "GetMemberRow", Fields: []parser.ProtoField{
+			{Name: "id", Number: 1, SQLCName: "ID"},
+			{Name: "password_hash", Number: 2, SQLCName: "PasswordHash"},
+		}},
+		{Name: "ListMembersRow", Fields: []parser.ProtoField{
+			{Name: "password_hash", Number: 1, SQLCName: "PasswordHash"},
+		}},
+		{Name: "MembershipRow", Fields: []parser.ProtoField{
+			{Name: "password_hash", Number: 1, SQLCName: "PasswordHash"},
+		}},
+	}
+	services := []parser.ServiceDefinition{{
+		Methods: []parser.ServiceMethod{{
+			RequestType:    "CreateMemberRequest",
+			RequestFields:  []parser.ProtoField{{Name: "password_hash", Number: 1, SQLCName: "PasswordHash"}},
+			ResponseType:   "CreateMemberResponse",
+			ResponseFields: []parser.ProtoField{{Name: "member", Number: 1}},
+		}},
+	}}
+
+	rules := NewFieldRuleSet([]FieldRule{{Message: "Member", Field: "password_hash", Line: 3, Exclude: true}})
+	if err := rules.ApplyToMessages(messages); err != nil {
+		t.Fatalf("ApplyToMessages failed: %v", err)
+	}
+	if err := rules.ApplyToServices(services); err != nil {
+		t.Fatalf("ApplyToServices failed: %v", err)
+	}
+
+	tests := []struct {
+		message string
+		fields  []parser.ProtoField
+		want    int
+	}{
+		{"Member", messages[0].Fields, 1},
+		{"GetMemberRow", messages[1].Fields, 1},
+		{"ListMembersRow", messages[2].Fields, 0},
+		{"MembershipRow", messages[3].Fields, 1},
+		{"CreateMemberRequest", services[0].Methods[0].RequestFields, 0},
+	}
+	for _, tt := range tests {
+		if len(tt.fields) != tt.want {
+			t.Errorf("%s has %d fields, want %d: %+v", tt.message, len(tt.fields), tt.want, tt.fields)
+		}
+	}
+	if !slices.Equal(messages[1].Reserved, []int{2}) {
+		t.Errorf("GetMemberRow reserved %v, want [2]", messages[1].Reserved)
+	}
+
+	// MembershipRow isn't named after Member, so it keeps the column
+	warnings := rules.Leaked()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Member.password_hash") || !strings.Contains(warnings[0], "MembershipRow") {
+		t.Errorf("Leaked() = %v, want a warning for MembershipRow", warnings)
+	}
+}
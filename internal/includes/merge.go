@@ -0,0 +1,202 @@
+package includes
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StaleMarker is the comment added to entries that no longer exist in the
+// sqlc output
+const StaleMarker = "# stale: not found in the sqlc output"
+
+var (
+	// entryPattern matches a list entry of the includes file, active or
+	// commented out, e.g. "- Book", "# - Loan  # Not public",
+	// "- name: ListActiveLoansByMember", "- Get*" or "- '/^List/'"
+	entryPattern = regexp.MustCompile(`^(\s*)(#\s*)?-\s+(?:name:\s*)?("[^"]*"|'[^']*'|[^\s#"'][^\s#]*)\s*(#.*)?$`)
+
+	// sectionPattern matches a top-level key, e.g. "models:"
+	sectionPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_-]*):\s*(.*)$`)
+)
+
+// MergeResult lists the changes made by MergeIncludes
+type MergeResult struct {
+	AddedModels  []string // Models added as commented-out entries
+	AddedQueries []string // Queries added as commented-out entries
+	StaleModels  []string // Model entries that are stale
+	StaleQueries []string // Query entries that are stale
+}
+
+// MergeIncludes updates the content of an includes file with the models and
+// queries found in the sqlc output, keeping the selections, comments and
+// formatting of the file. New models and queries are added as commented-out
+// entries at the end of their section, and entries (active or commented
+// out) that no longer exist are marked with StaleMarker. Markers are removed
+// from entries that exist again. A nil list of queries leaves the queries
+// section untouched, e.g. when service generation is disabled.
+//
+// Glob patterns and regular expressions cover the names they match, as do
+// the entries of the exclude section, so those names aren't added. Patterns
+// that match nothing are marked as stale.
+func MergeIncludes(data []byte, models, queries []string) ([]byte, MergeResult, error) {
+	var result MergeResult
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	var existing IncludesFile
+	if err := yaml.Unmarshal(data, &existing); err != nil {
+		return nil, result, fmt.Errorf("includes file is invalid: %w", err)
+	}
+
+	var err error
+	lines, result.AddedModels, result.StaleModels, err = mergeSection(lines, "models", models, existing.Exclude.Models)
+	if err != nil {
+		return nil, result, err
+	}
+	if queries != nil {
+		lines, result.AddedQueries, result.StaleQueries, err = mergeSection(lines, "queries", queries, existing.Exclude.Queries)
+		if err != nil {
+			return nil, result, err
+		}
+	}
+
+	merged := []byte(strings.Join(lines, "\n") + "\n")
+
+	// The merged file must still be a valid includes file
+	var check IncludesFile
+	if err := yaml.Unmarshal(merged, &check); err != nil {
+		return nil, result, fmt.Errorf("merged includes file is invalid: %w", err)
+	}
+	return merged, result, nil
+}
+
+// mergeSection merges the names of one top-level section, returning the
+// updated lines, the names that were added and the entries marked as stale.
+// Names matching an excluded entry aren't added.
+func mergeSection(lines []string, key string, names, excluded []string) ([]string, []string, []string, error) {
+	start, end := findSection(lines, key)
+	if start < 0 {
+		// Append the missing section
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, key+":")
+		start, end = len(lines)-1, len(lines)
+	}
+
+	// A flow-style list can only be merged when it's empty
+	if _, value := sectionValue(lines[start]); value != "" {
+		if strings.TrimSpace(strings.SplitN(value, "#", 2)[0]) != "[]" {
+			return nil, nil, nil, fmt.Errorf("line %d: %s must be a block list to be merged", start+1, key)
+		}
+		lines[start] = key + ":"
+	}
+
+	// Update the stale markers of the existing entries, which may be names,
+	// glob patterns or regular expressions
+	var present []Pattern
+	var stale []string
+	indent := ""
+	last := start
+	for i := start + 1; i < end; i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			last = i
+		}
+		m := entryPattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		if len(present) == 0 {
+			indent = m[1]
+		}
+		entry := unquoteEntry(m[3])
+		pattern, err := CompilePattern(entry)
+		if err != nil {
+			// Commented-out lines may be notes rather than entries
+			if m[2] != "" {
+				continue
+			}
+			return nil, nil, nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		present = append(present, pattern)
+
+		marked := strings.Contains(lines[i], StaleMarker)
+		switch {
+		case !slices.ContainsFunc(names, pattern.Match):
+			if !marked {
+				lines[i] += "  " + StaleMarker
+			}
+			stale = append(stale, entry)
+		case marked:
+			lines[i] = strings.TrimRight(strings.Replace(lines[i], StaleMarker, "", 1), " \t")
+		}
+	}
+
+	// Excluded names are selected already, by leaving them out
+	for _, entry := range excluded {
+		if pattern, err := CompilePattern(entry); err == nil {
+			present = append(present, pattern)
+		}
+	}
+
+	// Add the new names after the last entry of the section
+	var added, entries []string
+	for _, name := range names {
+		covered := slices.ContainsFunc(present, func(pattern Pattern) bool {
+			return pattern.Match(name)
+		})
+		if !covered {
+			present = append(present, Pattern{entry: name})
+			added = append(added, name)
+			entries = append(entries, indent+"# - "+name)
+		}
+	}
+	lines = append(lines[:last+1], append(entries, lines[last+1:]...)...)
+	return lines, added, stale, nil
+}
+
+// unquoteEntry removes the YAML quotes around an entry, e.g. "*Params"
+func unquoteEntry(entry string) string {
+	if len(entry) >= 2 && (entry[0] == '"' || entry[0] == '\'') && entry[len(entry)-1] == entry[0] {
+		return entry[1 : len(entry)-1]
+	}
+	return entry
+}
+
+// findSection returns the line of a top-level key and the line where its
+// section ends, or -1 if the key doesn't exist
+func findSection(lines []string, key string) (int, int) {
+	start := -1
+	for i, line := range lines {
+		name, _ := sectionValue(line)
+		if name == "" {
+			continue
+		}
+		if start >= 0 {
+			return start, i
+		}
+		if name == key {
+			start = i
+		}
+	}
+	if start < 0 {
+		return -1, -1
+	}
+	return start, len(lines)
+}
+
+// sectionValue returns the top-level key of a line and the value following
+// it, or "" if the line isn't a top-level key
+func sectionValue(line string) (string, string) {
+	m := sectionPattern.FindStringSubmatch(line)
+	if m == nil {
+		return "", ""
+	}
+	return m[1], strings.TrimSpace(m[2])
+}
@@ -0,0 +1,186 @@
+package includes
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMergeIncludes(t *testing.T) {
+	existing := `# Public API of the library service
+models:
+- Book
+# - Loan  # Not public yet
+- Author
+
+queries:
+- GetBook
+- name: ListActiveLoansByMember
+  service: Loan
+  streaming: server
+# - DeleteAuthor
+`
+	models := []string{"Book", "Loan", "Member"}
+	queries := []string{"GetBook", "ListActiveLoansByMember", "ListMembers"}
+
+	merged, result, err := MergeIncludes([]byte(existing), models, queries)
+	if err != nil {
+		t.Fatalf("MergeIncludes failed: %v", err)
+	}
+
+	want := `# Public API of the library service
+models:
+- Book
+# - Loan  # Not public yet
+- Author  # stale: not found in the sqlc output
+# - Member
+
+queries:
+- GetBook
+- name: ListActiveLoansByMember
+  service: Loan
+  streaming: server
+# - DeleteAuthor  # stale: not found in the sqlc output
+# - ListMembers
+`
+	if string(merged) != want {
+		t.Errorf("Unexpected merge result:\n%s\nwant:\n%s", merged, want)
+	}
+	if strings.Join(result.AddedModels, ",") != "Member" || strings.Join(result.AddedQueries, ",") != "ListMembers" {
+		t.Errorf("Unexpected additions: %+v", result)
+	}
+	if strings.Join(result.StaleModels, ",") != "Author" || strings.Join(result.StaleQueries, ",") != "DeleteAuthor" {
+		t.Errorf("Unexpected stale entries: %+v", result)
+	}
+
+	// The selections are kept
+	var includesFile IncludesFile
+	if err := yaml.Unmarshal(merged, &includesFile); err != nil {
+		t.Fatalf("Merged file doesn't parse: %v", err)
+	}
+	if strings.Join(includesFile.Models, ",") != "Book,Author" {
+		t.Errorf("Unexpected models: %v", includesFile.Models)
+	}
+	if includesFile.QueryConfigs["ListActiveLoansByMember"].Streaming != "server" {
+		t.Errorf("Structured query entry was lost: %+v", includesFile.QueryConfigs)
+	}
+
+	// Merging again changes nothing, and reports the entries that are still stale
+	again, result, err := MergeIncludes(merged, models, queries)
+	if err != nil {
+		t.Fatalf("MergeIncludes failed: %v", err)
+	}
+	if string(again) != string(merged) {
+		t.Errorf("Merge is not idempotent:\n%s", again)
+	}
+	if len(result.AddedModels) != 0 || strings.Join(result.StaleModels, ",") != "Author" {
+		t.Errorf("Unexpected result of the second merge: %+v", result)
+	}
+
+	// Entries that exist again lose their marker
+	restored, _, err := MergeIncludes(merged, append(models, "Author"), queries)
+	if err != nil {
+		t.Fatalf("MergeIncludes failed: %v", err)
+	}
+	if !strings.Contains(string(restored), "\n- Author\n") {
+		t.Errorf("Stale marker was not removed:\n%s", restored)
+	}
+}
+
+func TestMergeIncludes_Patterns(t *testing.T) {
+	existing := `models:
+- "*Params"
+- /^Legacy/
+
+queries:
+- Get*
+- '/^List/'
+# - Delete*
+
+exclude:
+  queries:
+  - CreateLoan
+`
+	models := []string{"Book", "CreateBookParams", "ListBooksParams"}
+	queries := []string{"GetBook", "GetMember", "ListBooks", "ListMembers", "CreateLoan", "ReturnBook"}
+
+	merged, result, err := MergeIncludes([]byte(existing), models, queries)
+	if err != nil {
+		t.Fatalf("MergeIncludes failed: %v", err)
+	}
+
+	// Names matched by a pattern or excluded aren't added, and patterns that
+	// match nothing are stale
+	want := `models:
+- "*Params"
+- /^Legacy/  # stale: not found in the sqlc output
+# - Book
+
+queries:
+- Get*
+- '/^List/'
+# - Delete*  # stale: not found in the sqlc output
+# - ReturnBook
+
+exclude:
+  queries:
+  - CreateLoan
+`
+	if string(merged) != want {
+		t.Errorf("Unexpected merge result:\n%s\nwant:\n%s", merged, want)
+	}
+	if strings.Join(result.AddedModels, ",") != "Book" || strings.Join(result.AddedQueries, ",") != "ReturnBook" {
+		t.Errorf("Unexpected additions: %+v", result)
+	}
+	if strings.Join(result.StaleModels, ",") != "/^Legacy/" || strings.Join(result.StaleQueries, ",") != "Delete*" {
+		t.Errorf("Unexpected stale entries: %+v", result)
+	}
+
+	// Nothing is new on the second merge
+	again, result, err := MergeIncludes(merged, models, queries)
+	if err != nil {
+		t.Fatalf("MergeIncludes failed: %v", err)
+	}
+	if string(again) != string(merged) || len(result.AddedModels) != 0 || len(result.AddedQueries) != 0 {
+		t.Errorf("Merge is not idempotent: %+v\n%s", result, again)
+	}
+}
+
+func TestMergeIncludes_MissingSections(t *testing.T) {
+	tests := map[string]struct {
+		existing string
+		queries  []string
+		want     string
+	}{
+		"empty file": {
+			existing: "",
+			queries:  []string{"GetBook"},
+			want:     "models:\n# - Book\n\nqueries:\n# - GetBook\n",
+		},
+		"empty flow list": {
+			existing: "models: []\n",
+			want:     "models:\n# - Book\n",
+		},
+		"queries left alone": {
+			existing: "models:\n- Book\n\nqueries:\n- Removed\n",
+			want:     "models:\n- Book\n\nqueries:\n- Removed\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			merged, _, err := MergeIncludes([]byte(tt.existing), []string{"Book"}, tt.queries)
+			if err != nil {
+				t.Fatalf("MergeIncludes failed: %v", err)
+			}
+			if string(merged) != tt.want {
+				t.Errorf("Unexpected merge result:\n%q\nwant:\n%q", merged, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := MergeIncludes([]byte("models: [Book]\n"), []string{"Book"}, nil); err == nil {
+		t.Error("Expected an error for a non-empty flow-style list")
+	}
+}
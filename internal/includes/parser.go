@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/boomskats/sqlc2proto/internal/parser"
 	"gopkg.in/yaml.v3"
 )
 
@@ -26,24 +27,135 @@ func LoadIncludesFile(path string) (IncludesFile, error) {
 	return includes, nil
 }
 
-// IsModelIncluded checks if a model is included
-func IsModelIncluded(includes IncludesFile, modelName string) bool {
-	for _, model := range includes.Models {
-		if model == modelName {
-			return true
+// UnmarshalYAML decodes an includes file, accepting query entries either as
+// plain names or as structured QueryConfig mappings
+func (f *IncludesFile) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Models  []yaml.Node `yaml:"models"`
+		Queries []yaml.Node `yaml:"queries"`
+		Exclude struct {
+			Models  []yaml.Node `yaml:"models"`
+			Queries []yaml.Node `yaml:"queries"`
+		} `yaml:"exclude"`
+		Fields yaml.Node `yaml:"fields"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	var err error
+	f.patterns = make(map[string]Pattern)
+	if f.Models, err = decodeEntries(raw.Models, "model", f.patterns); err != nil {
+		return err
+	}
+	if f.Exclude.Models, err = decodeEntries(raw.Exclude.Models, "model", f.patterns); err != nil {
+		return err
+	}
+	if f.Exclude.Queries, err = decodeEntries(raw.Exclude.Queries, "query", f.patterns); err != nil {
+		return err
+	}
+	if f.FieldRules, err = decodeFieldRules(&raw.Fields); err != nil {
+		return err
+	}
+	f.Queries = nil
+	f.QueryConfigs = nil
+
+	for _, node := range raw.Queries {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			pattern, err := compilePatternNode(&node)
+			if err != nil {
+				return err
+			}
+			f.patterns[node.Value] = pattern
+			f.Queries = append(f.Queries, node.Value)
+		case yaml.MappingNode:
+			var queryConfig QueryConfig
+			if err := node.Decode(&queryConfig); err != nil {
+				return fmt.Errorf("line %d: %w", node.Line, err)
+			}
+			if queryConfig.Name == "" {
+				return fmt.Errorf("line %d: query entry is missing a name", node.Line)
+			}
+			if IsPattern(queryConfig.Name) {
+				return fmt.Errorf("line %d: query entry with settings must name a single query, not the pattern %s", node.Line, queryConfig.Name)
+			}
+			switch queryConfig.Streaming {
+			case "", "none", "server":
+			default:
+				return fmt.Errorf("line %d: invalid streaming mode %q for query %s (expected \"none\" or \"server\")",
+					node.Line, queryConfig.Streaming, queryConfig.Name)
+			}
+
+			if f.QueryConfigs == nil {
+				f.QueryConfigs = make(map[string]QueryConfig)
+			}
+			f.Queries = append(f.Queries, queryConfig.Name)
+			f.QueryConfigs[queryConfig.Name] = queryConfig
+		default:
+			return fmt.Errorf("line %d: query entry must be a name or a mapping", node.Line)
 		}
 	}
-	return false
+
+	return nil
 }
 
-// IsQueryIncluded checks if a query is included
-func IsQueryIncluded(includes IncludesFile, queryName string) bool {
-	for _, query := range includes.Queries {
-		if query == queryName {
-			return true
+// decodeEntries decodes a list of names and patterns, adding their compiled
+// form to patterns
+func decodeEntries(nodes []yaml.Node, kind string, patterns map[string]Pattern) ([]string, error) {
+	var entries []string
+	for _, node := range nodes {
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: %s entry must be a name or a pattern", node.Line, kind)
+		}
+		pattern, err := compilePatternNode(&node)
+		if err != nil {
+			return nil, err
 		}
+		patterns[node.Value] = pattern
+		entries = append(entries, node.Value)
 	}
-	return false
+	return entries, nil
+}
+
+// MethodOverrides converts the structured query entries into service method overrides
+func MethodOverrides(includes IncludesFile) map[string]parser.MethodOverride {
+	overrides := make(map[string]parser.MethodOverride, len(includes.QueryConfigs))
+	for name, queryConfig := range includes.QueryConfigs {
+		overrides[name] = parser.MethodOverride{
+			Service:     queryConfig.Service,
+			RPCName:     queryConfig.RPC,
+			Streaming:   queryConfig.Streaming,
+			Pagination:  queryConfig.Pagination,
+			Description: queryConfig.Description,
+		}
+	}
+	return overrides
+}
+
+// IsModelIncluded checks if a model is included. A model is included if it
+// matches an entry of the models list and no excluded entry. A file that
+// only excludes includes every other model.
+func IsModelIncluded(includes IncludesFile, modelName string) bool {
+	if includes.matchesAny(includes.Exclude.Models, modelName) {
+		return false
+	}
+	return includesAll(includes) || includes.matchesAny(includes.Models, modelName)
+}
+
+// IsQueryIncluded checks if a query is included, in the same way as
+// IsModelIncluded
+func IsQueryIncluded(includes IncludesFile, queryName string) bool {
+	if includes.matchesAny(includes.Exclude.Queries, queryName) {
+		return false
+	}
+	return includesAll(includes) || includes.matchesAny(includes.Queries, queryName)
+}
+
+// includesAll reports whether the file doesn't list any models or queries
+// to include, so that everything not excluded is included
+func includesAll(includes IncludesFile) bool {
+	return len(includes.Models) == 0 && len(includes.Queries) == 0
 }
 
 // WriteIncludesFile writes the includes file to the given path
@@ -0,0 +1,82 @@
+package includes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadIncludesFile_StructuredQueries(t *testing.T) {
+	content := `models:
+- Book
+- Loan
+
+queries:
+- GetBook
+# - ListBooks
+- name: ListActiveLoansByMember
+  service: Loan
+  rpc: ListMemberLoans
+  streaming: server
+  pagination: false
+  description: Lists the active loans of a member
+`
+	path := filepath.Join(t.TempDir(), "includes.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write includes file: %v", err)
+	}
+
+	includesFile, err := LoadIncludesFile(path)
+	if err != nil {
+		t.Fatalf("LoadIncludesFile failed: %v", err)
+	}
+
+	if strings.Join(includesFile.Queries, ",") != "GetBook,ListActiveLoansByMember" {
+		t.Errorf("Unexpected queries: %v", includesFile.Queries)
+	}
+	if !IsQueryIncluded(includesFile, "ListActiveLoansByMember") {
+		t.Errorf("Structured query entries should be included")
+	}
+
+	overrides := MethodOverrides(includesFile)
+	if len(overrides) != 1 {
+		t.Fatalf("Expected 1 override, got %d", len(overrides))
+	}
+
+	override := overrides["ListActiveLoansByMember"]
+	if override.Service != "Loan" || override.RPCName != "ListMemberLoans" || override.Streaming != "server" {
+		t.Errorf("Unexpected override: %+v", override)
+	}
+	if override.Pagination == nil || *override.Pagination {
+		t.Errorf("Expected pagination to be explicitly disabled")
+	}
+	if override.Description != "Lists the active loans of a member" {
+		t.Errorf("Unexpected description: %s", override.Description)
+	}
+}
+
+func TestLoadIncludesFile_InvalidQueryEntries(t *testing.T) {
+	tests := map[string]string{
+		"missing name":      "queries:\n- service: Loan\n",
+		"invalid streaming": "queries:\n- name: ListBooks\n  streaming: bidi\n",
+		"nested list":       "queries:\n- [GetBook]\n",
+		"invalid regexp":    "queries:\n- /^List(/\n",
+		"invalid glob":      "models:\n- \"Book[\"\n",
+		"pattern settings":  "queries:\n- name: List*\n  service: Loan\n",
+		"excluded mapping":  "exclude:\n  models:\n  - name: Book\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "includes.yaml")
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write includes file: %v", err)
+			}
+
+			if _, err := LoadIncludesFile(path); err == nil {
+				t.Errorf("Expected an error for %s", name)
+			}
+		})
+	}
+}
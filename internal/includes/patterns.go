@@ -0,0 +1,130 @@
+package includes
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isRegexp reports whether an entry is a regular expression, e.g. "/^Get/"
+func isRegexp(entry string) bool {
+	return len(entry) >= 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/")
+}
+
+// IsPattern reports whether an entry of the includes file is a glob pattern
+// or a regular expression rather than an exact name
+func IsPattern(entry string) bool {
+	return isRegexp(entry) || strings.ContainsAny(entry, "*?[")
+}
+
+// Pattern is a compiled entry of the includes file. The zero Pattern
+// matches nothing.
+type Pattern struct {
+	entry string
+	re    *regexp.Regexp // Set for regular expressions
+	glob  bool
+}
+
+// CompilePattern compiles an exact name, a glob pattern or a regular
+// expression
+func CompilePattern(entry string) (Pattern, error) {
+	if isRegexp(entry) {
+		re, err := regexp.Compile(entry[1 : len(entry)-1])
+		if err != nil {
+			return Pattern{}, fmt.Errorf("invalid regular expression %s: %w", entry, err)
+		}
+		return Pattern{entry: entry, re: re}, nil
+	}
+	if IsPattern(entry) {
+		if _, err := path.Match(entry, ""); err != nil {
+			return Pattern{}, fmt.Errorf("invalid pattern %q: %w", entry, err)
+		}
+		return Pattern{entry: entry, glob: true}, nil
+	}
+	return Pattern{entry: entry}, nil
+}
+
+// compilePatternNode compiles an entry of the includes file, reporting
+// errors with its line
+func compilePatternNode(node *yaml.Node) (Pattern, error) {
+	pattern, err := CompilePattern(node.Value)
+	if err != nil {
+		return Pattern{}, fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	return pattern, nil
+}
+
+// Match reports whether a name matches the pattern. Regular expressions
+// match anywhere in the name unless anchored, and glob patterns must match
+// the whole name.
+func (p Pattern) Match(name string) bool {
+	switch {
+	case p.re != nil:
+		return p.re.MatchString(name)
+	case p.glob:
+		// The syntax was checked by CompilePattern
+		matched, _ := path.Match(p.entry, name)
+		return matched
+	default:
+		return p.entry != "" && p.entry == name
+	}
+}
+
+// String returns the entry the pattern was compiled from
+func (p Pattern) String() string {
+	return p.entry
+}
+
+// pattern returns the compiled form of an entry. Entries that weren't
+// decoded from the file, e.g. those of an IncludesFile built in code, are
+// compiled when they are used, and match nothing if they are invalid.
+func (f IncludesFile) pattern(entry string) Pattern {
+	if pattern, ok := f.patterns[entry]; ok {
+		return pattern
+	}
+	pattern, _ := CompilePattern(entry)
+	return pattern
+}
+
+// matchesAny reports whether a name matches any of the entries
+func (f IncludesFile) matchesAny(entries []string, name string) bool {
+	for _, entry := range entries {
+		if f.pattern(entry).Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmatched returns a warning for each entry of the includes file that
+// matches none of the given models or queries, such as a misspelled name or
+// a pattern that is too narrow. A nil list of queries skips the query
+// entries, e.g. when queries weren't parsed.
+func Unmatched(includes IncludesFile, models, queries []string) []string {
+	var warnings []string
+	check := func(section string, entries, names []string) {
+		for _, entry := range entries {
+			pattern := includes.pattern(entry)
+			if slices.ContainsFunc(names, pattern.Match) {
+				continue
+			}
+			if IsPattern(entry) {
+				warnings = append(warnings, fmt.Sprintf("%s pattern %s matches nothing", section, entry))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("%s entry %s matches nothing", section, entry))
+			}
+		}
+	}
+
+	check("models", includes.Models, models)
+	check("exclude.models", includes.Exclude.Models, models)
+	if queries != nil {
+		check("queries", includes.Queries, queries)
+		check("exclude.queries", includes.Exclude.Queries, queries)
+	}
+	return warnings
+}
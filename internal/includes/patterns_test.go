@@ -0,0 +1,185 @@
+package includes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadIncludesFile_Patterns(t *testing.T) {
+	content := `models:
+- Book
+- "*Params"
+
+queries:
+- Get*
+- /^List.*ByMember$/
+- name: SearchBooks
+  service: Book
+
+exclude:
+  models:
+  - CreateLoanParams
+  queries:
+  - GetLoan
+`
+	path := filepath.Join(t.TempDir(), "includes.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write includes file: %v", err)
+	}
+
+	includesFile, err := LoadIncludesFile(path)
+	if err != nil {
+		t.Fatalf("LoadIncludesFile failed: %v", err)
+	}
+
+	// Entries are compiled once, when the file is decoded
+	if pattern := includesFile.patterns["/^List.*ByMember$/"]; pattern.re == nil {
+		t.Errorf("Expected a compiled regular expression, got %+v", pattern)
+	}
+
+	models := map[string]bool{
+		"Book":             true,
+		"CreateBookParams": true,
+		"CreateLoanParams": false, // Excluded
+		"Loan":             false,
+	}
+	for model, want := range models {
+		if got := IsModelIncluded(includesFile, model); got != want {
+			t.Errorf("IsModelIncluded(%s) = %t, want %t", model, got, want)
+		}
+	}
+
+	queries := map[string]bool{
+		"GetBook":                 true,
+		"GetLoan":                 false, // Excluded
+		"ListActiveLoansByMember": true,
+		"ListBooks":               false,
+		"SearchBooks":             true,
+		"ForgetBook":              false, // Globs match the whole name
+	}
+	for query, want := range queries {
+		if got := IsQueryIncluded(includesFile, query); got != want {
+			t.Errorf("IsQueryIncluded(%s) = %t, want %t", query, got, want)
+		}
+	}
+}
+
+func TestIsIncluded_OnlyExclusions(t *testing.T) {
+	includesFile := IncludesFile{Exclude: Exclusions{Models: []string{"Internal*"}}}
+
+	if includesFile.IsEmpty() {
+		t.Fatal("A file with exclusions is not empty")
+	}
+	if !IsModelIncluded(includesFile, "Book") {
+		t.Error("Models that aren't excluded should be included")
+	}
+	if IsModelIncluded(includesFile, "InternalNote") {
+		t.Error("Excluded models should not be included")
+	}
+	if !IsQueryIncluded(includesFile, "GetBook") {
+		t.Error("Queries that aren't excluded should be included")
+	}
+}
+
+func TestUnmatched(t *testing.T) {
+	includesFile := IncludesFile{
+		Models:  []string{"Book", "Bok", "*Params", "Author*"},
+		Queries: []string{"/^Get/", "/^Remove/"},
+		Exclude: Exclusions{Models: []string{"Secret"}},
+	}
+	models := []string{"Book", "CreateBookParams"}
+
+	warnings := Unmatched(includesFile, models, []string{"GetBook"})
+	want := []string{
+		"models entry Bok matches nothing",
+		"models pattern Author* matches nothing",
+		"exclude.models entry Secret matches nothing",
+		"queries pattern /^Remove/ matches nothing",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected warnings:\n%s\nwant:\n%s", strings.Join(warnings, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Query entries are skipped when the queries are unknown
+	if warnings := Unmatched(includesFile, models, nil); len(warnings) != 3 {
+		t.Errorf("Expected 3 warnings without queries, got %v", warnings)
+	}
+}
+
+func TestLoadIncludesFile_InvalidPatterns(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    string
+	}{
+		"regular expression": {
+			content: "models:\n- Book\n- /^Book(/\n",
+			want:    "line 3: invalid regular expression /^Book(/",
+		},
+		"glob": {
+			content: "exclude:\n  queries:\n  - Get[\n",
+			want:    `line 3: invalid pattern "Get["`,
+		},
+		"query": {
+			content: "queries:\n- GetBook\n- /[/\n",
+			want:    "line 3: invalid regular expression /[/",
+		},
+		"field rule message": {
+			content: "fields:\n  /(/:\n    phone: exclude\n",
+			want:    "line 2: invalid regular expression /(/",
+		},
+		"field rule field": {
+			content: "fields:\n  Member:\n    \"phone[\": exclude\n",
+			want:    `line 3: invalid pattern "phone["`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "includes.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("Failed to write includes file: %v", err)
+			}
+			_, err := LoadIncludesFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadIncludesFile error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		entry string
+		name  string
+		want  bool
+	}{
+		{"Book", "Book", true},
+		{"Book", "Books", false},
+		{"Get*", "GetBook", true},
+		{"Get*", "ForgetBook", false},
+		{"Get?ook", "GetBook", true},
+		{"/Book/", "ListBooksByAuthor", true},
+		{"/^Book$/", "Books", false},
+		{"/^(Get|List)/", "ListBooks", true},
+	}
+	for _, tt := range tests {
+		pattern, err := CompilePattern(tt.entry)
+		if err != nil {
+			t.Fatalf("CompilePattern(%q) failed: %v", tt.entry, err)
+		}
+		if got := pattern.Match(tt.name); got != tt.want {
+			t.Errorf("%s.Match(%q) = %t, want %t", tt.entry, tt.name, got, tt.want)
+		}
+	}
+
+	// Invalid entries of files built in code match nothing
+	includesFile := IncludesFile{Models: []string{"/(/", "Book["}}
+	if IsModelIncluded(includesFile, "Book") || IsModelIncluded(includesFile, "(") {
+		t.Error("Invalid patterns should match nothing")
+	}
+	if (Pattern{}).Match("") {
+		t.Error("The zero Pattern should match nothing")
+	}
+}
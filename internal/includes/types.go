@@ -1,9 +1,67 @@
 package includes
 
-// IncludesFile represents the structure of the includes YAML file
+// IncludesFile represents the structure of the includes YAML file. Entries
+// are exact names, glob patterns such as "Get*", or regular expressions
+// between slashes such as "/^List.*ByMember$/". Excluded entries win over
+// included ones:
+//
+//	models:
+//	- Book
+//	- "*Params"
+//	queries:
+//	- Get*
+//	exclude:
+//	  models:
+//	  - InternalNote
+//	  queries:
+//	  - /^Delete/
 type IncludesFile struct {
 	Models  []string `yaml:"models"`
 	Queries []string `yaml:"queries"`
+
+	// Exclude lists the models and queries that are never included
+	Exclude Exclusions `yaml:"exclude"`
+
+	// FieldRules exclude, rename or annotate fields of messages
+	FieldRules []FieldRule `yaml:"-"`
+
+	// QueryConfigs holds the structured query entries, keyed by query name
+	QueryConfigs map[string]QueryConfig `yaml:"-"`
+
+	// patterns are the compiled entries of the file, keyed by entry
+	patterns map[string]Pattern
+}
+
+// Exclusions is the exclude section of the includes file
+type Exclusions struct {
+	Models  []string `yaml:"models"`
+	Queries []string `yaml:"queries"`
+}
+
+// IsEmpty reports whether the file selects nothing, in which case all
+// models and queries are generated
+func (f IncludesFile) IsEmpty() bool {
+	return len(f.Models) == 0 && len(f.Queries) == 0 && len(f.Exclude.Models) == 0 && len(f.Exclude.Queries) == 0
+}
+
+// QueryConfig is the structured form of a query entry in the includes file.
+// It overrides how the query is exposed as an RPC:
+//
+//	queries:
+//	- GetBook
+//	- name: ListActiveLoansByMember
+//	  service: Loan
+//	  rpc: ListMemberLoans
+//	  streaming: server
+//	  pagination: false
+//	  description: Lists the active loans of a member
+type QueryConfig struct {
+	Name        string `yaml:"name"`        // Name of the sqlc query
+	Service     string `yaml:"service"`     // Target service, e.g. "Loan" or "LoanService"
+	RPC         string `yaml:"rpc"`         // Name of the generated RPC
+	Streaming   string `yaml:"streaming"`   // "none" or "server"
+	Pagination  *bool  `yaml:"pagination"`  // Whether to add pagination fields
+	Description string `yaml:"description"` // Comment for the generated RPC
 }
 
 // NewEmptyIncludesFile creates a new empty includes file
@@ -0,0 +1,494 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+// GenerateAIPServiceDefinitions creates resource-oriented service definitions
+// following the Google AIP standard methods (Get, List, Create, Update and Delete).
+// Each model with at least one matching sqlc query becomes a resource, and the
+// remaining queries are kept as custom methods on the service of the resource
+// they return or take.
+// Parameter types are mapped with typeConfig. Overrides, keyed by query name,
+// take precedence for custom methods.
+func GenerateAIPServiceDefinitions(queryMethods []QueryMethod, messages []ProtoMessage, typeConfig TypeMappingConfig, resourceDomain string, overrides map[string]MethodOverride) []ServiceDefinition {
+	// Create lookup maps for queries and messages
+	queryMap := make(map[string]QueryMethod)
+	for _, method := range queryMethods {
+		queryMap[method.Name] = method
+	}
+	messageMap := make(map[string]ProtoMessage)
+	for _, msg := range messages {
+		messageMap[msg.Name] = msg
+	}
+
+	// Track which queries have been mapped onto standard methods
+	usedQueries := make(map[string]bool)
+
+	var services []ServiceDefinition
+	serviceIndex := make(map[string]int)
+
+	for _, msg := range messages {
+		if !isResourceCandidate(msg) {
+			continue
+		}
+
+		entity := msg.Name
+		plural := Pluralize(entity)
+
+		get, hasGet := findQuery(queryMap, QueryTypeOne, "Get"+entity, "Get"+entity+"ByID", "Get"+entity+"ById")
+		list, hasList := findQuery(queryMap, QueryTypeMany, "List"+plural, "List"+entity)
+		create, hasCreate := findQuery(queryMap, "", "Create"+entity)
+		update, hasUpdate := findQuery(queryMap, "", "Update"+entity)
+		del, hasDelete := findQuery(queryMap, "", "Delete"+entity)
+
+		if !hasGet && !hasList && !hasCreate && !hasUpdate && !hasDelete {
+			continue
+		}
+
+		resource := newResourceDefinition(msg, resourceDomain, get, hasGet, typeConfig)
+		service := ServiceDefinition{
+			Name:        entity + "Service",
+			Description: fmt.Sprintf("Resource-oriented service for %s resources", entity),
+			Methods:     []ServiceMethod{},
+			Resource:    &resource,
+		}
+
+		if hasGet {
+			service.Methods = append(service.Methods, newAIPGetMethod(get, resource))
+			usedQueries[get.Name] = true
+		}
+		if hasList {
+			service.Methods = append(service.Methods, newAIPListMethod(list, resource, messageMap, typeConfig))
+			usedQueries[list.Name] = true
+		}
+		if hasCreate {
+			service.Methods = append(service.Methods, newAIPCreateMethod(create, resource))
+			usedQueries[create.Name] = true
+		}
+		if hasUpdate {
+			service.Methods = append(service.Methods, newAIPUpdateMethod(update, resource))
+			usedQueries[update.Name] = true
+		}
+		if hasDelete {
+			service.Methods = append(service.Methods, newAIPDeleteMethod(del, resource))
+			usedQueries[del.Name] = true
+		}
+
+		// Explicit descriptions also apply to standard methods
+		for i := range service.Methods {
+			if description := overrides[service.Methods[i].OriginalQuery.Name].Description; description != "" {
+				service.Methods[i].Description = description
+			}
+		}
+
+		serviceIndex[entity] = len(services)
+		serviceIndex[plural] = len(services)
+		services = append(services, service)
+	}
+
+	// Attach the remaining queries as custom methods to the service of the
+	// resource they act on
+	resources := make(map[string]bool)
+	for _, service := range services {
+		resources[service.Resource.Message] = true
+	}
+	for _, method := range queryMethods {
+		if usedQueries[method.Name] {
+			continue
+		}
+
+		override := overrides[method.Name]
+		entity := customMethodEntity(method, override, resources)
+		idx, ok := serviceIndex[entity]
+		if !ok {
+			idx = len(services)
+			serviceIndex[entity] = idx
+			services = append(services, ServiceDefinition{
+				Name:        entity + "Service",
+				Description: fmt.Sprintf("Service for %s operations", entity),
+				Methods:     []ServiceMethod{},
+			})
+		}
+
+		serviceMethod := newServiceMethod(method, entity, messageMap, typeConfig, override)
+		if resource := services[idx].Resource; resource != nil {
+			addressByResourceName(&serviceMethod, *resource)
+		}
+		services[idx].Methods = append(services[idx].Methods, serviceMethod)
+	}
+
+	return services
+}
+
+// customMethodEntity returns the entity whose service a custom method joins:
+// the one named by an override, else the resource the query returns or takes,
+// else the entity inferred from the query name
+func customMethodEntity(method QueryMethod, override MethodOverride, resources map[string]bool) string {
+	if override.Service != "" {
+		return serviceEntity(method.Name, override)
+	}
+	if resources[method.ReturnType] {
+		return method.ReturnType
+	}
+	for _, param := range method.ParamTypes {
+		if resources[param.Type] {
+			return param.Type
+		}
+	}
+	return serviceEntity(method.Name, override)
+}
+
+// addressByResourceName replaces the primary key in the request of a custom
+// method with the resource name, so that it acts on a single resource
+// (AIP-136), e.g. POST /v1/{name=loans/*}:returnBook
+func addressByResourceName(method *ServiceMethod, resource ResourceDefinition) {
+	if method.Params != nil {
+		return
+	}
+	for i, field := range method.RequestFields {
+		if (resource.IDName != "" && field.Name == resource.IDName) || field.Name == strcase.ToSnake(resource.Message)+"_id" {
+			name := resourceNameField(resource, "act on")
+			name.Number = field.Number
+			method.RequestFields[i] = name
+			return
+		}
+	}
+}
+
+// ApplyResourceAnnotations adds google.api.resource options and the resource
+// name field to the messages backing resource-oriented services
+func ApplyResourceAnnotations(messages []ProtoMessage, services []ServiceDefinition) {
+	for _, service := range services {
+		if service.Resource == nil {
+			continue
+		}
+
+		for i := range messages {
+			if messages[i].Name != service.Resource.Message {
+				continue
+			}
+
+			resource := service.Resource
+			option := fmt.Sprintf("(google.api.resource) = {type: %q pattern: %q singular: %q plural: %q",
+				resource.Type, resource.Pattern, resource.Singular, resource.Plural)
+			if resource.NameField != "name" {
+				option += fmt.Sprintf(" name_field: %q", resource.NameField)
+			}
+			messages[i].Options = append(messages[i].Options, option+"}")
+
+			// The resource name comes first, numbered after the columns
+			field := resourceNameMessageField(*resource, messages[i])
+			messages[i].Fields = append([]ProtoField{field}, messages[i].Fields...)
+		}
+	}
+}
+
+// resourceNameMessageField creates the field holding the resource name of a
+// resource message (AIP-122). ToProto derives it from the primary key, and
+// FromProto ignores it, since the primary key column is read instead.
+func resourceNameMessageField(resource ResourceDefinition, msg ProtoMessage) ProtoField {
+	field := ProtoField{
+		Name:           resource.NameField,
+		Type:           "string",
+		Number:         nextFieldNumber(msg),
+		Comment:        fmt.Sprintf("The resource name of the %s. Format: %s", resource.Singular, resource.Pattern),
+		Options:        []string{"(google.api.field_behavior) = IDENTIFIER"},
+		OutputOnly:     true,
+		ConversionCode: `""`,
+	}
+
+	// Without a primary key on the model, the name can't be derived
+	if resource.IDName != "" {
+		// The ToProto conversion of the key is its string form, e.g.
+		// uuidToString(in.ID) for UUID keys
+		key := "in." + resource.IDField
+		for _, idField := range msg.Fields {
+			if idField.Name == resource.IDName && idField.ConversionCode != "" {
+				key = idField.ConversionCode
+			}
+		}
+		format := strings.Replace(resource.Pattern, "{"+strcase.ToSnake(resource.Message)+"}", "%v", 1)
+		field.ConversionCode = fmt.Sprintf("fmt.Sprintf(%q, %s)", format, key)
+	}
+	return field
+}
+
+// nextFieldNumber returns the lowest field number above those in use or
+// reserved
+func nextFieldNumber(msg ProtoMessage) int {
+	number := 0
+	for _, field := range msg.Fields {
+		number = max(number, field.Number)
+	}
+	for _, reserved := range msg.Reserved {
+		number = max(number, reserved)
+	}
+	return number + 1
+}
+
+// isResourceCandidate reports whether a message looks like a table model
+// rather than a query parameter or row struct
+func isResourceCandidate(msg ProtoMessage) bool {
+	if msg.Name == "Queries" {
+		return false
+	}
+	return !strings.HasSuffix(msg.Name, "Params") && !strings.HasSuffix(msg.Name, "Row")
+}
+
+// findQuery returns the first query matching one of the candidate names and,
+// if queryType is set, the expected query type
+func findQuery(queryMap map[string]QueryMethod, queryType QueryType, names ...string) (QueryMethod, bool) {
+	for _, name := range names {
+		if method, ok := queryMap[name]; ok && (queryType == "" || method.Type == queryType) {
+			return method, true
+		}
+	}
+	return QueryMethod{}, false
+}
+
+// newResourceDefinition derives the resource type and name pattern for a
+// model, using its primary key as the resource ID
+func newResourceDefinition(msg ProtoMessage, resourceDomain string, get QueryMethod, hasGet bool, typeConfig TypeMappingConfig) ResourceDefinition {
+	singular := strcase.ToLowerCamel(msg.Name)
+	plural := strcase.ToLowerCamel(Pluralize(msg.Name))
+
+	resource := ResourceDefinition{
+		Type:      resourceDomain + "/" + msg.Name,
+		Pattern:   fmt.Sprintf("%s/{%s}", plural, strcase.ToSnake(msg.Name)),
+		Singular:  singular,
+		Plural:    plural,
+		Message:   msg.Name,
+		NameField: "name",
+	}
+
+	// A name column keeps its field, and the resource name moves aside
+	for hasField(msg, resource.NameField) {
+		resource.NameField = "resource_" + resource.NameField
+	}
+
+	// Prefer an ID column on the model itself, e.g. ID or BookID. The sqlc
+	// names don't depend on the field style, unlike the proto names.
+	entityID := msg.SQLCStruct + "ID"
+	if msg.SQLCStruct == "" {
+		entityID = msg.Name + "ID"
+	}
+	for _, field := range msg.Fields {
+		if field.SQLCName == "ID" || field.SQLCName == entityID || field.Name == "id" || field.Name == strcase.ToSnake(msg.Name)+"_id" {
+			resource.IDField = field.SQLCName
+			resource.IDName = field.Name
+			resource.IDType = field.Type
+			return resource
+		}
+	}
+
+	// Fall back to the single parameter of the Get query
+	if hasGet && len(get.ParamTypes) == 1 {
+		resource.IDField = pascalCase(get.ParamTypes[0].Name)
+		resource.IDType = mapGoTypeToProtoType(get.ParamTypes[0].Type, typeConfig)
+	}
+
+	return resource
+}
+
+// hasField reports whether a message has a field with the given proto name
+func hasField(msg ProtoMessage, name string) bool {
+	for _, field := range msg.Fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceNameField creates the required `name` field referencing a resource
+func resourceNameField(resource ResourceDefinition, action string) ProtoField {
+	return ProtoField{
+		Name:    "name",
+		Type:    "string",
+		Number:  1,
+		Comment: fmt.Sprintf("The name of the %s to %s. Format: %s", resource.Singular, action, resource.Pattern),
+		Options: []string{
+			"(google.api.field_behavior) = REQUIRED",
+			fmt.Sprintf("(google.api.resource_reference).type = %q", resource.Type),
+		},
+	}
+}
+
+// newAIPGetMethod creates a standard Get method (AIP-131)
+func newAIPGetMethod(query QueryMethod, resource ResourceDefinition) ServiceMethod {
+	return ServiceMethod{
+		Name:                "Get" + resource.Message,
+		Description:         query.Comment,
+		RequestType:         "Get" + resource.Message + "Request",
+		ResponseType:        resource.Message,
+		RequestFields:       []ProtoField{resourceNameField(resource, "retrieve")},
+		OriginalQuery:       &query,
+		StandardMethod:      "Get",
+		OmitResponseMessage: true,
+	}
+}
+
+// newAIPListMethod creates a standard List method (AIP-132). Parameters of the
+// underlying query other than limit and offset are exposed as request fields.
+func newAIPListMethod(query QueryMethod, resource ResourceDefinition, messageMap map[string]ProtoMessage, typeConfig TypeMappingConfig) ServiceMethod {
+	plural := Pluralize(resource.Message)
+
+	requestFields := []ProtoField{
+		{
+			Name:    "page_size",
+			Type:    "int32",
+			Number:  1,
+			Comment: fmt.Sprintf("The maximum number of %s to return", resource.Plural),
+		},
+		{
+			Name:    "page_token",
+			Type:    "string",
+			Number:  2,
+			Comment: "A page token received from a previous call",
+		},
+	}
+
+	for _, param := range query.ParamTypes {
+		if msg, ok := messageMap[param.Type]; ok {
+			for _, field := range msg.Fields {
+				if field.Name == "limit" || field.Name == "offset" {
+					continue
+				}
+				requestFields = append(requestFields, ProtoField{
+					Name:       field.Name,
+					Type:       field.Type,
+					Number:     len(requestFields) + 1,
+					IsRepeated: field.IsRepeated,
+					Comment:    field.Comment,
+				})
+			}
+			continue
+		}
+
+		name := strcase.ToSnake(param.Name)
+		if name == "limit" || name == "offset" {
+			continue
+		}
+		requestFields = append(requestFields, ProtoField{
+			Name:    name,
+			Type:    mapGoTypeToProtoType(param.Type, typeConfig),
+			Number:  len(requestFields) + 1,
+			Comment: fmt.Sprintf("%s parameter", param.Name),
+		})
+	}
+
+	return ServiceMethod{
+		Name:          "List" + plural,
+		Description:   query.Comment,
+		RequestType:   "List" + plural + "Request",
+		ResponseType:  "List" + plural + "Response",
+		RequestFields: requestFields,
+		ResponseFields: []ProtoField{
+			{
+				Name:       strcase.ToSnake(plural),
+				Type:       resource.Message,
+				Number:     1,
+				IsRepeated: true,
+				Comment:    fmt.Sprintf("The %s", resource.Plural),
+			},
+			{
+				Name:    "next_page_token",
+				Type:    "string",
+				Number:  2,
+				Comment: "A token to retrieve the next page, empty if there are no more pages",
+			},
+		},
+		OriginalQuery:  &query,
+		StandardMethod: "List",
+	}
+}
+
+// newAIPCreateMethod creates a standard Create method (AIP-133)
+func newAIPCreateMethod(query QueryMethod, resource ResourceDefinition) ServiceMethod {
+	return ServiceMethod{
+		Name:         "Create" + resource.Message,
+		Description:  query.Comment,
+		RequestType:  "Create" + resource.Message + "Request",
+		ResponseType: resource.Message,
+		RequestFields: []ProtoField{
+			{
+				Name:    strcase.ToSnake(resource.Message),
+				Type:    resource.Message,
+				Number:  1,
+				Comment: fmt.Sprintf("The %s to create", resource.Singular),
+				Options: []string{"(google.api.field_behavior) = REQUIRED"},
+			},
+		},
+		OriginalQuery:       &query,
+		StandardMethod:      "Create",
+		OmitResponseMessage: true,
+	}
+}
+
+// newAIPUpdateMethod creates a standard Update method (AIP-134)
+func newAIPUpdateMethod(query QueryMethod, resource ResourceDefinition) ServiceMethod {
+	return ServiceMethod{
+		Name:         "Update" + resource.Message,
+		Description:  query.Comment,
+		RequestType:  "Update" + resource.Message + "Request",
+		ResponseType: resource.Message,
+		RequestFields: []ProtoField{
+			{
+				Name:    strcase.ToSnake(resource.Message),
+				Type:    resource.Message,
+				Number:  1,
+				Comment: fmt.Sprintf("The %s to update", resource.Singular),
+				Options: []string{"(google.api.field_behavior) = REQUIRED"},
+			},
+			{
+				Name:    "update_mask",
+				Type:    "google.protobuf.FieldMask",
+				Number:  2,
+				Comment: "The list of fields to update",
+			},
+		},
+		OriginalQuery:       &query,
+		StandardMethod:      "Update",
+		OmitResponseMessage: true,
+	}
+}
+
+// newAIPDeleteMethod creates a standard Delete method (AIP-135)
+func newAIPDeleteMethod(query QueryMethod, resource ResourceDefinition) ServiceMethod {
+	return ServiceMethod{
+		Name:                "Delete" + resource.Message,
+		Description:         query.Comment,
+		RequestType:         "Delete" + resource.Message + "Request",
+		ResponseType:        "google.protobuf.Empty",
+		RequestFields:       []ProtoField{resourceNameField(resource, "delete")},
+		OriginalQuery:       &query,
+		StandardMethod:      "Delete",
+		OmitResponseMessage: true,
+	}
+}
+
+// Pluralize returns a naive English plural of a PascalCase name
+func Pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "y") && !strings.HasSuffix(name, "ay") && !strings.HasSuffix(name, "ey") && !strings.HasSuffix(name, "oy"):
+		return strings.TrimSuffix(name, "y") + "ies"
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	default:
+		return name + "s"
+	}
+}
+
+// SliceName returns the name used for the slice converters of a struct, e.g.
+// Books for Book. Names already ending in "s", such as CreateBookParams, get
+// a Slice suffix instead of an awkward plural.
+func SliceName(name string) string {
+	if strings.HasSuffix(name, "s") {
+		return name + "Slice"
+	}
+	return Pluralize(name)
+}
@@ -0,0 +1,260 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAIPServiceDefinitions(t *testing.T) {
+	messages := []ProtoMessage{
+		{
+			Name:       "Book",
+			SQLCStruct: "Book",
+			Fields: []ProtoField{
+				{Name: "id", Type: "int32", SQLCName: "ID", Number: 1},
+				{Name: "title", Type: "string", SQLCName: "Title", Number: 2},
+			},
+		},
+		{
+			Name:       "ListBooksParams",
+			SQLCStruct: "ListBooksParams",
+			Fields: []ProtoField{
+				{Name: "genre", Type: "string", SQLCName: "Genre", Number: 1},
+				{Name: "limit", Type: "int32", SQLCName: "Limit", Number: 2},
+				{Name: "offset", Type: "int32", SQLCName: "Offset", Number: 3},
+			},
+		},
+	}
+
+	queryMethods := []QueryMethod{
+		{Name: "GetBook", Type: QueryTypeOne, ParamTypes: []ParamType{{Name: "id", Type: "int32"}}, ReturnType: "Book"},
+		{Name: "ListBooks", Type: QueryTypeMany, ParamTypes: []ParamType{{Name: "arg", Type: "ListBooksParams"}}, ReturnType: "Book", IsArray: true},
+		{Name: "CreateBook", Type: QueryTypeOne, ReturnType: "Book"},
+		{Name: "UpdateBook", Type: QueryTypeOne, ReturnType: "Book"},
+		{Name: "DeleteBook", Type: QueryTypeExec, ParamTypes: []ParamType{{Name: "id", Type: "int32"}}},
+		{Name: "SearchBooks", Type: QueryTypeMany, ReturnType: "Book", IsArray: true},
+		{Name: "ReturnBook", Type: QueryTypeOne, ParamTypes: []ParamType{{Name: "id", Type: "int32"}}, ReturnType: "Book"},
+		{Name: "PurgeSessions", Type: QueryTypeExec},
+	}
+
+	services := GenerateAIPServiceDefinitions(queryMethods, messages, GetTypeMapConfig(), "library.example.com", nil)
+
+	if len(services) != 2 {
+		t.Fatalf("Expected 2 services, got %d", len(services))
+	}
+
+	bookService := services[0]
+	if bookService.Name != "BookService" {
+		t.Errorf("Expected first service to be BookService, got %s", bookService.Name)
+	}
+	if bookService.Resource == nil {
+		t.Fatalf("BookService should have a resource definition")
+	}
+
+	resource := bookService.Resource
+	if resource.Type != "library.example.com/Book" {
+		t.Errorf("Expected resource type library.example.com/Book, got %s", resource.Type)
+	}
+	if resource.Pattern != "books/{book}" {
+		t.Errorf("Expected pattern books/{book}, got %s", resource.Pattern)
+	}
+	if resource.IDField != "ID" || resource.IDType != "int32" {
+		t.Errorf("Expected primary key ID of type int32, got %s of type %s", resource.IDField, resource.IDType)
+	}
+
+	expectedMethods := []struct {
+		name         string
+		standard     string
+		requestType  string
+		responseType string
+	}{
+		{"GetBook", "Get", "GetBookRequest", "Book"},
+		{"ListBooks", "List", "ListBooksRequest", "ListBooksResponse"},
+		{"CreateBook", "Create", "CreateBookRequest", "Book"},
+		{"UpdateBook", "Update", "UpdateBookRequest", "Book"},
+		{"DeleteBook", "Delete", "DeleteBookRequest", "google.protobuf.Empty"},
+		{"SearchBooks", "", "SearchBooksRequest", "SearchBooksResponse"},
+		{"ReturnBook", "", "ReturnBookRequest", "ReturnBookResponse"},
+	}
+
+	if len(bookService.Methods) != len(expectedMethods) {
+		t.Fatalf("Expected %d methods on BookService, got %d", len(expectedMethods), len(bookService.Methods))
+	}
+
+	for i, expected := range expectedMethods {
+		method := bookService.Methods[i]
+		if method.Name != expected.name {
+			t.Errorf("Method %d: expected %s, got %s", i, expected.name, method.Name)
+		}
+		if method.StandardMethod != expected.standard {
+			t.Errorf("Method %s: expected standard method %q, got %q", method.Name, expected.standard, method.StandardMethod)
+		}
+		if method.RequestType != expected.requestType {
+			t.Errorf("Method %s: expected request type %s, got %s", method.Name, expected.requestType, method.RequestType)
+		}
+		if method.ResponseType != expected.responseType {
+			t.Errorf("Method %s: expected response type %s, got %s", method.Name, expected.responseType, method.ResponseType)
+		}
+	}
+
+	// Get, Delete and custom methods acting on a single resource take a
+	// resource name
+	for _, idx := range []int{0, 4, 6} {
+		fields := bookService.Methods[idx].RequestFields
+		if len(fields) != 1 || fields[0].Name != "name" {
+			t.Errorf("Method %s should have a single name field", bookService.Methods[idx].Name)
+		}
+	}
+
+	// List exposes page_size, page_token and the non-pagination query parameters
+	listFields := bookService.Methods[1].RequestFields
+	var listFieldNames []string
+	for _, field := range listFields {
+		listFieldNames = append(listFieldNames, field.Name)
+	}
+	if strings.Join(listFieldNames, ",") != "page_size,page_token,genre" {
+		t.Errorf("Unexpected List request fields: %v", listFieldNames)
+	}
+
+	// Update carries a field mask
+	updateFields := bookService.Methods[3].RequestFields
+	if len(updateFields) != 2 || updateFields[1].Type != "google.protobuf.FieldMask" {
+		t.Errorf("Update request should have a google.protobuf.FieldMask update_mask field")
+	}
+
+	// Queries that can't be associated with a resource keep the rpc style
+	if services[1].Resource != nil || services[1].Methods[0].Name != "PurgeSessions" {
+		t.Errorf("Expected PurgeSessions in a separate non-resource service")
+	}
+
+	// Resource annotations are added to the backing message
+	ApplyResourceAnnotations(messages, services)
+	if len(messages[0].Options) != 1 || !strings.HasPrefix(messages[0].Options[0], "(google.api.resource)") {
+		t.Errorf("Expected google.api.resource option on Book, got %v", messages[0].Options)
+	}
+	if len(messages[1].Options) != 0 {
+		t.Errorf("Expected no options on ListBooksParams, got %v", messages[1].Options)
+	}
+
+	// The resource name field comes first, derived from the primary key
+	name := messages[0].Fields[0]
+	if name.Name != "name" || !name.OutputOnly || name.ConversionCode != `fmt.Sprintf("books/%v", in.ID)` {
+		t.Errorf("Expected a name field on Book, got %+v", name)
+	}
+}
+
+func TestApplyResourceAnnotationsNameColumn(t *testing.T) {
+	messages := []ProtoMessage{{
+		Name: "Member",
+		Fields: []ProtoField{
+			{Name: "id", Type: "int32", Number: 1, SQLCName: "ID"},
+			{Name: "name", Type: "string", Number: 2, SQLCName: "Name"},
+		},
+		Reserved: []int{5},
+	}}
+	queryMethods := []QueryMethod{
+		{Name: "GetMember", Type: QueryTypeOne, ParamTypes: []ParamType{{Name: "id", Type: "int32"}}, ReturnType: "Member"},
+	}
+
+	services := GenerateAIPServiceDefinitions(queryMethods, messages, GetTypeMapConfig(), "library.example.com", nil)
+	if len(services) != 1 || services[0].Resource == nil {
+		t.Fatalf("Expected a Member resource, got %+v", services)
+	}
+	if got := services[0].Resource.NameField; got != "resource_name" {
+		t.Errorf("Expected NameField resource_name, got %q", got)
+	}
+
+	ApplyResourceAnnotations(messages, services)
+	if len(messages[0].Options) != 1 || !strings.Contains(messages[0].Options[0], `name_field: "resource_name"`) {
+		t.Errorf("Expected name_field in the resource option, got %v", messages[0].Options)
+	}
+
+	// The name column is kept, and the resource name is numbered after the
+	// reserved numbers
+	fields := messages[0].Fields
+	if len(fields) != 3 || fields[0].Name != "resource_name" || fields[0].Number != 6 || fields[2].Name != "name" {
+		t.Errorf("Expected resource_name = 6 before the columns, got %+v", fields)
+	}
+}
+
+func TestApplyResourceAnnotationsUUIDKey(t *testing.T) {
+	messages := []ProtoMessage{{
+		Name: "Author",
+		Fields: []ProtoField{
+			{Name: "id", Type: "string", Number: 1, SQLCName: "ID", GoType: "uuid.UUID"},
+			{Name: "name", Type: "string", Number: 2, SQLCName: "Name", GoType: "string"},
+		},
+	}}
+	GenerateConversionCode(messages, GetTypeMapConfig())
+	queryMethods := []QueryMethod{
+		{Name: "GetAuthor", Type: QueryTypeOne, ParamTypes: []ParamType{{Name: "id", Type: "uuid.UUID"}}, ReturnType: "Author"},
+	}
+
+	services := GenerateAIPServiceDefinitions(queryMethods, messages, GetTypeMapConfig(), "library.example.com", nil)
+	ApplyResourceAnnotations(messages, services)
+
+	// The key is formatted in its string form, not as a byte array
+	name := messages[0].Fields[0]
+	if name.ConversionCode != `fmt.Sprintf("authors/%v", uuidToString(in.ID))` {
+		t.Errorf("Expected the resource name from the string form of the UUID, got %s", name.ConversionCode)
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := map[string]string{
+		"Book":     "Books",
+		"Category": "Categories",
+		"Address":  "Addresses",
+		"Key":      "Keys",
+		"Box":      "Boxes",
+	}
+
+	for input, expected := range tests {
+		if got := Pluralize(input); got != expected {
+			t.Errorf("Pluralize(%s): expected %s, got %s", input, expected, got)
+		}
+	}
+}
+
+func TestSliceName(t *testing.T) {
+	tests := map[string]string{
+		"Book":             "Books",
+		"Category":         "Categories",
+		"CreateBookParams": "CreateBookParamsSlice",
+		"Address":          "AddressSlice",
+	}
+
+	for input, expected := range tests {
+		if got := SliceName(input); got != expected {
+			t.Errorf("SliceName(%s): expected %s, got %s", input, expected, got)
+		}
+	}
+}
+
+func TestApplyResourceAnnotationsEntityIDKey(t *testing.T) {
+	// With the json field style, the BookID column is named bookId
+	messages := []ProtoMessage{{
+		Name:       "Book",
+		SQLCStruct: "Book",
+		Fields: []ProtoField{
+			{Name: "title", Type: "string", Number: 1, SQLCName: "Title", GoType: "string"},
+			{Name: "bookId", Type: "int64", Number: 2, SQLCName: "BookID", GoType: "int64"},
+		},
+	}}
+	GenerateConversionCode(messages, GetTypeMapConfig())
+	queryMethods := []QueryMethod{
+		{Name: "GetBook", Type: QueryTypeOne, ParamTypes: []ParamType{{Name: "bookID", Type: "int64"}}, ReturnType: "Book"},
+	}
+
+	services := GenerateAIPServiceDefinitions(queryMethods, messages, GetTypeMapConfig(), "library.example.com", nil)
+	resource := services[0].Resource
+	if resource == nil || resource.IDField != "BookID" || resource.IDName != "bookId" {
+		t.Fatalf("Expected the BookID column as the key, got %+v", resource)
+	}
+
+	ApplyResourceAnnotations(messages, services)
+	name := messages[0].Fields[0]
+	if name.ConversionCode != `fmt.Sprintf("books/%v", in.BookID)` {
+		t.Errorf("Expected the resource name from BookID, got %s", name.ConversionCode)
+	}
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/boomskats/sqlc2proto/internal/cache"
+)
+
+func TestParseCache(t *testing.T) {
+	dir := t.TempDir()
+	models := "package db\n\ntype Book struct {\n\tID    int64  `json:\"id\"`\n\tTitle string `json:\"title\"`\n}\n"
+	querier := "package db\n\nimport \"context\"\n\ntype Querier interface {\n\tGetBook(ctx context.Context, id int64) (Book, error)\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "models.go"), []byte(models), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "querier.go"), []byte(querier), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cache.Open(t.TempDir(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func() ([]ProtoMessage, []QueryMethod) {
+		pkg, err := LoadPackage(dir)
+		if err != nil {
+			t.Fatalf("LoadPackage failed: %v", err)
+		}
+		pkg.Cache = c
+		messages, err := pkg.Messages("json", DefaultTypeMappingConfig())
+		if err != nil {
+			t.Fatalf("Messages failed: %v", err)
+		}
+		methods, err := pkg.QueryMethods()
+		if err != nil {
+			t.Fatalf("QueryMethods failed: %v", err)
+		}
+		return messages, methods
+	}
+
+	messages, methods := parse()
+	if hits, _ := c.Stats(); hits != 0 {
+		t.Errorf("Expected no hits on the first run, got %d", hits)
+	}
+
+	// Unchanged files come from the cache with the same results
+	cachedMessages, cachedMethods := parse()
+	if hits, _ := c.Stats(); hits != 2 {
+		t.Errorf("Expected 2 hits on the second run, got %d", hits)
+	}
+	if !reflect.DeepEqual(messages, cachedMessages) {
+		t.Errorf("Expected cached messages %+v, got %+v", messages, cachedMessages)
+	}
+	if !reflect.DeepEqual(methods, cachedMethods) {
+		t.Errorf("Expected cached methods %+v, got %+v", methods, cachedMethods)
+	}
+
+	// A changed file is parsed again
+	models += "\ntype Author struct {\n\tID int64 `json:\"id\"`\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "models.go"), []byte(models), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	messages, _ = parse()
+	if len(messages) != 2 {
+		t.Errorf("Expected 2 messages after the change, got %d", len(messages))
+	}
+	if hits, _ := c.Stats(); hits != 3 {
+		t.Errorf("Expected only the querier to be cached, got %d hits", hits)
+	}
+
+	// Other type mappings don't reuse the cached messages
+	pkg, err := LoadPackage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg.Cache = c
+	typeConfig := NewTypeMapConfig(map[string]string{"int64": "string"}, nil)
+	messages, err = pkg.Messages("json", typeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := c.Stats(); hits != 3 || messages[0].Fields[0].Type != "string" {
+		t.Errorf("Expected the messages to be parsed with the new mappings, got %d hits and %+v", hits, messages[0].Fields[0])
+	}
+}
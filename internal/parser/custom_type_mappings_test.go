@@ -4,36 +4,47 @@ import (
 	"testing"
 )
 
-func TestAddCustomNullableTypeMappings(t *testing.T) {
-	// Save original mappings to restore after test
-	originalNullableTypeMappings := make(map[string]string)
-	for k, v := range NullableTypeMapping {
-		originalNullableTypeMappings[k] = v
-	}
-	defer func() {
-		// Restore original mappings
-		NullableTypeMapping = originalNullableTypeMappings
-	}()
-
-	// Test adding new mappings
+func TestNewTypeMapConfig(t *testing.T) {
+	customMappings := map[string]string{
+		"custom.Type": "custom.ProtoType",
+		"time.Time":   "custom.Timestamp", // Override existing mapping
+	}
 	customNullableMappings := map[string]string{
 		"CustomNullableType":                        "string",
 		"github.com/shopspring/decimal.NullDecimal": "string",
 		"sql.NullString":                            "google.protobuf.StringValue", // Override existing mapping
 	}
 
-	AddCustomNullableTypeMappings(customNullableMappings)
+	config := NewTypeMapConfig(customMappings, customNullableMappings)
 
 	// Verify new mappings were added
-	if NullableTypeMapping["CustomNullableType"] != "string" {
-		t.Errorf("Expected CustomNullableType to be mapped to string, got %s", NullableTypeMapping["CustomNullableType"])
+	if config.StandardTypes["custom.Type"] != "custom.ProtoType" {
+		t.Errorf("Expected custom.Type to be mapped to custom.ProtoType, got %s", config.StandardTypes["custom.Type"])
+	}
+	if config.NullableTypes["CustomNullableType"] != "string" {
+		t.Errorf("Expected CustomNullableType to be mapped to string, got %s", config.NullableTypes["CustomNullableType"])
+	}
+	if config.NullableTypes["github.com/shopspring/decimal.NullDecimal"] != "string" {
+		t.Errorf("Expected decimal.NullDecimal to be mapped to string, got %s", config.NullableTypes["github.com/shopspring/decimal.NullDecimal"])
 	}
-	if NullableTypeMapping["github.com/shopspring/decimal.NullDecimal"] != "string" {
-		t.Errorf("Expected decimal.NullDecimal to be mapped to string, got %s", NullableTypeMapping["github.com/shopspring/decimal.NullDecimal"])
+
+	// Verify existing mappings were overridden, and the others kept
+	if config.StandardTypes["time.Time"] != "custom.Timestamp" {
+		t.Errorf("Expected time.Time to be overridden to custom.Timestamp, got %s", config.StandardTypes["time.Time"])
+	}
+	if config.NullableTypes["sql.NullString"] != "google.protobuf.StringValue" {
+		t.Errorf("Expected sql.NullString to be overridden to google.protobuf.StringValue, got %s", config.NullableTypes["sql.NullString"])
+	}
+	if config.StandardTypes["int64"] != "int64" {
+		t.Errorf("Expected int64 to keep its mapping, got %s", config.StandardTypes["int64"])
 	}
 
-	// Verify existing mapping was overridden
-	if NullableTypeMapping["sql.NullString"] != "google.protobuf.StringValue" {
-		t.Errorf("Expected sql.NullString to be overridden to google.protobuf.StringValue, got %s", NullableTypeMapping["sql.NullString"])
+	// The defaults are left unchanged
+	defaults := GetTypeMapConfig()
+	if defaults.StandardTypes["time.Time"] != "google.protobuf.Timestamp" || defaults.NullableTypes["sql.NullString"] != "string" {
+		t.Errorf("NewTypeMapConfig changed the default mappings")
+	}
+	if _, ok := defaults.StandardTypes["custom.Type"]; ok {
+		t.Errorf("NewTypeMapConfig added custom.Type to the default mappings")
 	}
 }
@@ -1,16 +1,11 @@
 package parser
 
 import (
-	"maps"
 	"path/filepath"
 	"testing"
 )
 
 func TestCustomTypeMappings(t *testing.T) {
-	// Save original mappings
-	originalStandardMappings := maps.Clone(TypeMapping)
-	originalNullableMappings := maps.Clone(NullableTypeMapping)
-
 	// Create a temporary test file with custom types
 	tempFile := filepath.Join(t.TempDir(), "custom_types.go")
 
@@ -45,14 +40,10 @@ type CustomTypes struct {
 		"custom.Nullable": "string",
 	}
 
-	// Add custom mappings to the global variables
-	AddCustomTypeMappings(customMappings)
-	AddCustomNullableTypeMappings(customNullableMappings)
-
-	// Get a config that includes the custom mappings we just added
+	// Get a config that includes the custom mappings
 	config := ParserConfig{
 		FieldStyle: "json",
-		TypeConfig: GetTypeMapConfig(), // This should now include our custom mappings
+		TypeConfig: NewTypeMapConfig(customMappings, customNullableMappings),
 	}
 
 	// Process the file with the updated config
@@ -108,10 +99,6 @@ type CustomTypes struct {
 			t.Errorf("Field %s: expected IsOptional=%v, got %v", field.Name, expected.IsOptional, field.IsOptional)
 		}
 	}
-
-	// Restore original mappings for other tests
-	TypeMapping = originalStandardMappings
-	NullableTypeMapping = originalNullableMappings
 }
 
 func TestTypeMapping(t *testing.T) {
@@ -0,0 +1,276 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/iancoleman/strcase"
+)
+
+// HTTP verbs used in google.api.http rules
+const (
+	HTTPGet    = "get"
+	HTTPPost   = "post"
+	HTTPPatch  = "patch"
+	HTTPDelete = "delete"
+)
+
+// readPrefixes are method name prefixes of queries that don't modify data
+var readPrefixes = []string{"Get", "List", "Find", "Search", "Lookup", "Count"}
+
+// writeVerbs are words that make a method with a read prefix modify data
+// when joined to it, e.g. GetOrCreateLoan or FindAndLockCopy
+var writeVerbs = []string{"Create", "Add", "Insert", "Upsert", "Update", "Set", "Reset", "Delete", "Remove", "Lock", "Mark", "Save"}
+
+// execAnnotations are the sqlc annotations of queries that return no rows
+var execAnnotations = []string{"exec", "execrows", "execresult", "execlastid", "batchexec", "copyfrom"}
+
+// HTTPVerb determines the HTTP verb for a service method from its AIP
+// standard method kind, its name and the underlying query kind. Methods
+// whose query may modify data are never GET, whatever their name.
+func HTTPVerb(method ServiceMethod) string {
+	switch method.StandardMethod {
+	case "Get", "List":
+		return HTTPGet
+	case "Create":
+		return HTTPPost
+	case "Update":
+		return HTTPPatch
+	case "Delete":
+		return HTTPDelete
+	}
+
+	read := method.OriginalQuery == nil || isReadQuery(*method.OriginalQuery)
+	switch {
+	case hasWordPrefix(method.Name, "Create", "Add", "Insert"):
+		return HTTPPost
+	case hasWordPrefix(method.Name, "Update", "Set"):
+		return HTTPPatch
+	case hasWordPrefix(method.Name, "Delete", "Remove"):
+		return HTTPDelete
+	case hasWordPrefix(method.Name, readPrefixes...) && read && !joinsWriteVerb(method.Name):
+		return HTTPGet
+	}
+
+	// Other :many queries are reads; :one and :exec queries may have side
+	// effects (e.g. UPDATE ... RETURNING), so they are exposed as POST
+	if read && method.OriginalQuery != nil && method.OriginalQuery.Type == QueryTypeMany {
+		return HTTPGet
+	}
+	return HTTPPost
+}
+
+// isReadQuery reports whether a query returns rows without modifying data,
+// as far as sqlc's annotation and SQL tell
+func isReadQuery(query QueryMethod) bool {
+	if slices.Contains(execAnnotations, query.Annotation) || query.Type == QueryTypeExec || query.ReturnType == "" {
+		return false
+	}
+	if query.SQL == "" {
+		return true
+	}
+	// Data-modifying statements may also hide in a WITH clause
+	words := strings.Fields(strings.ToUpper(stripSQLComments(query.SQL)))
+	if len(words) == 0 || (words[0] != "SELECT" && words[0] != "WITH") {
+		return false
+	}
+	for _, word := range words {
+		switch strings.TrimLeft(word, "(") {
+		case "INSERT", "UPDATE", "DELETE", "MERGE":
+			return false
+		}
+	}
+	return true
+}
+
+// stripSQLComments removes the -- line comments of a query
+func stripSQLComments(sql string) string {
+	lines := strings.Split(sql, "\n")
+	for i, line := range lines {
+		if before, _, found := strings.Cut(line, "--"); found {
+			lines[i] = before
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hasWordPrefix reports whether a CamelCase name starts with one of the
+// prefixes as a whole word, e.g. SetTitle but not SettleFine
+func hasWordPrefix(name string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) && (len(name) == len(prefix) || unicode.IsUpper(rune(name[len(prefix)]))) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinsWriteVerb reports whether a name joins a write verb with And or Or,
+// e.g. GetOrCreateLoan
+func joinsWriteVerb(name string) bool {
+	words := camelWords(name)
+	for i := 1; i+1 < len(words); i++ {
+		if (words[i] == "And" || words[i] == "Or") && slices.Contains(writeVerbs, words[i+1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// camelWords splits a CamelCase name into its words
+func camelWords(name string) []string {
+	var words []string
+	start := 0
+	for i := 1; i < len(name); i++ {
+		if unicode.IsUpper(rune(name[i])) {
+			words = append(words, name[start:i])
+			start = i
+		}
+	}
+	return append(words, name[start:])
+}
+
+// IsReadMethod reports whether a service method has no side effects
+func IsReadMethod(method ServiceMethod) bool {
+	return HTTPVerb(method) == HTTPGet
+}
+
+// DeriveHTTPRule builds a google.api.http option for a service method.
+// Standard methods map onto the resource collection (e.g. GET /v1/books/{id}),
+// other methods become custom methods (e.g. POST /v1/loans/{id}:returnBook).
+func DeriveHTTPRule(service ServiceDefinition, method ServiceMethod, pathPrefix string) string {
+	verb := HTTPVerb(method)
+	base := strings.TrimSuffix(pathPrefix, "/")
+
+	var path, body string
+	if service.Resource != nil && method.StandardMethod == "" {
+		// Custom methods act on a resource addressed by name or on the
+		// collection, e.g. POST /v1/{name=loans/*}:returnBook
+		if requestsResourceName(method) {
+			path = fmt.Sprintf("%s/{name=%s/*}:%s", base, service.Resource.Plural, strcase.ToLowerCamel(method.Name))
+		} else {
+			path = fmt.Sprintf("%s/%s:%s", base, service.Resource.Plural, strcase.ToLowerCamel(method.Name))
+		}
+		if verb == HTTPPost || verb == HTTPPatch {
+			body = "*"
+		}
+	} else if service.Resource != nil {
+		// Resource-oriented standard methods address resources by name
+		collection := base + "/" + service.Resource.Plural
+		resourceField := strcase.ToSnake(service.Resource.Message)
+		switch method.StandardMethod {
+		case "Get", "Delete":
+			path = fmt.Sprintf("%s/{name=%s/*}", base, service.Resource.Plural)
+		case "List":
+			path = collection
+		case "Create":
+			path = collection
+			body = resourceField
+		case "Update":
+			path = fmt.Sprintf("%s/{%s.%s=%s/*}", base, resourceField, service.Resource.NameField, service.Resource.Plural)
+			body = resourceField
+		}
+	} else {
+		entity := httpEntity(method)
+		collection := base + "/" + strcase.ToLowerCamel(Pluralize(entity))
+
+		// Path parameter from the primary key, if the request carries it
+		idParam := requestIDParam(method, entity)
+
+		switch {
+		case isStandardName(method.Name, entity, "Get", "Delete", "Update") && idParam != "":
+			path = fmt.Sprintf("%s/{%s}", collection, idParam)
+		case isStandardName(method.Name, entity, "List", "Create"):
+			path = collection
+		case idParam != "":
+			path = fmt.Sprintf("%s/{%s}:%s", collection, idParam, strcase.ToLowerCamel(method.Name))
+		default:
+			path = fmt.Sprintf("%s:%s", collection, strcase.ToLowerCamel(method.Name))
+		}
+
+		if verb == HTTPPost || verb == HTTPPatch {
+			body = "*"
+		}
+	}
+
+	if body != "" {
+		return fmt.Sprintf("(google.api.http) = {%s: %q body: %q}", verb, path, body)
+	}
+	return fmt.Sprintf("(google.api.http) = {%s: %q}", verb, path)
+}
+
+// DefaultHTTPPathPrefix derives the path prefix from the version component
+// of a proto package, e.g. "library.v1" becomes "/v1"
+func DefaultHTTPPathPrefix(protoPackage string) string {
+	parts := strings.Split(protoPackage, ".")
+	last := parts[len(parts)-1]
+	if len(last) > 1 && last[0] == 'v' && strings.Trim(last[1:], "0123456789") == "" {
+		return "/" + last
+	}
+	return ""
+}
+
+// httpEntity determines the entity a method operates on, preferring the
+// message it returns
+func httpEntity(method ServiceMethod) string {
+	if method.OriginalQuery != nil && method.OriginalQuery.ReturnType != "" {
+		return method.OriginalQuery.ReturnType
+	}
+	return inferEntityFromMethodName(method.Name)
+}
+
+// requestsResourceName reports whether the request of a method carries the
+// name of the resource it acts on
+func requestsResourceName(method ServiceMethod) bool {
+	for _, field := range method.RequestFields {
+		if field.Name == "name" {
+			return true
+		}
+	}
+	return false
+}
+
+// requestIDParam returns the path of the request field holding the primary
+// key of the entity, looking into the params struct for queries taking one
+// (e.g. "update_book_params.id"), or "" if the request doesn't carry it
+func requestIDParam(method ServiceMethod, entity string) string {
+	isID := func(name string) bool {
+		return name == "id" || name == strcase.ToSnake(entity)+"_id"
+	}
+
+	for _, field := range method.RequestFields {
+		if isID(field.Name) {
+			return field.Name
+		}
+	}
+	if method.Params == nil {
+		return ""
+	}
+	for _, field := range method.RequestFields {
+		if field.Type != method.Params.Name {
+			continue
+		}
+		for _, param := range method.Params.Fields {
+			if isID(param.Name) {
+				return field.Name + "." + param.Name
+			}
+		}
+	}
+	return ""
+}
+
+// isStandardName reports whether a method name is one of the given verbs
+// applied to the entity or its plural (e.g. GetBook, GetBookByID, ListBooks)
+func isStandardName(name, entity string, verbs ...string) bool {
+	for _, verb := range verbs {
+		for _, candidate := range []string{entity, Pluralize(entity), entity + "ByID", entity + "ById"} {
+			if name == verb+candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestDeriveHTTPRule(t *testing.T) {
+	book := &QueryMethod{Name: "GetBook", Type: QueryTypeOne, ReturnType: "Book"}
+	books := &QueryMethod{Name: "ListBooks", Type: QueryTypeMany, ReturnType: "Book", IsArray: true}
+	create := &QueryMethod{Name: "CreateBook", Type: QueryTypeOne, ReturnType: "Book"}
+	returnBook := &QueryMethod{Name: "ReturnBook", Type: QueryTypeOne, ReturnType: "Loan"}
+	byMember := &QueryMethod{Name: "ListActiveLoansByMember", Type: QueryTypeMany, ReturnType: "Loan", IsArray: true}
+	deleteBook := &QueryMethod{Name: "DeleteBook", Type: QueryTypeExec}
+	updateBook := &QueryMethod{Name: "UpdateBook", Type: QueryTypeOne, ReturnType: "Book"}
+	updateParams := &ProtoMessage{Name: "UpdateBookParams", Fields: []ProtoField{{Name: "id", Type: "int32"}, {Name: "title", Type: "string"}}}
+
+	idField := []ProtoField{{Name: "id", Type: "int32", Number: 1}}
+
+	tests := []struct {
+		name     string
+		method   ServiceMethod
+		expected string
+		read     bool
+	}{
+		{
+			name:     "get by id",
+			method:   ServiceMethod{Name: "GetBook", OriginalQuery: book, RequestFields: idField},
+			expected: `(google.api.http) = {get: "/v1/books/{id}"}`,
+			read:     true,
+		},
+		{
+			name:     "list collection",
+			method:   ServiceMethod{Name: "ListBooks", OriginalQuery: books},
+			expected: `(google.api.http) = {get: "/v1/books"}`,
+			read:     true,
+		},
+		{
+			name:     "create",
+			method:   ServiceMethod{Name: "CreateBook", OriginalQuery: create},
+			expected: `(google.api.http) = {post: "/v1/books" body: "*"}`,
+		},
+		{
+			name:     "delete without return type",
+			method:   ServiceMethod{Name: "DeleteBook", OriginalQuery: deleteBook, RequestFields: idField},
+			expected: `(google.api.http) = {delete: "/v1/books/{id}"}`,
+		},
+		{
+			name: "update with params struct",
+			method: ServiceMethod{
+				Name:          "UpdateBook",
+				OriginalQuery: updateBook,
+				Params:        updateParams,
+				RequestFields: []ProtoField{{Name: "update_book_params", Type: "UpdateBookParams", Number: 1}},
+			},
+			expected: `(google.api.http) = {patch: "/v1/books/{update_book_params.id}" body: "*"}`,
+		},
+		{
+			name:     "custom write method",
+			method:   ServiceMethod{Name: "ReturnBook", OriginalQuery: returnBook, RequestFields: idField},
+			expected: `(google.api.http) = {post: "/v1/loans/{id}:returnBook" body: "*"}`,
+		},
+		{
+			name:     "custom list method",
+			method:   ServiceMethod{Name: "ListActiveLoansByMember", OriginalQuery: byMember, RequestFields: []ProtoField{{Name: "member_id", Type: "int32"}}},
+			expected: `(google.api.http) = {get: "/v1/loans:listActiveLoansByMember"}`,
+			read:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DeriveHTTPRule(ServiceDefinition{Name: "BookService"}, tt.method, "/v1")
+			if got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+			if IsReadMethod(tt.method) != tt.read {
+				t.Errorf("Expected IsReadMethod to be %t", tt.read)
+			}
+		})
+	}
+}
+
+func TestHTTPVerb(t *testing.T) {
+	tests := []struct {
+		name  string
+		query QueryMethod
+		want  string
+	}{
+		{"GetBook", QueryMethod{Type: QueryTypeOne, ReturnType: "Book", Annotation: "one", SQL: "SELECT * FROM books WHERE id = $1"}, HTTPGet},
+		{"CountBooks", QueryMethod{Type: QueryTypeOne, ReturnType: "int64", SQL: "-- total\nSELECT count(*) FROM books"}, HTTPGet},
+		{"GetOrCreateLoan", QueryMethod{Type: QueryTypeOne, ReturnType: "Loan"}, HTTPPost},
+		{"FindAndLockCopy", QueryMethod{Type: QueryTypeOne, ReturnType: "Copy"}, HTTPPost},
+		{"CountAndResetVisits", QueryMethod{Type: QueryTypeOne, ReturnType: "int64"}, HTTPPost},
+		{"GetLoanCount", QueryMethod{Type: QueryTypeOne, ReturnType: "int64", Annotation: "execrows"}, HTTPPost},
+		{"GetBookTouch", QueryMethod{Type: QueryTypeOne, ReturnType: "Book", SQL: "UPDATE books SET touched_at = now() RETURNING *"}, HTTPPost},
+		{"GetRecentBooks", QueryMethod{Type: QueryTypeMany, ReturnType: "Book", SQL: "WITH moved AS (DELETE FROM books RETURNING *) SELECT * FROM moved"}, HTTPPost},
+		{"FindMarker", QueryMethod{Type: QueryTypeOne}, HTTPPost},
+		{"SettleFine", QueryMethod{Type: QueryTypeExec}, HTTPPost},
+		{"AddressUpdate", QueryMethod{Type: QueryTypeExec}, HTTPPost},
+		{"SetTitle", QueryMethod{Type: QueryTypeExec}, HTTPPatch},
+		{"OverdueLoans", QueryMethod{Type: QueryTypeMany, ReturnType: "Loan", Annotation: "many", SQL: "SELECT * FROM loans"}, HTTPGet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Name = tt.name
+			if got := HTTPVerb(ServiceMethod{Name: tt.name, OriginalQuery: &tt.query}); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDeriveHTTPRule_AIP(t *testing.T) {
+	resource := &ResourceDefinition{
+		Type:      "library.example.com/Book",
+		Pattern:   "books/{book}",
+		Singular:  "book",
+		Plural:    "books",
+		Message:   "Book",
+		IDField:   "ID",
+		IDName:    "id",
+		NameField: "name",
+	}
+	service := ServiceDefinition{Name: "BookService", Resource: resource}
+
+	tests := map[string]string{
+		"Get":    `(google.api.http) = {get: "/v1/{name=books/*}"}`,
+		"List":   `(google.api.http) = {get: "/v1/books"}`,
+		"Create": `(google.api.http) = {post: "/v1/books" body: "book"}`,
+		"Update": `(google.api.http) = {patch: "/v1/{book.name=books/*}" body: "book"}`,
+		"Delete": `(google.api.http) = {delete: "/v1/{name=books/*}"}`,
+	}
+
+	for standard, expected := range tests {
+		method := ServiceMethod{Name: standard + "Book", StandardMethod: standard}
+		if got := DeriveHTTPRule(service, method, "/v1/"); got != expected {
+			t.Errorf("%s: expected %s, got %s", standard, expected, got)
+		}
+	}
+
+	// Custom methods act on a resource by name or on the collection
+	custom := map[string]ServiceMethod{
+		`(google.api.http) = {post: "/v1/{name=books/*}:archiveBook" body: "*"}`: {
+			Name:          "ArchiveBook",
+			RequestFields: []ProtoField{resourceNameField(*resource, "act on")},
+		},
+		`(google.api.http) = {get: "/v1/books:searchBooks"}`: {
+			Name:          "SearchBooks",
+			RequestFields: []ProtoField{{Name: "title", Type: "string", Number: 1}},
+		},
+	}
+	for expected, method := range custom {
+		if got := DeriveHTTPRule(service, method, "/v1"); got != expected {
+			t.Errorf("%s: expected %s, got %s", method.Name, expected, got)
+		}
+	}
+}
+
+func TestDefaultHTTPPathPrefix(t *testing.T) {
+	tests := map[string]string{
+		"library.v1":   "/v1",
+		"api.v2":       "/v2",
+		"library":      "",
+		"acme.vendor":  "",
+		"acme.api.v10": "/v10",
+	}
+
+	for pkg, expected := range tests {
+		if got := DefaultHTTPPathPrefix(pkg); got != expected {
+			t.Errorf("DefaultHTTPPathPrefix(%s): expected %q, got %q", pkg, expected, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"strings"
+)
+
+// MessageGroup is a set of messages belonging to the same entity, used to
+// split the generated code into one file per entity
+type MessageGroup struct {
+	Entity   string
+	Messages []ProtoMessage
+}
+
+// GroupMessagesByEntity groups messages by the table model they relate to.
+// Table models form their own group, and query parameter and row structs
+// (e.g. CreateBookParams, SearchBooksParams) join the group of the model
+// they were inferred from. Groups are ordered by first appearance.
+func GroupMessagesByEntity(messages []ProtoMessage) []MessageGroup {
+	// Index the table models by singular and plural name
+	models := make(map[string]string)
+	for _, msg := range messages {
+		if isResourceCandidate(msg) {
+			models[msg.Name] = msg.Name
+			models[Pluralize(msg.Name)] = msg.Name
+		}
+	}
+
+	var groups []MessageGroup
+	groupIndex := make(map[string]int)
+
+	for _, msg := range messages {
+		if msg.Name == "Queries" {
+			continue
+		}
+
+		entity := messageEntity(msg, models)
+		idx, ok := groupIndex[entity]
+		if !ok {
+			idx = len(groups)
+			groupIndex[entity] = idx
+			groups = append(groups, MessageGroup{Entity: entity})
+		}
+		groups[idx].Messages = append(groups[idx].Messages, msg)
+	}
+
+	return groups
+}
+
+// messageEntity returns the entity a message belongs to, given the table
+// models indexed by singular and plural name
+func messageEntity(msg ProtoMessage, models map[string]string) string {
+	if isResourceCandidate(msg) {
+		return msg.Name
+	}
+
+	// Strip the sqlc suffix to recover the query name, e.g. CreateBookParams -> CreateBook
+	queryName := strings.TrimSuffix(strings.TrimSuffix(msg.Name, "Params"), "Row")
+	entity := inferEntityFromMethodName(queryName)
+	// List queries have a trailing "s" trimmed, e.g. ListCategories -> Categorie
+	for _, candidate := range []string{entity, entity + "s"} {
+		if model, ok := models[candidate]; ok {
+			return model
+		}
+	}
+
+	return queryName
+}
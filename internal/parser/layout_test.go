@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestGroupMessagesByEntity(t *testing.T) {
+	messages := []ProtoMessage{
+		{Name: "Book"},
+		{Name: "Category"},
+		{Name: "CreateBookParams"},
+		{Name: "SearchBooksParams"},
+		{Name: "ListCategoriesRow"},
+		{Name: "GetStatsRow"},
+		{Name: "Queries"},
+	}
+
+	groups := GroupMessagesByEntity(messages)
+
+	expected := []struct {
+		entity   string
+		messages []string
+	}{
+		{"Book", []string{"Book", "CreateBookParams", "SearchBooksParams"}},
+		{"Category", []string{"Category", "ListCategoriesRow"}},
+		{"GetStats", []string{"GetStatsRow"}},
+	}
+
+	if len(groups) != len(expected) {
+		t.Fatalf("Expected %d groups, got %d", len(expected), len(groups))
+	}
+
+	for i, exp := range expected {
+		group := groups[i]
+		if group.Entity != exp.entity {
+			t.Errorf("Group %d: expected entity %s, got %s", i, exp.entity, group.Entity)
+		}
+		if len(group.Messages) != len(exp.messages) {
+			t.Errorf("Group %s: expected %d messages, got %d", exp.entity, len(exp.messages), len(group.Messages))
+			continue
+		}
+		for j, name := range exp.messages {
+			if group.Messages[j].Name != name {
+				t.Errorf("Group %s: expected message %s at %d, got %s", exp.entity, name, j, group.Messages[j].Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/boomskats/sqlc2proto/internal/cache"
+)
+
+// SourceFile is a Go file of a sqlc output directory. It is parsed at most
+// once, on first use.
+type SourceFile struct {
+	Path string
+	Src  []byte
+	Hash string // Content hash, used to key the parse cache
+
+	once sync.Once
+	node *ast.File
+	err  error
+}
+
+// AST returns the parsed file, parsing it on first use
+func (f *SourceFile) AST() (*ast.File, error) {
+	f.once.Do(func() {
+		f.node, f.err = parser.ParseFile(token.NewFileSet(), f.Path, f.Src, parser.ParseComments)
+	})
+	return f.node, f.err
+}
+
+// Package is a sqlc output directory loaded into memory. Model and query
+// parsing share it, so every file is read and parsed at most once.
+type Package struct {
+	Dir   string
+	Files []*SourceFile // Sorted by path
+
+	// Cache holds parse results between runs, nil to disable caching
+	Cache *cache.Cache
+}
+
+// LoadPackage reads the Go files of a sqlc output directory and its
+// subdirectories, skipping tests
+func LoadPackage(dir string) (*Package, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	pkg := &Package{Dir: dir, Files: make([]*SourceFile, len(paths))}
+	err = parallel(len(paths), func(i int) error {
+		src, err := os.ReadFile(paths[i])
+		if err != nil {
+			return err
+		}
+		pkg.Files[i] = &SourceFile{Path: paths[i], Src: src, Hash: cache.Hash(src)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// Messages extracts message definitions from the structs of the package,
+// mapping field types with typeConfig and parsing files concurrently. Errors
+// from all files are returned together, ordered by file path. The fields
+// have no conversion code yet, see GenerateConversionCode.
+func (p *Package) Messages(fieldStyle string, typeConfig TypeMappingConfig) ([]ProtoMessage, error) {
+	config := ParserConfig{
+		FieldStyle: fieldStyle,
+		TypeConfig: typeConfig,
+	}
+
+	results := make([][]ProtoMessage, len(p.Files))
+	err := parallel(len(p.Files), func(i int) error {
+		file := p.Files[i]
+
+		// Process all Go files except for querier.go and db.go
+		// querier.go contains the interface and db.go contains the DB connection code
+		filename := filepath.Base(file.Path)
+		if filename == "querier.go" || filename == "db.go" {
+			return nil
+		}
+
+		messages, err := file.messages(config, p.Cache)
+		if err != nil {
+			return fmt.Errorf("error processing file %s: %v", file.Path, err)
+		}
+		results[i] = messages
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []ProtoMessage
+	for _, fileMessages := range results {
+		messages = append(messages, fileMessages...)
+	}
+	return messages, nil
+}
+
+// messages returns the messages of the file from the parse cache if its
+// content and the parser configuration haven't changed, parsing it otherwise
+func (f *SourceFile) messages(config ParserConfig, c *cache.Cache) ([]ProtoMessage, error) {
+	key := "messages/" + cache.Hash(fmt.Appendf(nil, "%+v", config)) + "/" + f.Hash
+	var messages []ProtoMessage
+	if c != nil && c.Get(key, &messages) {
+		return messages, nil
+	}
+
+	node, err := f.AST()
+	if err != nil {
+		return nil, err
+	}
+	messages = messagesFromAST(node, config)
+	if c != nil {
+		c.Put(key, messages)
+	}
+	return messages, nil
+}
+
+// QueryMethods returns the methods of the package's Querier interface
+func (p *Package) QueryMethods() ([]QueryMethod, error) {
+	for _, file := range p.querierCandidates() {
+		if !bytes.Contains(file.Src, []byte("Querier interface")) {
+			continue
+		}
+
+		key := "querier/" + file.Hash
+		var methods []QueryMethod
+		if p.Cache != nil && p.Cache.Get(key, &methods) {
+			p.annotateQueries(methods)
+			return methods, nil
+		}
+
+		// Parse the file containing the Querier interface
+		node, err := file.AST()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse querier file: %w", err)
+		}
+
+		querierInterface := findQuerierInterface(node)
+		if querierInterface == nil {
+			continue
+		}
+
+		methods = queryMethods(querierInterface)
+		if p.Cache != nil {
+			p.Cache.Put(key, methods)
+		}
+		p.annotateQueries(methods)
+		return methods, nil
+	}
+
+	return nil, fmt.Errorf("could not find the Querier interface in %s", p.Dir)
+}
+
+// queryConstant matches the constant sqlc declares for each query, e.g.
+// const getBook = `-- name: GetBook :one` followed by the SQL
+var queryConstant = regexp.MustCompile("`-- name: (\\w+) :(\\w+)\n?([^`]*)`")
+
+// annotateQueries sets the sqlc annotation and the SQL of the methods from
+// the query constants of the package
+func (p *Package) annotateQueries(methods []QueryMethod) {
+	index := make(map[string]*QueryMethod, len(methods))
+	for i := range methods {
+		index[methods[i].Name] = &methods[i]
+	}
+	for _, file := range p.Files {
+		for _, match := range queryConstant.FindAllSubmatch(file.Src, -1) {
+			if method, ok := index[string(match[1])]; ok {
+				method.Annotation = string(match[2])
+				method.SQL = strings.TrimSpace(string(match[3]))
+			}
+		}
+	}
+}
+
+// querierCandidates returns the files at the top of the package that may
+// contain the Querier interface, starting with the usual file names
+func (p *Package) querierCandidates() []*SourceFile {
+	// Common filenames for the Querier interface
+	priority := map[string]int{
+		"querier.go":   0,
+		"db.go":        1,
+		"interface.go": 2,
+	}
+
+	var candidates []*SourceFile
+	for _, file := range p.Files {
+		if filepath.Clean(filepath.Dir(file.Path)) == filepath.Clean(p.Dir) {
+			candidates = append(candidates, file)
+		}
+	}
+
+	rank := func(file *SourceFile) int {
+		if r, ok := priority[filepath.Base(file.Path)]; ok {
+			return r
+		}
+		return len(priority)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return rank(candidates[i]) < rank(candidates[j])
+	})
+	return candidates
+}
+
+// parallel calls fn for 0..n-1 on a bounded pool of workers and returns the
+// errors joined in index order, so the result doesn't depend on scheduling
+func parallel(n int, fn func(i int) error) error {
+	workers := min(runtime.GOMAXPROCS(0), n)
+	errs := make([]error, n)
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
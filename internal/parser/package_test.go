@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackageMessagesOrder(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		src := fmt.Sprintf("package db\n\ntype Model%02d struct {\n\tID int64 `json:\"id\"`\n}\n", i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("model%02d.go", i)), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkg, err := LoadPackage(dir)
+	if err != nil {
+		t.Fatalf("LoadPackage failed: %v", err)
+	}
+	messages, err := pkg.Messages("json", DefaultTypeMappingConfig())
+	if err != nil {
+		t.Fatalf("Messages failed: %v", err)
+	}
+
+	// Messages keep the file order regardless of which worker parsed them
+	if len(messages) != 20 {
+		t.Fatalf("Expected 20 messages, got %d", len(messages))
+	}
+	for i, msg := range messages {
+		if want := fmt.Sprintf("Model%02d", i); msg.Name != want {
+			t.Errorf("Expected message %d to be %s, got %s", i, want, msg.Name)
+		}
+	}
+}
+
+func TestPackageMessagesErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go": "package db\n\ntype A struct {\n",
+		"b.go": "package db\n\ntype B struct{}\n",
+		"c.go": "package db\n\nfunc {\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkg, err := LoadPackage(dir)
+	if err != nil {
+		t.Fatalf("LoadPackage failed: %v", err)
+	}
+	_, err = pkg.Messages("json", DefaultTypeMappingConfig())
+	if err == nil {
+		t.Fatal("Expected an error for the invalid files")
+	}
+
+	// Both errors are reported, in file order
+	msg := err.Error()
+	a := strings.Index(msg, "a.go")
+	c := strings.Index(msg, "c.go")
+	if a < 0 || c < 0 || a > c {
+		t.Errorf("Expected errors for a.go and c.go in order, got: %v", msg)
+	}
+	if strings.Contains(msg, "b.go") {
+		t.Errorf("Expected no error for b.go, got: %v", msg)
+	}
+}
+
+func TestPackageQueryMethods(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"models.go":  "package db\n\ntype Book struct {\n\tID int64 `json:\"id\"`\n}\n",
+		"querier.go": "package db\n\nimport \"context\"\n\ntype Querier interface {\n\tGetBook(ctx context.Context, id int64) (Book, error)\n}\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkg, err := LoadPackage(dir)
+	if err != nil {
+		t.Fatalf("LoadPackage failed: %v", err)
+	}
+	messages, err := pkg.Messages("json", DefaultTypeMappingConfig())
+	if err != nil {
+		t.Fatalf("Messages failed: %v", err)
+	}
+	methods, err := pkg.QueryMethods()
+	if err != nil {
+		t.Fatalf("QueryMethods failed: %v", err)
+	}
+
+	if len(messages) != 1 || messages[0].Name != "Book" {
+		t.Errorf("Expected the Book message, got %+v", messages)
+	}
+	if len(methods) != 1 || methods[0].Name != "GetBook" || methods[0].ReturnType != "Book" {
+		t.Errorf("Expected the GetBook method, got %+v", methods)
+	}
+}
+
+func TestPackageQueryAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"querier.go": "package db\n\nimport \"context\"\n\ntype Querier interface {\n\tGetBook(ctx context.Context, id int64) (int64, error)\n\tGetOrCreateLoan(ctx context.Context, id int64) (int64, error)\n}\n",
+		"query.sql.go": "package db\n\nconst getBook = `-- name: GetBook :one\nSELECT id FROM books WHERE id = $1\n`\n\n" +
+			"const getOrCreateLoan = `-- name: GetOrCreateLoan :execrows\nINSERT INTO loans (book_id) VALUES ($1)\n`\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkg, err := LoadPackage(dir)
+	if err != nil {
+		t.Fatalf("LoadPackage failed: %v", err)
+	}
+	methods, err := pkg.QueryMethods()
+	if err != nil {
+		t.Fatalf("QueryMethods failed: %v", err)
+	}
+
+	want := map[string][2]string{
+		"GetBook":         {"one", "SELECT id FROM books WHERE id = $1"},
+		"GetOrCreateLoan": {"execrows", "INSERT INTO loans (book_id) VALUES ($1)"},
+	}
+	for _, method := range methods {
+		if got := [2]string{method.Annotation, method.SQL}; got != want[method.Name] {
+			t.Errorf("Expected %s to be %q, got %q", method.Name, want[method.Name], got)
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"go/parser"
 	"go/token"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/iancoleman/strcase"
@@ -23,6 +23,8 @@ type ProtoMessage struct {
 	Comments     string
 	SQLCStruct   string
 	ProtoPackage string
+	Options      []string // Message-level options, e.g. resource annotations
+	Reserved     []int    // Field numbers that must not be used, e.g. of excluded fields
 }
 
 // ProtoField represents a field in a Protobuf message
@@ -36,8 +38,13 @@ type ProtoField struct {
 	JSONName              string
 	OriginalTag           string
 	SQLCName              string
-	ConversionCode        string
-	ReverseConversionCode string
+	ConversionCode        string   // Set by GenerateConversionCode
+	ReverseConversionCode string   // Set by GenerateConversionCode
+	StrictConversionCode  string   // Error-returning reverse conversion for strict mappers, empty if it can't fail
+	GoType                string   // Go type of the sqlc struct field, e.g. "sql.NullString" or "[]uuid.UUID"
+	Lossy                 string   // Why the conversion doesn't round-trip, empty if it does
+	Options               []string // Field options, e.g. "(google.api.field_behavior) = REQUIRED"
+	OutputOnly            bool     // Set by the server only, so FromProto leaves it zero
 }
 
 // ParserConfig holds configuration for the parser
@@ -84,72 +91,93 @@ func DefaultTypeMappingConfig() TypeMappingConfig {
 		},
 		CustomConverters: map[string]ConversionFuncs{
 			"time.Time": {
-				ToProto:   "timestamppb.New(%s)",
-				FromProto: "%s.AsTime()",
+				ToProto:         "timestamppb.New(%s)",
+				FromProto:       "%s.AsTime()",
+				StrictFromProto: "parseRequiredTime(%s)",
 			},
 			"pgtype.Date": {
-				ToProto:   "dateToTimestamp(%s)",
-				FromProto: "timestampToDate(%s)",
+				ToProto:         "dateToTimestamp(%s)",
+				FromProto:       "timestampToDate(%s)",
+				StrictFromProto: "parseDate(%s)",
+				Lossy:           "NULL dates come back as valid zero dates",
 			},
 			"pgtype.Timestamptz": {
-				ToProto:   "timestamptzToTimestamp(%s)",
-				FromProto: "timestampToTimestamptz(%s)",
+				ToProto:         "timestamptzToTimestamp(%s)",
+				FromProto:       "timestampToTimestamptz(%s)",
+				StrictFromProto: "parseTimestamptz(%s)",
+				Lossy:           "NULL timestamps keep the Unix epoch as their time",
 			},
 			"pgtype.Text": {
 				ToProto:   "pgtypeTextToString(%s)",
 				FromProto: "stringToPgtypeText(%s)",
+				Lossy:     "empty strings are stored as NULL",
 			},
 			"pgtype.Numeric": {
-				ToProto:   "numericToString(%s)",
-				FromProto: "stringToNumeric(%s)",
+				ToProto:         "numericToString(%s)",
+				FromProto:       "stringToNumeric(%s)",
+				StrictFromProto: "parseNumeric(%s)",
+				Lossy:           "values are normalised through their decimal string",
 			},
 			"uuid.UUID": {
-				ToProto:   "uuidToString(%s)",
-				FromProto: "stringToUUID(%s)",
+				ToProto:         "uuidToString(%s)",
+				FromProto:       "stringToUUID(%s)",
+				StrictFromProto: "parseUUID(%s)",
 			},
 			"json.RawMessage": {
-				ToProto:   "jsonToString(%s)",
-				FromProto: "stringToJSON(%s)",
+				ToProto:         "jsonToString(%s)",
+				FromProto:       "stringToJSON(%s)",
+				StrictFromProto: "parseJSON(%s)",
 			},
 			"pgtype.Interval": {
 				ToProto:   "intervalToInt64(%s)",
 				FromProto: "int64ToInterval(%s)",
+				Lossy:     "days and months are dropped",
 			},
 			"int16": {
-				ToProto:   "int32(%s)",
-				FromProto: "int16(%s)",
+				ToProto:         "int32(%s)",
+				FromProto:       "int16(%s)",
+				StrictFromProto: "parseInt16(%s)",
 			},
 			"sql.NullString": {
 				ToProto:   "nullStringToString(%s)",
 				FromProto: "stringToNullString(%s)",
+				Lossy:     "empty strings are stored as NULL",
 			},
 			"sql.NullInt16": {
-				ToProto:   "nullInt16ToInt32(%s)",
-				FromProto: "int32ToNullInt16(%s)",
+				ToProto:         "nullInt16ToInt32(%s)",
+				FromProto:       "int32ToNullInt16(%s)",
+				StrictFromProto: "parseNullInt16(%s)",
+				Lossy:           "zero is stored as NULL",
 			},
 			"sql.NullInt32": {
 				ToProto:   "nullInt32ToInt32(%s)",
 				FromProto: "int32ToNullInt32(%s)",
+				Lossy:     "zero is stored as NULL",
 			},
 			"sql.NullInt64": {
 				ToProto:   "nullInt64ToInt64(%s)",
 				FromProto: "int64ToNullInt64(%s)",
+				Lossy:     "zero is stored as NULL",
 			},
 			"sql.NullFloat64": {
 				ToProto:   "nullFloat64ToFloat64(%s)",
 				FromProto: "float64ToNullFloat64(%s)",
+				Lossy:     "zero is stored as NULL",
 			},
 			"sql.NullBool": {
 				ToProto:   "nullBoolToBool(%s)",
 				FromProto: "boolToNullBool(%s)",
+				Lossy:     "NULL comes back as false",
 			},
 			"sql.NullTime": {
-				ToProto:   "nullTimeToTimestamp(%s)",
-				FromProto: "timestampToNullTime(%s)",
+				ToProto:         "nullTimeToTimestamp(%s)",
+				FromProto:       "timestampToNullTime(%s)",
+				StrictFromProto: "parseNullTime(%s)",
 			},
 			"uuid.NullUUID": {
-				ToProto:   "nullUUIDToString(%s)",
-				FromProto: "stringToNullUUID(%s)",
+				ToProto:         "nullUUIDToString(%s)",
+				FromProto:       "stringToNullUUID(%s)",
+				StrictFromProto: "parseNullUUID(%s)",
 			},
 		},
 	}
@@ -159,37 +187,35 @@ func DefaultTypeMappingConfig() TypeMappingConfig {
 // Public API Methods
 // ========================================
 
-// ProcessSQLCDirectory processes all Go files in the sqlc output directory
+// ProcessSQLCDirectory processes all Go files in the sqlc output directory,
+// returning the messages with their conversion code
 func ProcessSQLCDirectory(dir string, fieldStyle string) ([]ProtoMessage, error) {
-	config := ParserConfig{
-		FieldStyle: fieldStyle,
-		TypeConfig: DefaultTypeMappingConfig(),
+	pkg, err := LoadPackage(dir)
+	if err != nil {
+		return nil, err
 	}
+	typeConfig := DefaultTypeMappingConfig()
+	messages, err := pkg.Messages(fieldStyle, typeConfig)
+	if err != nil {
+		return nil, err
+	}
+	GenerateConversionCode(messages, typeConfig)
+	return messages, nil
+}
 
-	var messages []ProtoMessage
-
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-			// Process all Go files except for querier.go and db.go
-			// querier.go contains the interface and db.go contains the DB connection code
-			filename := filepath.Base(path)
-			if filename == "querier.go" || filename == "db.go" {
-				return nil
-			}
-
-			fileMessages, err := processSQLCFile(path, config)
-			if err != nil {
-				return fmt.Errorf("error processing file %s: %v", path, err)
+// GenerateConversionCode builds the conversion code of the message fields
+// from their Go types and names. Fields are parsed without it, so that it
+// is built from the final names once fields have been renamed, e.g. by the
+// field rules of the includes file.
+func GenerateConversionCode(messages []ProtoMessage, typeConfig TypeMappingConfig) {
+	for i := range messages {
+		for j := range messages[i].Fields {
+			field := &messages[i].Fields[j]
+			if field.GoType != "" {
+				field.setConversionCode(field.GoType, typeConfig)
 			}
-			messages = append(messages, fileMessages...)
 		}
-		return nil
-	})
-
-	return messages, err
+	}
 }
 
 // GenerateHelperFunctions generates helper functions for type conversions
@@ -213,19 +239,79 @@ func GenerateHelperFunctions(messages []ProtoMessage) string {
 	return generateHelperFunctionsCode(neededHelpers)
 }
 
+// GenerateStrictHelperFunctions generates helper functions for strict mappers,
+// in which conversions from proto that can fail return an error
+func GenerateStrictHelperFunctions(messages []ProtoMessage) string {
+	neededHelpers := make(map[string]bool)
+
+	for _, msg := range messages {
+		for _, field := range msg.Fields {
+			extractHelperNames(field.ConversionCode, neededHelpers)
+			if field.StrictConversionCode != "" {
+				extractHelperNames(field.StrictConversionCode, neededHelpers)
+			} else {
+				extractHelperNames(field.ReverseConversionCode, neededHelpers)
+			}
+		}
+	}
+
+	return fieldErrorCode + generateHelperFunctionsCode(neededHelpers)
+}
+
+// fieldErrorCode is the error type returned by strict mappers, which handlers
+// can detect with errors.As to report an InvalidArgument error
+const fieldErrorCode = `
+// FieldError reports an invalid field in a proto message, e.g. "book.isbn: invalid uuid"
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// Error implements the error interface
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying conversion error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+`
+
 // ========================================
 // Internal Implementation Methods
 // ========================================
 
-// processSQLCFile extracts message definitions from a sqlc-generated Go file
+// processSQLCFile extracts message definitions from a sqlc-generated Go
+// file, with their conversion code
 func processSQLCFile(filePath string, config ParserConfig) ([]ProtoMessage, error) {
+	src, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := processSQLCSource(filePath, src, config)
+	if err != nil {
+		return nil, err
+	}
+	GenerateConversionCode(messages, config.TypeConfig)
+	return messages, nil
+}
+
+// processSQLCSource extracts message definitions from the source of a
+// sqlc-generated Go file
+func processSQLCSource(filePath string, src []byte, config ParserConfig) ([]ProtoMessage, error) {
 	// Parse the Go file
 	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+	node, err := parser.ParseFile(fset, filePath, src, parser.ParseComments)
 	if err != nil {
 		return nil, err
 	}
 
+	return messagesFromAST(node, config), nil
+}
+
+// messagesFromAST extracts message definitions from the structs of a parsed file
+func messagesFromAST(node *ast.File, config ParserConfig) []ProtoMessage {
 	// Find and process struct type declarations
 	var messages []ProtoMessage
 	for _, decl := range node.Decls {
@@ -260,7 +346,7 @@ func processSQLCFile(filePath string, config ParserConfig) ([]ProtoMessage, erro
 		}
 	}
 
-	return messages, nil
+	return messages
 }
 
 // processStructFields extracts and processes the fields of a struct
@@ -367,6 +453,7 @@ func getProtoFieldName(field *ast.Field, fieldName string, fieldStyle string) st
 func processFieldType(field *ast.Field, protoField *ProtoField, typeConfig TypeMappingConfig) bool {
 	// Extract type string from the AST
 	typeStr := exprToTypeString(field.Type)
+	protoField.GoType = typeStr
 
 	// Handle array/slice types
 	if strings.HasPrefix(typeStr, "[]") {
@@ -404,45 +491,68 @@ func processStandardType(typeStr string, protoField *ProtoField, typeConfig Type
 	if protoType, ok := typeConfig.NullableTypes[typeStr]; ok {
 		protoField.Type = protoType
 		protoField.IsOptional = true
-
-		// Set conversion code
 		if converter, ok := typeConfig.CustomConverters[typeStr]; ok {
-			protoField.ConversionCode = fmt.Sprintf(converter.ToProto, "in."+protoField.SQLCName)
-			protoField.ReverseConversionCode = fmt.Sprintf(converter.FromProto, "in."+pascalCase(protoField.Name))
-		} else {
-			// Default conversion for nullable types
-			protoField.ConversionCode = fmt.Sprintf("in.%s", protoField.SQLCName)
-			protoField.ReverseConversionCode = fmt.Sprintf("in.%s", pascalCase(protoField.Name))
+			protoField.Lossy = converter.Lossy
 		}
-
 		return true
 	}
 
 	// Then check standard types
 	if protoType, ok := typeConfig.StandardTypes[typeStr]; ok {
 		protoField.Type = protoType
-
-		// Set conversion code
 		if converter, ok := typeConfig.CustomConverters[typeStr]; ok {
-			protoField.ConversionCode = fmt.Sprintf(converter.ToProto, "in."+protoField.SQLCName)
-			protoField.ReverseConversionCode = fmt.Sprintf(converter.FromProto, "in."+pascalCase(protoField.Name))
-		} else {
-			// Default conversion for standard types
-			protoField.ConversionCode = fmt.Sprintf("in.%s", protoField.SQLCName)
-			protoField.ReverseConversionCode = fmt.Sprintf("in.%s", pascalCase(protoField.Name))
+			protoField.Lossy = converter.Lossy
 		}
-
 		return true
 	}
 
 	// Default to string for unknown types
 	protoField.Type = "string"
-	protoField.ConversionCode = fmt.Sprintf("in.%s", protoField.SQLCName)
-	protoField.ReverseConversionCode = fmt.Sprintf("in.%s", pascalCase(protoField.Name))
 
 	return true
 }
 
+// setConversionCode builds the expressions converting a field of the given
+// Go type from the sqlc struct to the proto message and back, reading the
+// proto message by the field's current name
+func (f *ProtoField) setConversionCode(goType string, typeConfig TypeMappingConfig) {
+	sqlcValue := "in." + f.SQLCName
+	protoValue := "in." + pascalCase(f.Name)
+	f.ConversionCode, f.ReverseConversionCode, f.StrictConversionCode = sqlcValue, protoValue, ""
+
+	// Convert slices element-wise if the element type needs a conversion,
+	// e.g. []uuid.UUID or []pgtype.Text
+	if elementType, ok := strings.CutPrefix(goType, "[]"); ok && elementType != "byte" {
+		converter, ok := typeConfig.CustomConverters[elementType]
+		protoGoType, known := protoGoTypes[f.Type]
+		if ok && known {
+			f.ConversionCode = fmt.Sprintf("convertSlice(%s, func(v %s) %s { return %s })",
+				sqlcValue, elementType, protoGoType, fmt.Sprintf(converter.ToProto, "v"))
+			f.ReverseConversionCode = fmt.Sprintf("convertSlice(%s, func(v %s) %s { return %s })",
+				protoValue, protoGoType, elementType, fmt.Sprintf(converter.FromProto, "v"))
+			if converter.StrictFromProto != "" {
+				f.StrictConversionCode = fmt.Sprintf("parseSlice(%s, func(v %s) (%s, error) { return %s })",
+					protoValue, protoGoType, elementType, fmt.Sprintf(converter.StrictFromProto, "v"))
+			}
+			return
+		}
+		goType = elementType
+	}
+
+	// Mapped types use their converter, others are assigned as they are
+	_, nullable := typeConfig.NullableTypes[goType]
+	_, standard := typeConfig.StandardTypes[goType]
+	converter, ok := typeConfig.CustomConverters[goType]
+	if !ok || !(nullable || standard) {
+		return
+	}
+	f.ConversionCode = fmt.Sprintf(converter.ToProto, sqlcValue)
+	f.ReverseConversionCode = fmt.Sprintf(converter.FromProto, protoValue)
+	if converter.StrictFromProto != "" {
+		f.StrictConversionCode = fmt.Sprintf(converter.StrictFromProto, protoValue)
+	}
+}
+
 // ========================================
 // Helper Functions
 // ========================================
@@ -531,6 +641,14 @@ func extractHelperNames(code string, helpers map[string]bool) {
 		"nullUUIDToString", "stringToNullUUID",
 		"jsonToString", "stringToJSON",
 		"intervalToInt64", "int64ToInterval",
+		"commandTagToString", "stringToCommandTag",
+		// Strict conversions
+		"parseRequiredTime", "parseNullTime",
+		"parseDate", "parseTimestamptz",
+		"parseNumeric", "parseUUID", "parseNullUUID",
+		"parseJSON", "parseInt16", "parseNullInt16",
+		// Slice conversions
+		"convertSlice", "parseSlice",
 	}
 
 	for _, prefix := range helperPrefixes {
@@ -719,16 +837,23 @@ func stringToPgtypeText(v string) pgtype.Text {
 		"numericToString": `
 // Helper function to convert pgtype.Numeric to string
 func numericToString(v pgtype.Numeric) string {
-	if v.Valid {
-		return v.String()
+	if !v.Valid {
+		return ""
 	}
-	return ""
+	value, err := v.Value()
+	if err != nil {
+		return ""
+	}
+	s, _ := value.(string)
+	return s
 }`,
 		"stringToNumeric": `
 // Helper function to convert string to pgtype.Numeric
 func stringToNumeric(v string) pgtype.Numeric {
 	var n pgtype.Numeric
-	n.Set(v)
+	if v != "" {
+		_ = n.Scan(v)
+	}
 	return n
 }`,
 		// UUID helpers
@@ -794,6 +919,140 @@ func int64ToInterval(v int64) pgtype.Interval {
 		Microseconds: v,
 		Valid:        true,
 	}
+}`,
+		// Strict helpers, returning an error instead of a zero value
+		"parseRequiredTime": `
+// Helper function to convert a required *timestamppb.Timestamp to time.Time
+func parseRequiredTime(v *timestamppb.Timestamp) (time.Time, error) {
+	if v == nil {
+		return time.Time{}, fmt.Errorf("missing timestamp")
+	}
+	if err := v.CheckValid(); err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return v.AsTime(), nil
+}`,
+		"parseNullTime": `
+// Helper function to convert *timestamppb.Timestamp to sql.NullTime
+func parseNullTime(v *timestamppb.Timestamp) (sql.NullTime, error) {
+	if v == nil {
+		return sql.NullTime{}, nil
+	}
+	if err := v.CheckValid(); err != nil {
+		return sql.NullTime{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return sql.NullTime{Time: v.AsTime(), Valid: true}, nil
+}`,
+		"parseDate": `
+// Helper function to convert *timestamppb.Timestamp to pgtype.Date
+func parseDate(v *timestamppb.Timestamp) (pgtype.Date, error) {
+	if v == nil {
+		return pgtype.Date{}, nil
+	}
+	if err := v.CheckValid(); err != nil {
+		return pgtype.Date{}, fmt.Errorf("invalid date: %w", err)
+	}
+	return pgtype.Date{Time: v.AsTime(), Valid: true}, nil
+}`,
+		"parseTimestamptz": `
+// Helper function to convert *timestamppb.Timestamp to pgtype.Timestamptz
+func parseTimestamptz(v *timestamppb.Timestamp) (pgtype.Timestamptz, error) {
+	if v == nil {
+		return pgtype.Timestamptz{}, nil
+	}
+	if err := v.CheckValid(); err != nil {
+		return pgtype.Timestamptz{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return pgtype.Timestamptz{Time: v.AsTime(), Valid: true}, nil
+}`,
+		"parseNumeric": `
+// Helper function to convert string to pgtype.Numeric
+func parseNumeric(v string) (pgtype.Numeric, error) {
+	var n pgtype.Numeric
+	if v == "" {
+		return n, nil
+	}
+	if err := n.Scan(v); err != nil {
+		return pgtype.Numeric{}, fmt.Errorf("invalid numeric: %w", err)
+	}
+	return n, nil
+}`,
+		"parseUUID": `
+// Helper function to convert string to uuid.UUID
+func parseUUID(v string) (uuid.UUID, error) {
+	u, err := uuid.Parse(v)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid uuid: %w", err)
+	}
+	return u, nil
+}`,
+		"parseNullUUID": `
+// Helper function to convert string to uuid.NullUUID
+func parseNullUUID(v string) (uuid.NullUUID, error) {
+	if v == "" {
+		return uuid.NullUUID{}, nil
+	}
+	u, err := uuid.Parse(v)
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("invalid uuid: %w", err)
+	}
+	return uuid.NullUUID{UUID: u, Valid: true}, nil
+}`,
+		"parseJSON": `
+// Helper function to convert string to json.RawMessage
+func parseJSON(v string) (json.RawMessage, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if !json.Valid([]byte(v)) {
+		return nil, fmt.Errorf("invalid json")
+	}
+	return json.RawMessage(v), nil
+}`,
+		"parseInt16": `
+// Helper function to convert int32 to int16
+func parseInt16(v int32) (int16, error) {
+	if v < math.MinInt16 || v > math.MaxInt16 {
+		return 0, fmt.Errorf("value %d out of range for int16", v)
+	}
+	return int16(v), nil
+}`,
+		"parseNullInt16": `
+// Helper function to convert int32 to sql.NullInt16
+func parseNullInt16(v int32) (sql.NullInt16, error) {
+	if v < math.MinInt16 || v > math.MaxInt16 {
+		return sql.NullInt16{}, fmt.Errorf("value %d out of range for int16", v)
+	}
+	return sql.NullInt16{Int16: int16(v), Valid: v != 0}, nil
+}`,
+		// Slice helpers
+		"convertSlice": `
+// Helper function to convert a slice element-wise
+func convertSlice[T, U any](in []T, convert func(T) U) []U {
+	if in == nil {
+		return nil
+	}
+	out := make([]U, len(in))
+	for i, v := range in {
+		out[i] = convert(v)
+	}
+	return out
+}`,
+		"parseSlice": `
+// Helper function to convert a slice element-wise, stopping at the first invalid element
+func parseSlice[T, U any](in []T, convert func(T) (U, error)) ([]U, error) {
+	if in == nil {
+		return nil, nil
+	}
+	out := make([]U, len(in))
+	for i, v := range in {
+		u, err := convert(v)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		out[i] = u
+	}
+	return out, nil
 }`,
 		// CommandTag helpers
 		"commandTagToString": `
@@ -804,17 +1063,24 @@ func commandTagToString(v pgconn.CommandTag) string {
 		"stringToCommandTag": `
 // Helper function to convert string to pgconn.CommandTag
 func stringToCommandTag(v string) pgconn.CommandTag {
-	return pgconn.CommandTag(v)
+	return pgconn.NewCommandTag(v)
 }`,
 	}
 
-	// Build the output string with only the needed implementations
-	var implementations []string
+	// Build the output string with only the needed implementations,
+	// sorted by name so that the output is stable between runs
+	helperNames := make([]string, 0, len(neededHelpers))
 	for helperName, needed := range neededHelpers {
 		if needed {
-			if impl, ok := helperImplementations[helperName]; ok {
-				implementations = append(implementations, impl)
-			}
+			helperNames = append(helperNames, helperName)
+		}
+	}
+	sort.Strings(helperNames)
+
+	var implementations []string
+	for _, helperName := range helperNames {
+		if impl, ok := helperImplementations[helperName]; ok {
+			implementations = append(implementations, impl)
 		}
 	}
 
@@ -433,48 +433,6 @@ func TestProcessSQLCFile_ComplexTypes(t *testing.T) {
 	}
 }
 
-func TestAddCustomTypeMappings(t *testing.T) {
-	// Save original mappings
-	originalMappings := make(map[string]string)
-	for k, v := range TypeMapping {
-		originalMappings[k] = v
-	}
-
-	// Test adding custom type mappings
-	customMappings := map[string]string{
-		"custom.Type":  "custom.ProtoType",
-		"another.Type": "another.ProtoType",
-		"time.Time":    "custom.Timestamp", // Override existing mapping
-	}
-
-	AddCustomTypeMappings(customMappings)
-
-	// Check that custom mappings were added
-	for k, v := range customMappings {
-		if TypeMapping[k] != v {
-			t.Errorf("Custom mapping not added correctly: %s -> %s, got %s", k, v, TypeMapping[k])
-		}
-	}
-
-	// Check that original mappings are still present (except overridden ones)
-	for k, v := range originalMappings {
-		if k != "time.Time" && TypeMapping[k] != v {
-			t.Errorf("Original mapping changed: %s -> %s, now %s", k, v, TypeMapping[k])
-		}
-	}
-
-	// Verify the overridden mapping
-	if TypeMapping["time.Time"] != "custom.Timestamp" {
-		t.Errorf("Overridden mapping not applied: time.Time -> custom.Timestamp, got %s", TypeMapping["time.Time"])
-	}
-
-	// Restore original mappings for other tests
-	TypeMapping = make(map[string]string)
-	for k, v := range originalMappings {
-		TypeMapping[k] = v
-	}
-}
-
 func TestGenerateHelperFunctions(t *testing.T) {
 	// Create a sample message with fields that require helper functions
 	messages := []ProtoMessage{
@@ -562,6 +520,103 @@ func TestGenerateHelperFunctions(t *testing.T) {
 	}
 }
 
+func TestGenerateStrictHelperFunctions(t *testing.T) {
+	// Strict conversions are set by the parser for conversions that can fail
+	config := ParserConfig{
+		FieldStyle: "snake_case",
+		TypeConfig: GetTypeMapConfig(),
+	}
+
+	var fields []ProtoField
+	for _, goType := range []string{"uuid.UUID", "pgtype.Numeric", "int16", "string"} {
+		field := ProtoField{Name: "field", SQLCName: "Field"}
+		processStandardType(goType, &field, config.TypeConfig)
+		field.setConversionCode(goType, config.TypeConfig)
+		fields = append(fields, field)
+	}
+
+	if fields[0].StrictConversionCode != "parseUUID(in.Field)" {
+		t.Errorf("Expected parseUUID(in.Field), got %s", fields[0].StrictConversionCode)
+	}
+	if fields[3].StrictConversionCode != "" {
+		t.Errorf("Expected no strict conversion for string, got %s", fields[3].StrictConversionCode)
+	}
+
+	helpers := GenerateStrictHelperFunctions([]ProtoMessage{{Name: "TestMessage", Fields: fields}})
+
+	for _, helper := range []string{"type FieldError struct", "func parseUUID(", "func parseNumeric(", "func parseInt16(", "func uuidToString("} {
+		if !strings.Contains(helpers, helper) {
+			t.Errorf("Expected %s in generated strict helpers", helper)
+		}
+	}
+
+	// Lenient helpers are replaced by their strict counterparts
+	for _, helper := range []string{"func stringToUUID(", "func stringToNumeric("} {
+		if strings.Contains(helpers, helper) {
+			t.Errorf("Did not expect %s in generated strict helpers", helper)
+		}
+	}
+}
+
+func TestRepeatedFieldConversion(t *testing.T) {
+	typeConfig := GetTypeMapConfig()
+
+	tests := []struct {
+		goType         string
+		conversion     string
+		reverse        string
+		strict         string
+		expectedHelper string
+	}{
+		{
+			goType:     "[]string",
+			conversion: "in.Tags",
+			reverse:    "in.Tags",
+		},
+		{
+			goType:         "[]uuid.UUID",
+			conversion:     "convertSlice(in.Tags, func(v uuid.UUID) string { return uuidToString(v) })",
+			reverse:        "convertSlice(in.Tags, func(v string) uuid.UUID { return stringToUUID(v) })",
+			strict:         "parseSlice(in.Tags, func(v string) (uuid.UUID, error) { return parseUUID(v) })",
+			expectedHelper: "func convertSlice[",
+		},
+		{
+			goType:         "[]pgtype.Text",
+			conversion:     "convertSlice(in.Tags, func(v pgtype.Text) string { return pgtypeTextToString(v) })",
+			reverse:        "convertSlice(in.Tags, func(v string) pgtype.Text { return stringToPgtypeText(v) })",
+			expectedHelper: "func pgtypeTextToString(",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goType, func(t *testing.T) {
+			field := ProtoField{Name: "tags", SQLCName: "Tags"}
+			if !processArrayType(tt.goType, &field, typeConfig) {
+				t.Fatalf("processArrayType failed for %s", tt.goType)
+			}
+			field.setConversionCode(tt.goType, typeConfig)
+
+			if !field.IsRepeated {
+				t.Errorf("Expected %s to be repeated", tt.goType)
+			}
+			if field.ConversionCode != tt.conversion {
+				t.Errorf("Expected conversion %s, got %s", tt.conversion, field.ConversionCode)
+			}
+			if field.ReverseConversionCode != tt.reverse {
+				t.Errorf("Expected reverse conversion %s, got %s", tt.reverse, field.ReverseConversionCode)
+			}
+			if field.StrictConversionCode != tt.strict {
+				t.Errorf("Expected strict conversion %q, got %q", tt.strict, field.StrictConversionCode)
+			}
+
+			helpers := GenerateHelperFunctions([]ProtoMessage{{Name: "Post", Fields: []ProtoField{field}}})
+			if tt.expectedHelper != "" && !strings.Contains(helpers, tt.expectedHelper) {
+				t.Errorf("Expected %s in generated helpers", tt.expectedHelper)
+			}
+		})
+	}
+}
+
 func TestTypeConversion(t *testing.T) {
 	// Create test field
 	field := ProtoField{
@@ -595,8 +650,13 @@ func TestTypeConversion(t *testing.T) {
 		// Create a copy of the field for this test
 		testField := field
 		
-		// Process the field using our new implementation
+		// processStandardType only maps the type; the conversion code is
+		// built by setConversionCode once the field has its final name
 		processStandardType(tt.sqlType, &testField, config.TypeConfig)
+		if testField.ConversionCode != "" || testField.ReverseConversionCode != "" {
+			t.Errorf("processStandardType set conversion code for %q", tt.sqlType)
+		}
+		testField.setConversionCode(tt.sqlType, config.TypeConfig)
 		
 		// Check the result
 		if testField.ConversionCode != tt.expected {
@@ -625,8 +685,13 @@ func TestTypeConversion(t *testing.T) {
 		// Create a copy of the field for this test
 		testField := field
 		
-		// Process the field using our new implementation
+		// processStandardType only maps the type; the conversion code is
+		// built by setConversionCode once the field has its final name
 		processStandardType(tt.sqlType, &testField, config.TypeConfig)
+		if testField.ConversionCode != "" || testField.ReverseConversionCode != "" {
+			t.Errorf("processStandardType set conversion code for %q", tt.sqlType)
+		}
+		testField.setConversionCode(tt.sqlType, config.TypeConfig)
 		
 		// Check the result
 		if testField.ConversionCode != tt.expected {
@@ -635,9 +700,32 @@ func TestTypeConversion(t *testing.T) {
 	}
 }
 
+func TestGenerateConversionCodeAfterRename(t *testing.T) {
+	messages := []ProtoMessage{{
+		Name: "Member",
+		Fields: []ProtoField{
+			{Name: "email", SQLCName: "Email", GoType: "sql.NullString", Type: "string"},
+			{Name: "tags", SQLCName: "Tags", GoType: "[]uuid.UUID", Type: "string", IsRepeated: true},
+		},
+	}}
+
+	// Fields renamed before the code is generated are read by their new name
+	messages[0].Fields[0].Name = "email_address"
+	GenerateConversionCode(messages, GetTypeMapConfig())
+
+	email := messages[0].Fields[0]
+	if email.ConversionCode != "nullStringToString(in.Email)" || email.ReverseConversionCode != "stringToNullString(in.EmailAddress)" {
+		t.Errorf("Unexpected conversion code for the renamed field: %q / %q", email.ConversionCode, email.ReverseConversionCode)
+	}
+	tags := messages[0].Fields[1]
+	if !strings.HasPrefix(tags.ConversionCode, "convertSlice(in.Tags, ") || tags.StrictConversionCode == "" {
+		t.Errorf("Unexpected conversion code for the slice field: %q / %q", tags.ConversionCode, tags.StrictConversionCode)
+	}
+}
+
 // Helper function to manually generate conversion code for testing unknown types
 func TestGenerateConversionCode(t *testing.T) {
-	// This test verifies that we can generate proper conversion code using the ConversionMapping
+	// This test verifies that we can generate proper conversion code using the default conversion mappings
 	
 	// Directly test the conversion mapping approach
 	mapping := GetTypeMapConfig()
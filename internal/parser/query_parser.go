@@ -3,10 +3,8 @@ package parser
 import (
 	"fmt"
 	"go/ast"
-	"go/parser"
 	"go/token"
-	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/iancoleman/strcase"
@@ -14,21 +12,15 @@ import (
 
 // ParseSQLCQuerierInterface parses the Querier interface in a sqlc-generated directory
 func ParseSQLCQuerierInterface(dir string) ([]QueryMethod, error) {
-	// Look for the file containing the Querier interface
-	querierFile := findQuerierFile(dir)
-	if querierFile == "" {
-		return nil, fmt.Errorf("could not find querier.go file in %s", dir)
-	}
-
-	// Parse the file containing the Querier interface
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, querierFile, nil, parser.ParseComments)
+	pkg, err := LoadPackage(dir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse querier file: %w", err)
+		return nil, err
 	}
+	return pkg.QueryMethods()
+}
 
-	// Find the Querier interface
-	var querierInterface *ast.InterfaceType
+// findQuerierInterface returns the Querier interface declared in a file, or nil
+func findQuerierInterface(node *ast.File) *ast.InterfaceType {
 	for _, decl := range node.Decls {
 		genDecl, ok := decl.(*ast.GenDecl)
 		if !ok || genDecl.Tok != token.TYPE {
@@ -43,22 +35,17 @@ func ParseSQLCQuerierInterface(dir string) ([]QueryMethod, error) {
 
 			// Check if this is the Querier interface
 			if typeSpec.Name.Name == "Querier" {
-				if interfaceType, ok := typeSpec.Type.(*ast.InterfaceType); ok {
-					querierInterface = interfaceType
-					break
-				}
+				interfaceType, _ := typeSpec.Type.(*ast.InterfaceType)
+				return interfaceType
 			}
 		}
-
-		if querierInterface != nil {
-			break
-		}
 	}
 
-	if querierInterface == nil {
-		return nil, fmt.Errorf("querier interface not found in %s", querierFile)
-	}
+	return nil
+}
 
+// queryMethods extracts the query methods of the Querier interface
+func queryMethods(querierInterface *ast.InterfaceType) []QueryMethod {
 	// Extract methods from the Querier interface
 	var methods []QueryMethod
 	for _, method := range querierInterface.Methods.List {
@@ -155,74 +142,7 @@ func ParseSQLCQuerierInterface(dir string) ([]QueryMethod, error) {
 		methods = append(methods, queryMethod)
 	}
 
-	return methods, nil
-}
-
-// findQuerierFile finds the file containing the Querier interface
-func findQuerierFile(dir string) string {
-	// Common filenames for the Querier interface
-	possibleNames := []string{
-		"querier.go",
-		"db.go",
-		"interface.go",
-	}
-
-	for _, name := range possibleNames {
-		path := filepath.Join(dir, name)
-		if _, err := os.Stat(path); err == nil {
-			// Check if this file contains the Querier interface
-			if containsQuerierInterface(path) {
-				return path
-			}
-		}
-	}
-
-	// If not found, search all Go files in the directory
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return ""
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
-			path := filepath.Join(dir, entry.Name())
-			if containsQuerierInterface(path) {
-				return path
-			}
-		}
-	}
-
-	return ""
-}
-
-// containsQuerierInterface checks if a file contains the Querier interface
-func containsQuerierInterface(filePath string) bool {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filePath, nil, 0)
-	if err != nil {
-		return false
-	}
-
-	for _, decl := range node.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.TYPE {
-			continue
-		}
-
-		for _, spec := range genDecl.Specs {
-			typeSpec, ok := spec.(*ast.TypeSpec)
-			if !ok {
-				continue
-			}
-
-			if typeSpec.Name.Name == "Querier" {
-				_, ok := typeSpec.Type.(*ast.InterfaceType)
-				return ok
-			}
-		}
-	}
-
-	return false
+	return methods
 }
 
 // typeToString converts an AST type expression to a string
@@ -247,12 +167,14 @@ func typeToString(expr ast.Expr) string {
 	}
 }
 
-// GenerateServiceDefinitions creates service definitions from query methods
-func GenerateServiceDefinitions(queryMethods []QueryMethod, messages []ProtoMessage) []ServiceDefinition {
+// GenerateServiceDefinitions creates service definitions from query methods,
+// mapping parameter types with typeConfig. Overrides, keyed by query name, take
+// precedence over the inferred grouping and naming.
+func GenerateServiceDefinitions(queryMethods []QueryMethod, messages []ProtoMessage, typeConfig TypeMappingConfig, overrides map[string]MethodOverride) []ServiceDefinition {
 	// Group methods by entity
 	methodsByEntity := make(map[string][]QueryMethod)
 	for _, method := range queryMethods {
-		entity := inferEntityFromMethodName(method.Name)
+		entity := serviceEntity(method.Name, overrides[method.Name])
 		methodsByEntity[entity] = append(methodsByEntity[entity], method)
 	}
 
@@ -262,9 +184,16 @@ func GenerateServiceDefinitions(queryMethods []QueryMethod, messages []ProtoMess
 		messageMap[msg.Name] = msg
 	}
 
-	// Generate service definitions
+	// Generate service definitions in a stable order
+	entities := make([]string, 0, len(methodsByEntity))
+	for entity := range methodsByEntity {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+
 	var services []ServiceDefinition
-	for entity, methods := range methodsByEntity {
+	for _, entity := range entities {
+		methods := methodsByEntity[entity]
 		service := ServiceDefinition{
 			Name:        entity + "Service",
 			Description: fmt.Sprintf("Service for %s operations", entity),
@@ -272,145 +201,179 @@ func GenerateServiceDefinitions(queryMethods []QueryMethod, messages []ProtoMess
 		}
 
 		for _, method := range methods {
-			serviceMethod := ServiceMethod{
-				Name:          method.Name,
-				Description:   method.Comment,
-				RequestType:   method.Name + "Request",
-				ResponseType:  method.Name + "Response",
-				OriginalQuery: &method,
-			}
+			service.Methods = append(service.Methods, newServiceMethod(method, entity, messageMap, typeConfig, overrides[method.Name]))
+		}
 
-			// Generate request fields based on parameter types
-			if len(method.ParamTypes) > 0 {
-				for i, param := range method.ParamTypes {
-					// Check if the parameter type is a known message type
-					if _, ok := messageMap[param.Type]; ok {
-						// If it's a known message type, include it directly
-						protoField := ProtoField{
-							Name:    strcase.ToSnake(param.Type),
-							Type:    param.Type,
-							Number:  i + 1,
-							Comment: fmt.Sprintf("%s to process", param.Type),
-						}
-						serviceMethod.RequestFields = append(serviceMethod.RequestFields, protoField)
-					} else {
-						// For primitive types or unknown types, use the parameter name
-						// Map Go type to Proto type
-						protoType := mapGoTypeToProtoType(param.Type)
-
-						protoField := ProtoField{
-							Name:    strcase.ToSnake(param.Name),
-							Type:    protoType,
-							Number:  i + 1,
-							Comment: fmt.Sprintf("%s parameter", param.Name),
-						}
-						serviceMethod.RequestFields = append(serviceMethod.RequestFields, protoField)
-					}
-				}
+		services = append(services, service)
+	}
 
-				// Add pagination fields for list methods
-				if strings.HasPrefix(method.Name, "List") {
-					// Only add pagination if not already present
-					hasLimit := false
-					hasOffset := false
-					for _, field := range serviceMethod.RequestFields {
-						if field.Name == "limit" {
-							hasLimit = true
-						}
-						if field.Name == "offset" {
-							hasOffset = true
-						}
-					}
+	return services
+}
 
-					if !hasLimit {
-						serviceMethod.RequestFields = append(serviceMethod.RequestFields, ProtoField{
-							Name:    "limit",
-							Type:    "int32",
-							Number:  len(serviceMethod.RequestFields) + 1,
-							Comment: "Maximum number of results to return",
-						})
-					}
+// serviceEntity returns the entity whose service a query belongs to
+func serviceEntity(methodName string, override MethodOverride) string {
+	if override.Service != "" {
+		return strings.TrimSuffix(override.Service, "Service")
+	}
+	return inferEntityFromMethodName(methodName)
+}
 
-					if !hasOffset {
-						serviceMethod.RequestFields = append(serviceMethod.RequestFields, ProtoField{
-							Name:    "page_token",
-							Type:    "string",
-							Number:  len(serviceMethod.RequestFields) + 1,
-							Comment: "Page token for pagination",
-						})
-					}
+// newServiceMethod creates a service method with its own request and response
+// messages for a single query method
+func newServiceMethod(method QueryMethod, entity string, messageMap map[string]ProtoMessage, typeConfig TypeMappingConfig, override MethodOverride) ServiceMethod {
+	name := method.Name
+	if override.RPCName != "" {
+		name = override.RPCName
+	}
+
+	description := method.Comment
+	if override.Description != "" {
+		description = override.Description
+	}
+
+	// List methods are paginated unless configured otherwise
+	paginated := strings.HasPrefix(name, "List")
+	if override.Pagination != nil {
+		paginated = *override.Pagination
+	}
+
+	serviceMethod := ServiceMethod{
+		Name:            name,
+		Description:     description,
+		RequestType:     name + "Request",
+		ResponseType:    name + "Response",
+		OriginalQuery:   &method,
+		Paginated:       paginated,
+		StreamingServer: override.Streaming == "server",
+		StreamingSet:    override.Streaming != "",
+	}
+
+	// Generate request fields based on parameter types
+	if len(method.ParamTypes) > 0 {
+		for i, param := range method.ParamTypes {
+			// Check if the parameter type is a known message type
+			if msg, ok := messageMap[param.Type]; ok {
+				// If it's a known message type, include it directly
+				serviceMethod.Params = &msg
+				protoField := ProtoField{
+					Name:    strcase.ToSnake(param.Type),
+					Type:    param.Type,
+					Number:  i + 1,
+					Comment: fmt.Sprintf("%s to process", param.Type),
 				}
-			} else if strings.HasPrefix(method.Name, "Get") || strings.HasPrefix(method.Name, "Delete") {
-				// For Get and Delete methods without parameters, add an ID field
+				serviceMethod.RequestFields = append(serviceMethod.RequestFields, protoField)
+			} else {
+				// For primitive types or unknown types, use the parameter name
+				// Map Go type to Proto type
+				protoType := mapGoTypeToProtoType(param.Type, typeConfig)
+
+				protoField := ProtoField{
+					Name:    strcase.ToSnake(param.Name),
+					Type:    protoType,
+					Number:  i + 1,
+					Comment: fmt.Sprintf("%s parameter", param.Name),
+				}
+				serviceMethod.RequestFields = append(serviceMethod.RequestFields, protoField)
+			}
+		}
+
+		// Add pagination fields for list methods
+		if paginated {
+			// Only add pagination if not already present
+			hasLimit := false
+			hasOffset := false
+			for _, field := range serviceMethod.RequestFields {
+				if field.Name == "limit" {
+					hasLimit = true
+				}
+				if field.Name == "offset" {
+					hasOffset = true
+				}
+			}
+
+			if !hasLimit {
 				serviceMethod.RequestFields = append(serviceMethod.RequestFields, ProtoField{
-					Name:    strcase.ToSnake(entity) + "_id",
+					Name:    "limit",
 					Type:    "int32",
-					Number:  1,
-					Comment: fmt.Sprintf("ID of the %s", entity),
+					Number:  len(serviceMethod.RequestFields) + 1,
+					Comment: "Maximum number of results to return",
 				})
 			}
 
-			// Generate response fields based on return type
-			if method.ReturnType != "" {
-				if !method.IsArray {
-					// For single result methods
-					serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
-						Name:    strcase.ToSnake(method.ReturnType),
-						Type:    method.ReturnType,
-						Number:  1,
-						Comment: fmt.Sprintf("The %s result", method.ReturnType),
-					})
-				} else {
-					// For list/array result methods
-					serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
-						Name:       strcase.ToSnake(method.ReturnType) + "s",
-						Type:       method.ReturnType,
-						Number:     1,
-						IsRepeated: true,
-						Comment:    fmt.Sprintf("List of %s results", method.ReturnType),
-					})
+			if !hasOffset {
+				serviceMethod.RequestFields = append(serviceMethod.RequestFields, ProtoField{
+					Name:    "page_token",
+					Type:    "string",
+					Number:  len(serviceMethod.RequestFields) + 1,
+					Comment: "Page token for pagination",
+				})
+			}
+		}
+	} else if strings.HasPrefix(method.Name, "Get") || strings.HasPrefix(method.Name, "Delete") {
+		// For Get and Delete methods without parameters, add an ID field
+		serviceMethod.RequestFields = append(serviceMethod.RequestFields, ProtoField{
+			Name:    strcase.ToSnake(entity) + "_id",
+			Type:    "int32",
+			Number:  1,
+			Comment: fmt.Sprintf("ID of the %s", entity),
+		})
+	}
 
-					// Add pagination metadata for list methods
-					if strings.HasPrefix(method.Name, "List") {
-						serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
-							Name:    "next_page_token",
-							Type:    "string",
-							Number:  2,
-							Comment: "Token for retrieving the next page of results",
-						})
-
-						serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
-							Name:    "total_size",
-							Type:    "int32",
-							Number:  3,
-							Comment: "Total number of results available",
-						})
-					}
-				}
-			} else if method.Type == QueryTypeExec {
-				// For exec-type methods with no return value, add a success flag
+	// Generate response fields based on return type
+	if method.ReturnType != "" {
+		if !method.IsArray {
+			// For single result methods
+			serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
+				Name:    strcase.ToSnake(method.ReturnType),
+				Type:    method.ReturnType,
+				Number:  1,
+				Comment: fmt.Sprintf("The %s result", method.ReturnType),
+			})
+		} else {
+			// For list/array result methods
+			serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
+				Name:       strcase.ToSnake(method.ReturnType) + "s",
+				Type:       method.ReturnType,
+				Number:     1,
+				IsRepeated: true,
+				Comment:    fmt.Sprintf("List of %s results", method.ReturnType),
+			})
+
+			// Add pagination metadata for list methods
+			if paginated {
 				serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
-					Name:    "success",
-					Type:    "bool",
-					Number:  1,
-					Comment: "Whether the operation was successful",
+					Name:    "next_page_token",
+					Type:    "string",
+					Number:  2,
+					Comment: "Token for retrieving the next page of results",
 				})
 
 				serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
-					Name:    "affected_rows",
+					Name:    "total_size",
 					Type:    "int32",
-					Number:  2,
-					Comment: "Number of rows affected by the operation",
+					Number:  3,
+					Comment: "Total number of results available",
 				})
 			}
-
-			service.Methods = append(service.Methods, serviceMethod)
 		}
-
-		services = append(services, service)
+	} else if method.Type == QueryTypeExec {
+		// For exec-type methods with no return value, add a success flag
+		serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
+			Name:    "success",
+			Type:    "bool",
+			Number:  1,
+			Comment: "Whether the operation was successful",
+		})
+
+		serviceMethod.ResponseFields = append(serviceMethod.ResponseFields, ProtoField{
+			Name:    "affected_rows",
+			Type:    "int32",
+			Number:  2,
+			Comment: "Number of rows affected by the operation",
+		})
 	}
 
-	return services
+	return serviceMethod
 }
 
 // inferEntityFromMethodName extracts the entity name from a method name
@@ -449,14 +412,14 @@ func inferEntityFromMethodName(methodName string) string {
 }
 
 // mapGoTypeToProtoType converts Go types to Protocol Buffer types
-func mapGoTypeToProtoType(goType string) string {
-	// Use the global TypeMapping map instead of a hardcoded mapping
+func mapGoTypeToProtoType(goType string, typeConfig TypeMappingConfig) string {
+	// Use the same mappings as the models instead of a hardcoded mapping
 	// This ensures consistency with model type mappings and allows for custom mappings
 
 	// Handle pointer types
 	if strings.HasPrefix(goType, "*") {
 		baseType := strings.TrimPrefix(goType, "*")
-		if protoType, ok := TypeMapping[baseType]; ok {
+		if protoType, ok := typeConfig.StandardTypes[baseType]; ok {
 			return protoType
 		}
 		return baseType // Pass through as is
@@ -466,14 +429,14 @@ func mapGoTypeToProtoType(goType string) string {
 	if strings.HasPrefix(goType, "[]") {
 		// For arrays, we'll handle the repeated tag separately
 		baseType := strings.TrimPrefix(goType, "[]")
-		if protoType, ok := TypeMapping[baseType]; ok {
+		if protoType, ok := typeConfig.StandardTypes[baseType]; ok {
 			return protoType
 		}
 		return baseType // Pass through as is
 	}
 
 	// Direct mapping
-	if protoType, ok := TypeMapping[goType]; ok {
+	if protoType, ok := typeConfig.StandardTypes[goType]; ok {
 		return protoType
 	}
 
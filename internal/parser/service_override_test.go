@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestGenerateServiceDefinitions_Overrides(t *testing.T) {
+	messages := []ProtoMessage{
+		{Name: "Loan", SQLCStruct: "Loan"},
+	}
+
+	queryMethods := []QueryMethod{
+		{Name: "ListActiveLoansByMember", Type: QueryTypeMany, ParamTypes: []ParamType{{Name: "memberID", Type: "int32"}}, ReturnType: "Loan", IsArray: true},
+		{Name: "ReturnBook", Type: QueryTypeOne, ParamTypes: []ParamType{{Name: "id", Type: "int32"}}, ReturnType: "Loan", Comment: "Marks a loan as returned"},
+	}
+
+	noPagination := false
+	overrides := map[string]MethodOverride{
+		"ListActiveLoansByMember": {
+			Service:    "Loan",
+			RPCName:    "ListMemberLoans",
+			Streaming:  "server",
+			Pagination: &noPagination,
+		},
+		"ReturnBook": {
+			Service:     "LoanService",
+			Description: "Returns a borrowed book",
+		},
+	}
+
+	services := GenerateServiceDefinitions(queryMethods, messages, GetTypeMapConfig(), overrides)
+
+	if len(services) != 1 {
+		t.Fatalf("Expected both queries in a single service, got %d services", len(services))
+	}
+	if services[0].Name != "LoanService" {
+		t.Errorf("Expected LoanService, got %s", services[0].Name)
+	}
+
+	methods := make(map[string]ServiceMethod)
+	for _, method := range services[0].Methods {
+		methods[method.Name] = method
+	}
+
+	list, ok := methods["ListMemberLoans"]
+	if !ok {
+		t.Fatalf("Expected ListMemberLoans RPC, got %v", services[0].Methods)
+	}
+	if list.RequestType != "ListMemberLoansRequest" || list.ResponseType != "ListMemberLoansResponse" {
+		t.Errorf("Unexpected request/response types: %s, %s", list.RequestType, list.ResponseType)
+	}
+	if !list.StreamingServer || !list.StreamingSet {
+		t.Errorf("Expected ListMemberLoans to be explicitly server streaming")
+	}
+	if list.Paginated {
+		t.Errorf("Expected pagination to be disabled")
+	}
+	for _, field := range list.RequestFields {
+		if field.Name == "limit" || field.Name == "page_token" {
+			t.Errorf("Unexpected pagination field %s", field.Name)
+		}
+	}
+
+	returnBook, ok := methods["ReturnBook"]
+	if !ok {
+		t.Fatalf("Expected ReturnBook RPC")
+	}
+	if returnBook.Description != "Returns a borrowed book" {
+		t.Errorf("Expected description override, got %s", returnBook.Description)
+	}
+	if returnBook.StreamingSet {
+		t.Errorf("ReturnBook should use the default streaming options")
+	}
+}
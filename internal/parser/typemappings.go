@@ -4,8 +4,8 @@ import (
 	"maps"
 )
 
-// TypeMapping maps Go types to Protobuf types
-var TypeMapping = map[string]string{
+// typeMapping maps Go types to Protobuf types
+var typeMapping = map[string]string{
 	"string":             "string",
 	"int":                "int32",
 	"int16":              "int32", // Added for smallint/int2
@@ -26,8 +26,8 @@ var TypeMapping = map[string]string{
 	"pgconn.CommandTag":  "string", // Added for command tag results
 }
 
-// NullableTypeMapping maps sqlc nullable types to Protobuf types
-var NullableTypeMapping = map[string]string{
+// nullableTypeMapping maps sqlc nullable types to Protobuf types
+var nullableTypeMapping = map[string]string{
 	"sql.NullString":  "string",
 	"sql.NullInt16":   "int32", // Added for nullable smallint
 	"sql.NullInt32":   "int32",
@@ -38,79 +38,114 @@ var NullableTypeMapping = map[string]string{
 	"uuid.NullUUID":   "string", // Added for nullable UUID
 }
 
-// ConversionMapping maps Go types to conversion function templates
-var ConversionMapping = map[string]ConversionFuncs{
+// protoGoTypes maps Protobuf types to the Go types generated by protoc-gen-go
+var protoGoTypes = map[string]string{
+	"string":                    "string",
+	"int32":                     "int32",
+	"int64":                     "int64",
+	"uint32":                    "uint32",
+	"uint64":                    "uint64",
+	"float":                     "float32",
+	"double":                    "float64",
+	"bool":                      "bool",
+	"bytes":                     "[]byte",
+	"google.protobuf.Timestamp": "*timestamppb.Timestamp",
+}
+
+// conversionMapping maps Go types to conversion function templates
+var conversionMapping = map[string]ConversionFuncs{
 	"time.Time": {
-		ToProto:   "timestamppb.New(%s)",
-		FromProto: "%s.AsTime()",
+		ToProto:         "timestamppb.New(%s)",
+		FromProto:       "%s.AsTime()",
+		StrictFromProto: "parseRequiredTime(%s)",
 	},
 	"pgtype.Date": {
-		ToProto:   "dateToTimestamp(%s)",
-		FromProto: "timestampToDate(%s)",
+		ToProto:         "dateToTimestamp(%s)",
+		FromProto:       "timestampToDate(%s)",
+		StrictFromProto: "parseDate(%s)",
+		Lossy:           "NULL dates come back as valid zero dates",
 	},
 	"pgtype.Timestamptz": {
-		ToProto:   "timestamptzToTimestamp(%s)",
-		FromProto: "timestampToTimestamptz(%s)",
+		ToProto:         "timestamptzToTimestamp(%s)",
+		FromProto:       "timestampToTimestamptz(%s)",
+		StrictFromProto: "parseTimestamptz(%s)",
+		Lossy:           "NULL timestamps keep the Unix epoch as their time",
 	},
 	"pgtype.Text": {
 		ToProto:   "pgtypeTextToString(%s)",
 		FromProto: "stringToPgtypeText(%s)",
+		Lossy:     "empty strings are stored as NULL",
 	},
 	"pgtype.Numeric": {
-		ToProto:   "numericToString(%s)",
-		FromProto: "stringToNumeric(%s)",
+		ToProto:         "numericToString(%s)",
+		FromProto:       "stringToNumeric(%s)",
+		StrictFromProto: "parseNumeric(%s)",
+		Lossy:           "values are normalised through their decimal string",
 	},
 	"uuid.UUID": {
-		ToProto:   "uuidToString(%s)",
-		FromProto: "stringToUUID(%s)",
+		ToProto:         "uuidToString(%s)",
+		FromProto:       "stringToUUID(%s)",
+		StrictFromProto: "parseUUID(%s)",
 	},
 	"json.RawMessage": {
-		ToProto:   "jsonToString(%s)",
-		FromProto: "stringToJSON(%s)",
+		ToProto:         "jsonToString(%s)",
+		FromProto:       "stringToJSON(%s)",
+		StrictFromProto: "parseJSON(%s)",
 	},
 	"pgtype.Interval": {
 		ToProto:   "intervalToInt64(%s)",
 		FromProto: "int64ToInterval(%s)",
+		Lossy:     "days and months are dropped",
 	},
 	"pgconn.CommandTag": {
 		ToProto:   "commandTagToString(%s)",
 		FromProto: "stringToCommandTag(%s)",
 	},
 	"int16": {
-		ToProto:   "int32(%s)",
-		FromProto: "int16(%s)",
+		ToProto:         "int32(%s)",
+		FromProto:       "int16(%s)",
+		StrictFromProto: "parseInt16(%s)",
 	},
 	"sql.NullString": {
 		ToProto:   "nullStringToString(%s)",
 		FromProto: "stringToNullString(%s)",
+		Lossy:     "empty strings are stored as NULL",
 	},
 	"sql.NullInt16": {
-		ToProto:   "nullInt16ToInt32(%s)",
-		FromProto: "int32ToNullInt16(%s)",
+		ToProto:         "nullInt16ToInt32(%s)",
+		FromProto:       "int32ToNullInt16(%s)",
+		StrictFromProto: "parseNullInt16(%s)",
+		Lossy:           "zero is stored as NULL",
 	},
 	"sql.NullInt32": {
 		ToProto:   "nullInt32ToInt32(%s)",
 		FromProto: "int32ToNullInt32(%s)",
+		Lossy:     "zero is stored as NULL",
 	},
 	"sql.NullInt64": {
 		ToProto:   "nullInt64ToInt64(%s)",
 		FromProto: "int64ToNullInt64(%s)",
+		Lossy:     "zero is stored as NULL",
 	},
 	"sql.NullFloat64": {
 		ToProto:   "nullFloat64ToFloat64(%s)",
 		FromProto: "float64ToNullFloat64(%s)",
+		Lossy:     "zero is stored as NULL",
 	},
 	"sql.NullBool": {
 		ToProto:   "nullBoolToBool(%s)",
 		FromProto: "boolToNullBool(%s)",
+		Lossy:     "NULL comes back as false",
 	},
 	"sql.NullTime": {
-		ToProto:   "nullTimeToTimestamp(%s)",
-		FromProto: "timestampToNullTime(%s)",
+		ToProto:         "nullTimeToTimestamp(%s)",
+		FromProto:       "timestampToNullTime(%s)",
+		StrictFromProto: "parseNullTime(%s)",
 	},
 	"uuid.NullUUID": {
-		ToProto:   "nullUUIDToString(%s)",
-		FromProto: "stringToNullUUID(%s)",
+		ToProto:         "nullUUIDToString(%s)",
+		FromProto:       "stringToNullUUID(%s)",
+		StrictFromProto: "parseNullUUID(%s)",
 	},
 }
 
@@ -118,24 +153,31 @@ var ConversionMapping = map[string]ConversionFuncs{
 type ConversionFuncs struct {
 	ToProto   string // Template for converting from Go to Proto
 	FromProto string // Template for converting from Proto to Go
-}
 
-// AddCustomTypeMappings adds custom type mappings
-func AddCustomTypeMappings(mappings map[string]string) {
-	maps.Copy(TypeMapping, mappings)
+	// Template for converting from Proto to Go in strict mappers, returning
+	// (value, error); empty if the conversion can't fail
+	StrictFromProto string
+
+	// Why a value doesn't survive a round trip through proto, e.g. "zero is
+	// stored as NULL"; empty if the conversion is lossless
+	Lossy string
 }
 
-// AddCustomNullableTypeMappings adds custom nullable type mappings
-func AddCustomNullableTypeMappings(mappings map[string]string) {
-	maps.Copy(NullableTypeMapping, mappings)
+// NewTypeMapConfig returns the default mappings extended with custom
+// standard and nullable type mappings
+func NewTypeMapConfig(custom, nullable map[string]string) TypeMappingConfig {
+	config := GetTypeMapConfig()
+	maps.Copy(config.StandardTypes, custom)
+	maps.Copy(config.NullableTypes, nullable)
+	return config
 }
 
-// GetTypeMapConfig returns a TypeMappingConfig based on the current mappings
+// GetTypeMapConfig returns a copy of the default mappings
 func GetTypeMapConfig() TypeMappingConfig {
 	return TypeMappingConfig{
-		StandardTypes:    maps.Clone(TypeMapping),
-		NullableTypes:    maps.Clone(NullableTypeMapping),
-		CustomConverters: maps.Clone(ConversionMapping),
+		StandardTypes:    maps.Clone(typeMapping),
+		NullableTypes:    maps.Clone(nullableTypeMapping),
+		CustomConverters: maps.Clone(conversionMapping),
 	}
 }
 
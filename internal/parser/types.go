@@ -17,6 +17,12 @@ type QueryMethod struct {
 	ReturnType string
 	IsArray    bool
 	Comment    string
+
+	// Annotation is the sqlc query annotation, e.g. "one" or "execrows",
+	// and SQL the query text. Both are empty if the query constant wasn't
+	// found in the sqlc output.
+	Annotation string
+	SQL        string
 }
 
 // ParamType represents a parameter type
@@ -30,6 +36,23 @@ type ServiceDefinition struct {
 	Name        string
 	Description string
 	Methods     []ServiceMethod
+	Resource    *ResourceDefinition // Set for resource-oriented (AIP) services
+}
+
+// ResourceDefinition describes the AIP resource a service operates on
+type ResourceDefinition struct {
+	Type     string // Resource type, e.g. "library.example.com/Book"
+	Pattern  string // Resource name pattern, e.g. "books/{book}"
+	Singular string // Singular resource name, e.g. "book"
+	Plural   string // Plural resource name, e.g. "books"
+	Message  string // Name of the message representing the resource
+	IDField  string // Name of the primary key field in the sqlc struct
+	IDName   string // Name of the primary key field in the proto message
+	IDType   string // Proto type of the primary key
+
+	// NameField is the field of the message holding the resource name, e.g.
+	// "name", or "resource_name" if the model has a name column
+	NameField string
 }
 
 // ServiceMethod represents a method in a service definition
@@ -43,5 +66,38 @@ type ServiceMethod struct {
 	OriginalQuery   *QueryMethod
 	StreamingServer bool
 	StreamingClient bool
+
+	// Params is the sqlc params struct carried by the request, e.g.
+	// UpdateBookParams, nil if the query takes plain arguments
+	Params *ProtoMessage
+
+	// StandardMethod is the AIP standard method kind ("Get", "List", "Create",
+	// "Update" or "Delete"), empty for custom methods
+	StandardMethod string
+
+	// OmitRequestMessage and OmitResponseMessage are set when the request or
+	// response type is defined elsewhere (e.g. a model or a well-known type)
+	OmitRequestMessage  bool
+	OmitResponseMessage bool
+
+	// Options are method-level options, e.g. google.api.http rules
+	Options []string
+
+	// Paginated is set for list methods carrying pagination fields
+	Paginated bool
+
+	// StreamingSet is set when the streaming mode was chosen explicitly
+	// and should not be changed by the streaming options
+	StreamingSet bool
+}
+
+// MethodOverride holds explicit settings for the service method generated
+// from a query, overriding the grouping and naming heuristics
+type MethodOverride struct {
+	Service     string // Entity of the target service, e.g. "Loan" for LoanService
+	RPCName     string // Name of the RPC, also used for its request and response types
+	Streaming   string // "none" or "server", empty to use the streaming options
+	Pagination  *bool  // Whether to add pagination fields, nil to infer from the name
+	Description string // Description of the RPC, replacing the query comment
 }
 
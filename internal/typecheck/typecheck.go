@@ -0,0 +1,208 @@
+// Package typecheck compiles generated mappers against the sqlc and
+// protobuf-generated Go packages and maps compile errors back to the proto
+// messages and fields they come from.
+package typecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+	"github.com/iancoleman/strcase"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Problem is a compile error in the generated mappers or the packages they
+// import
+type Problem struct {
+	Pos       string // Position of the error, e.g. "proto/mappers/mappers.go:12:3"
+	Message   string // Proto message of the converter, empty if outside a converter
+	Field     string // Proto field name, empty if the error isn't tied to a field
+	SQLCField string // sqlc struct field, e.g. "Book.PublishedAt"
+	GoType    string // Go type of the sqlc struct field
+	Err       string // Compiler message
+}
+
+// String formats the problem for the check command
+func (p Problem) String() string {
+	location := p.Message
+	if p.Field != "" {
+		location += "." + p.Field
+		if p.SQLCField != "" {
+			location += fmt.Sprintf(" (sqlc field %s %s)", p.SQLCField, p.GoType)
+		}
+	}
+	if location == "" {
+		return fmt.Sprintf("%s: %s", p.Pos, p.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Pos, location, p.Err)
+}
+
+// CheckMappers loads the mapper package in dir together with its
+// dependencies and returns the compile errors found. Errors in the mapper
+// package are attributed to the message and field whose conversion caused
+// them, using the parsed sqlc messages.
+func CheckMappers(dir string, messages []parser.ProtoMessage) ([]Problem, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+			packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Dir: dir,
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mapper package: %w", err)
+	}
+
+	converters := converterMessages(messages)
+
+	var problems []Problem
+	seen := make(map[string]bool)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, pkgErr := range pkg.Errors {
+			// Dependency errors are reported once per package, not by every importer
+			key := pkgErr.Pos + pkgErr.Msg
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			problem := Problem{Pos: pkgErr.Pos, Err: pkgErr.Msg}
+			if pkgErr.Pos == "" {
+				problem.Pos = pkg.PkgPath
+			}
+			if pkg == pkgs[0] {
+				attribute(&problem, pkg, converters)
+			}
+			problems = append(problems, problem)
+		}
+	})
+
+	problems = dropUnresolvedImports(problems)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Pos < problems[j].Pos
+	})
+	return problems, nil
+}
+
+// dropUnresolvedImports removes the type checker's "could not import"
+// errors for packages go list already explained why it couldn't load
+func dropUnresolvedImports(problems []Problem) []Problem {
+	var kept []Problem
+	for _, problem := range problems {
+		var path string
+		if _, err := fmt.Sscanf(problem.Err, "could not import %s", &path); err == nil {
+			explained := false
+			for _, other := range problems {
+				if other.Err != problem.Err && strings.Contains(other.Err, path) {
+					explained = true
+					break
+				}
+			}
+			if explained {
+				continue
+			}
+		}
+		kept = append(kept, problem)
+	}
+	return kept
+}
+
+// converterMessages maps the names of the generated converter functions to
+// the message they convert
+func converterMessages(messages []parser.ProtoMessage) map[string]*parser.ProtoMessage {
+	converters := make(map[string]*parser.ProtoMessage)
+	for i := range messages {
+		msg := &messages[i]
+		for _, name := range []string{msg.SQLCStruct, parser.SliceName(msg.SQLCStruct)} {
+			converters[name+"ToProto"] = msg
+			converters[name+"FromProto"] = msg
+		}
+	}
+	return converters
+}
+
+// attribute fills in the message and field of a problem from the converter
+// function and struct field at its position
+func attribute(problem *Problem, pkg *packages.Package, converters map[string]*parser.ProtoMessage) {
+	pos := findPos(pkg, problem.Pos)
+	if !pos.IsValid() {
+		return
+	}
+
+	var file *ast.File
+	for _, f := range pkg.Syntax {
+		if f.Pos() <= pos && pos <= f.End() {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		return
+	}
+
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+
+	var fieldName string
+	for _, node := range path {
+		switch n := node.(type) {
+		case *ast.KeyValueExpr:
+			// Composite literal fields, e.g. PublishedAt: dateToTimestamp(in.PublishedAt)
+			if key, ok := n.Key.(*ast.Ident); ok && fieldName == "" {
+				fieldName = key.Name
+			}
+		case *ast.AssignStmt:
+			// Strict conversions, e.g. out.PublishedAt, err = parseDate(in.PublishedAt)
+			if sel, ok := n.Lhs[0].(*ast.SelectorExpr); ok && fieldName == "" {
+				fieldName = sel.Sel.Name
+			}
+		case *ast.FuncDecl:
+			msg, ok := converters[n.Name.Name]
+			if !ok {
+				return
+			}
+			problem.Message = msg.Name
+			for _, field := range msg.Fields {
+				if fieldName != "" && (fieldName == field.SQLCName || fieldName == strcase.ToCamel(field.Name)) {
+					problem.Field = field.Name
+					problem.SQLCField = msg.SQLCStruct + "." + field.SQLCName
+					problem.GoType = field.GoType
+				}
+			}
+			return
+		}
+	}
+}
+
+// findPos converts a "file:line:col" error position to a token.Pos in the package
+func findPos(pkg *packages.Package, position string) token.Pos {
+	parts := strings.Split(position, ":")
+	if len(parts) < 3 {
+		return token.NoPos
+	}
+	line, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return token.NoPos
+	}
+	col, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return token.NoPos
+	}
+	filename := strings.Join(parts[:len(parts)-2], ":")
+
+	pos := token.NoPos
+	pkg.Fset.Iterate(func(f *token.File) bool {
+		if f.Name() != filename {
+			return true
+		}
+		if line >= 1 && line <= f.LineCount() {
+			pos = f.LineStart(line) + token.Pos(col-1)
+		}
+		return false
+	})
+	return pos
+}
@@ -0,0 +1,116 @@
+package typecheck
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+// writeModule writes a module with sqlc, protobuf and mapper packages
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/app\n\ngo 1.21\n"
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCheckMappers(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"db/models.go": `package db
+
+type Book struct {
+	ID    int64
+	Title string
+}
+`,
+		"pb/models.pb.go": `package pb
+
+type Book struct {
+	Id    int64
+	Title int32
+}
+`,
+		"mappers/mappers.go": `package mappers
+
+import (
+	db "example.com/app/db"
+	pb "example.com/app/pb"
+)
+
+func BookToProto(in *db.Book) *pb.Book {
+	return &pb.Book{
+		Id:    in.ID,
+		Title: in.Title,
+	}
+}
+`,
+	})
+
+	messages := []parser.ProtoMessage{{
+		Name:       "Book",
+		SQLCStruct: "Book",
+		Fields: []parser.ProtoField{
+			{Name: "id", SQLCName: "ID", GoType: "int64"},
+			{Name: "title", SQLCName: "Title", GoType: "string"},
+		},
+	}}
+
+	problems, err := CheckMappers(filepath.Join(dir, "mappers"), messages)
+	if err != nil {
+		t.Fatalf("CheckMappers failed: %v", err)
+	}
+	if len(problems) != 1 {
+		t.Fatalf("Expected 1 problem, got %d: %v", len(problems), problems)
+	}
+
+	problem := problems[0]
+	if problem.Message != "Book" || problem.Field != "title" {
+		t.Errorf("Expected problem in Book.title, got %q.%q", problem.Message, problem.Field)
+	}
+	if problem.SQLCField != "Book.Title" {
+		t.Errorf("Expected sqlc field Book.Title, got %q", problem.SQLCField)
+	}
+	if !strings.Contains(problem.String(), "mappers.go:11:") {
+		t.Errorf("Expected position mappers.go:11, got %s", problem)
+	}
+}
+
+func TestCheckMappersMissingPackage(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"db/models.go": "package db\n\ntype Book struct{}\n",
+		"mappers/mappers.go": `package mappers
+
+import (
+	db "example.com/app/db"
+	pb "example.com/app/gen"
+)
+
+func BookToProto(in *db.Book) *pb.Book {
+	return &pb.Book{}
+}
+`,
+	})
+
+	problems, err := CheckMappers(filepath.Join(dir, "mappers"), nil)
+	if err != nil {
+		t.Fatalf("CheckMappers failed: %v", err)
+	}
+	if len(problems) == 0 {
+		t.Fatal("Expected a problem for the missing protobuf package")
+	}
+	if !strings.Contains(problems[0].String(), "example.com/app/gen") {
+		t.Errorf("Expected the problem to name the missing package, got %s", problems[0])
+	}
+}
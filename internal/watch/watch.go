@@ -0,0 +1,120 @@
+// Package watch detects changes to files by polling, so it works on any
+// platform without inotify or external tools.
+package watch
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// FileState is what a snapshot records about a file
+type FileState struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Snapshot records the state of the given files and of all files below the
+// given directories. Paths that don't exist are skipped, so a file that is
+// created later shows up as a change.
+func Snapshot(paths []string) map[string]FileState {
+	states := make(map[string]FileState)
+	for _, path := range paths {
+		_ = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				states[p] = FileState{ModTime: info.ModTime(), Size: info.Size()}
+			}
+			return nil
+		})
+	}
+	return states
+}
+
+// Changed returns the sorted paths that were added, removed or modified
+// between two snapshots
+func Changed(before, after map[string]FileState) []string {
+	var changed []string
+	for path, state := range after {
+		if prev, ok := before[path]; !ok || prev != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Watcher polls a set of files and directories and reports changes once
+// they have settled
+type Watcher struct {
+	Interval time.Duration // Time between polls
+	Debounce time.Duration // Quiet period after the last change before reporting
+}
+
+// Validate checks that the interval is positive and the debounce period
+// isn't negative
+func (w Watcher) Validate() error {
+	if w.Interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", w.Interval)
+	}
+	if w.Debounce < 0 {
+		return fmt.Errorf("debounce must not be negative, got %s", w.Debounce)
+	}
+	return nil
+}
+
+// Run polls the paths returned by paths until stop is closed, calling
+// onChange with the changed files once no further changes have been seen for
+// the debounce period. The paths are re-read after every change, so the set
+// of watched files can change over time. The watcher must be valid, see
+// Validate.
+func (w Watcher) Run(paths func() []string, stop <-chan struct{}, onChange func(changed []string)) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	last := Snapshot(paths())
+	pending := make(map[string]bool)
+	var lastChange time.Time
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			current := Snapshot(paths())
+			if changed := Changed(last, current); len(changed) > 0 {
+				for _, path := range changed {
+					pending[path] = true
+				}
+				lastChange = now
+			}
+			last = current
+
+			if len(pending) == 0 || now.Sub(lastChange) < w.Debounce {
+				continue
+			}
+
+			changed := make([]string, 0, len(pending))
+			for path := range pending {
+				changed = append(changed, path)
+			}
+			sort.Strings(changed)
+			pending = make(map[string]bool)
+
+			onChange(changed)
+
+			// Start from the state the handler left behind, so files it wrote
+			// don't trigger another run and newly watched paths are picked up
+			last = Snapshot(paths())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChanged(t *testing.T) {
+	dir := t.TempDir()
+	models := filepath.Join(dir, "models.go")
+	queries := filepath.Join(dir, "queries.sql.go")
+	config := filepath.Join(t.TempDir(), "sqlc2proto.yaml")
+
+	if err := os.WriteFile(models, []byte("package db\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(queries, []byte("package db\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	before := Snapshot([]string{dir, config})
+	if len(before) != 2 {
+		t.Fatalf("Expected 2 files in snapshot, got %d", len(before))
+	}
+
+	// Modify one file, remove another and create the missing config
+	if err := os.WriteFile(models, []byte("package db\n\ntype Book struct{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(queries); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte("sqlcDir: db\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := Changed(before, Snapshot([]string{dir, config}))
+	want := []string{models, queries, config}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected changes %v, got %v", want, got)
+	}
+}
+
+func TestWatcherDebounces(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "models.go")
+
+	calls := make(chan []string, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	w := Watcher{Interval: 5 * time.Millisecond, Debounce: 50 * time.Millisecond}
+	go func() {
+		w.Run(func() []string { return []string{dir} }, stop, func(changed []string) { calls <- changed })
+		close(done)
+	}()
+
+	// A burst of writes is reported once
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte("package db\n"+string(rune('a'+i))), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case changed := <-calls:
+		if !reflect.DeepEqual(changed, []string{path}) {
+			t.Errorf("Expected change to %s, got %v", path, changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the change to be reported")
+	}
+
+	select {
+	case changed := <-calls:
+		t.Errorf("Expected a single report, got another for %v", changed)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	close(stop)
+	<-done
+}
+
+func TestWatcherPicksUpNewPaths(t *testing.T) {
+	// Like the watch command after a bad initial config: only the config
+	// file is watched until a reload resolves the sqlc directory
+	dir := t.TempDir()
+	config := filepath.Join(dir, "sqlc2proto.yaml")
+	sqlcDir := filepath.Join(dir, "sqlc")
+	models := filepath.Join(sqlcDir, "models.go")
+	if err := os.WriteFile(config, []byte("sqlcDir: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(sqlcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	watched := []string{config}
+	paths := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return watched
+	}
+
+	calls := make(chan []string, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	w := Watcher{Interval: 5 * time.Millisecond, Debounce: 20 * time.Millisecond}
+	go func() {
+		w.Run(paths, stop, func(changed []string) {
+			mu.Lock()
+			watched = []string{config, sqlcDir}
+			mu.Unlock()
+			calls <- changed
+		})
+		close(done)
+	}()
+
+	next := func() []string {
+		t.Helper()
+		select {
+		case changed := <-calls:
+			return changed
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected a change to be reported")
+			return nil
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(config, []byte("sqlcDir: sqlc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changed := next(); !reflect.DeepEqual(changed, []string{config}) {
+		t.Errorf("Expected change to %s, got %v", config, changed)
+	}
+
+	// The sqlc directory is watched from now on
+	if err := os.WriteFile(models, []byte("package db\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changed := next(); !reflect.DeepEqual(changed, []string{models}) {
+		t.Errorf("Expected change to %s, got %v", models, changed)
+	}
+
+	close(stop)
+	<-done
+}
+
+func TestWatcherValidate(t *testing.T) {
+	tests := []struct {
+		watcher Watcher
+		valid   bool
+	}{
+		{Watcher{Interval: 500 * time.Millisecond, Debounce: 300 * time.Millisecond}, true},
+		{Watcher{Interval: 500 * time.Millisecond}, true},
+		{Watcher{Interval: 0, Debounce: 300 * time.Millisecond}, false},
+		{Watcher{Interval: -time.Second}, false},
+		{Watcher{Interval: time.Second, Debounce: -time.Second}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.watcher.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %t", tt.watcher, err, tt.valid)
+		}
+	}
+}
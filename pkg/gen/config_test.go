@@ -0,0 +1,179 @@
+package gen
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sqlc2proto.yaml")
+	content := `sqlcDir: "./db/sqlc"
+withMapper: true
+serviceNaming: entities
+protoPackage: "library-v1"
+serviceOptions:
+  httpAnnotation: true
+typeMappings:
+  "uuid.UUID": "uuid"
+  "pgtype.Text": "google.protobuf.StringValue"
+targets:
+  - name: billing
+    layout: nested
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("LoadConfig accepted an invalid config")
+	}
+
+	want := []string{
+		path + `:2: unknown key "withMapper", did you mean "withMappers"?`,
+		path + `:3: serviceNaming: invalid value "entities", must be one of entity, flat, custom`,
+		path + `:4: protoPackage: invalid proto package "library-v1"`,
+		path + `:6: serviceOptions: unknown key "httpAnnotation", did you mean "httpAnnotations"?`,
+		path + `:8: typeMappings.uuid.UUID: invalid proto type "uuid"`,
+		path + `:12: targets[0].layout: invalid value "nested", must be one of single, split`,
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(lines), len(want), err)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("error %d = %q, want prefix %q", i, lines[i], prefix)
+		}
+	}
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || configErr.Line != 2 {
+		t.Errorf("errors.As found %+v, want the error on line 2", configErr)
+	}
+}
+
+func TestLoadConfigTypeError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sqlc2proto.yaml")
+	if err := os.WriteFile(path, []byte("sqlcDir: ./db\nwithMappers: maybe\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.HasPrefix(err.Error(), path+":2: ") {
+		t.Errorf("LoadConfig error = %v, want an error on line 2", err)
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	config := DefaultConfig()
+	config.FieldStyle = "camel"
+	if _, err := Parse(ParseOptions{Config: config}); err == nil || !strings.Contains(err.Error(), "fieldStyle") {
+		t.Errorf("Parse error = %v, want an invalid fieldStyle", err)
+	}
+}
+
+// TestConfigSchema checks that the published JSON Schema covers exactly
+// the options of Config
+func TestConfigSchema(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "sqlc2proto.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type schemaObject struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	var schema struct {
+		schemaObject
+		Defs struct {
+			Target schemaObject `json:"target"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	compare := func(name string, t2 reflect.Type, properties map[string]json.RawMessage, extra ...string) {
+		want := append(yamlKeys(t2), extra...)
+		var got []string
+		for key := range properties {
+			got = append(got, key)
+		}
+		slices.Sort(want)
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: schema properties %v, want %v", name, got, want)
+		}
+	}
+	compare("config", reflect.TypeOf(Config{}), schema.Properties)
+
+	var nested map[string]schemaObject
+	if err := json.Unmarshal(data, &struct {
+		Properties *map[string]schemaObject `json:"properties"`
+	}{&nested}); err != nil {
+		t.Fatal(err)
+	}
+	compare("serviceOptions", reflect.TypeOf(ServiceOptions{}), nested["serviceOptions"].Properties)
+	compare("mapperOptions", reflect.TypeOf(MapperOptions{}), nested["mapperOptions"].Properties)
+
+	// Targets have a name and every option except nested targets
+	var targetKeys []string
+	for _, key := range yamlKeys(reflect.TypeOf(Config{})) {
+		if key != "targets" {
+			targetKeys = append(targetKeys, key)
+		}
+	}
+	var got []string
+	for key := range schema.Defs.Target.Properties {
+		got = append(got, key)
+	}
+	want := append(targetKeys, "name")
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("target: schema properties %v, want %v", got, want)
+	}
+}
+
+// yamlKeys returns the YAML keys of the fields of a struct type
+func yamlKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+func TestLoadConfigExplicitFalse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sqlc2proto.yaml")
+	content := "serviceOptions:\n  includePagination: false\ntypeMappings:\n  \"uuid.UUID\": bytes\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	// Options the file sets to false override true defaults, and the
+	// options it doesn't set keep their defaults
+	if config.ServiceOptions.IncludePagination {
+		t.Error("includePagination: false did not override the default")
+	}
+	if config.ServiceOptions.PageSizeField != "limit" || config.ServiceSuffix != "Service" {
+		t.Errorf("unset options lost their defaults: %+v", config)
+	}
+	if config.TypeMappings["uuid.UUID"] != "bytes" {
+		t.Errorf("typeMappings = %v, want uuid.UUID mapped to bytes", config.TypeMappings)
+	}
+}
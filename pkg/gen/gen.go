@@ -0,0 +1,90 @@
+// Package gen generates Protocol Buffer definitions, mappers and services
+// from sqlc output. It is the library behind the sqlc2proto command, for
+// driving generation from build tools and tests.
+//
+// Generation runs in four steps, each returning an error instead of exiting:
+//
+//	schema, err := gen.Parse(gen.ParseOptions{Config: config})
+//	plan, err := gen.Resolve(schema, gen.ResolveOptions{Config: config})
+//	files, err := gen.Render(plan, gen.RenderOptions{})
+//	result, err := gen.Write(files, gen.WriteOptions{})
+//
+// Parse reads the sqlc package and the includes file, Resolve selects the
+// models and queries to generate and builds the services, and Render
+// produces the files in memory. Write is optional: the rendered FileSet can
+// be compared, printed or written by the caller instead.
+package gen
+
+import (
+	"io"
+
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/includes"
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+// Config is the generation configuration, as read from sqlc2proto.yaml
+type Config = config.Config
+
+// ServiceOptions and MapperOptions are the nested sections of Config
+type (
+	ServiceOptions = config.ServiceOptions
+	MapperOptions  = config.MapperOptions
+)
+
+// ConfigError is an invalid option, with the file and line that set it if
+// the configuration was loaded from a file
+type ConfigError = config.ConfigError
+
+// DefaultConfig returns the configuration used when no config file is given
+func DefaultConfig() Config {
+	return config.DefaultConfig()
+}
+
+// LoadConfig reads a configuration file on top of the defaults. Unknown
+// keys and invalid values are reported as ConfigErrors joined with
+// errors.Join.
+func LoadConfig(path string) (Config, error) {
+	cfg := config.DefaultConfig()
+	err := config.LoadConfigFile(io.Discard, path, &cfg)
+	return cfg, err
+}
+
+// Message is a proto message inferred from a sqlc struct
+type Message = parser.ProtoMessage
+
+// QueryMethod is a method of the sqlc Querier interface
+type QueryMethod = parser.QueryMethod
+
+// Service is a proto service built from query methods
+type Service = parser.ServiceDefinition
+
+// TypeMappings maps sqlc Go types to proto types and conversion functions
+type TypeMappings = parser.TypeMappingConfig
+
+// Includes is a parsed includes file selecting models and queries
+type Includes = includes.IncludesFile
+
+// Log receives progress messages from the generation steps. The zero value
+// discards all messages.
+type Log struct {
+	// Printf prints a message. Messages end with a newline.
+	Printf func(format string, args ...any)
+
+	// Verbose enables detailed messages about each step
+	Verbose bool
+}
+
+// info prints a message that is always shown
+func (l Log) info(format string, args ...any) {
+	if l.Printf != nil {
+		l.Printf(format, args...)
+	}
+}
+
+// debug prints a message that is only shown in verbose mode
+func (l Log) debug(format string, args ...any) {
+	if l.Verbose {
+		l.info(format, args...)
+	}
+}
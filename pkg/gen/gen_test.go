@@ -0,0 +1,496 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/boomskats/sqlc2proto/internal/generator"
+)
+
+// libraryDir contains sqlc output and golden files for the library example
+var libraryDir = filepath.Join("..", "..", "internal", "generator", "testdata", "library")
+
+// setupLibrary copies the library example to ./db/sqlc in a temporary
+// working directory
+func setupLibrary(t *testing.T) {
+	t.Helper()
+
+	src := filepath.Join(libraryDir, "sqlc")
+	golden, err := filepath.Abs(filepath.Join(libraryDir, "golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "db", "sqlc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "db", "sqlc", entry.Name()), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(golden, filepath.Join(dir, "golden")); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+}
+
+// libraryConfig returns the configuration used for the golden files
+func libraryConfig() Config {
+	config := DefaultConfig()
+	config.SQLCDir = "./db/sqlc"
+	config.ProtoOutputDir = "./proto/gen"
+	config.ProtoPackageName = "library.v1"
+	config.ModuleName = "example.com/library"
+	config.GoPackagePath = "example.com/library/proto/gen"
+	config.GenerateMappers = true
+	config.GenerateServices = true
+	config.ServiceOptions.HTTPAnnotations = true
+	config.IncludeFile = ""
+	return config
+}
+
+// render runs Parse, Resolve and Render
+func render(t *testing.T, config Config) *FileSet {
+	t.Helper()
+
+	schema, err := Parse(ParseOptions{Config: config})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	plan, err := Resolve(schema, ResolveOptions{Config: config})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	files, err := Render(plan, RenderOptions{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	return files
+}
+
+func TestRenderMatchesGolden(t *testing.T) {
+	setupLibrary(t)
+
+	files := render(t, libraryConfig())
+
+	want := map[string]string{
+		"proto/gen/models.proto":       "models.proto.golden",
+		"proto/gen/mappers/mappers.go": "mappers.go.golden",
+		"proto/gen/service.proto":      "service.proto.golden",
+	}
+	if len(files.Files) != len(want) {
+		t.Errorf("rendered %d files, want %d", len(files.Files), len(want))
+	}
+	for path, goldenName := range want {
+		file, ok := files.Lookup(path)
+		if !ok {
+			t.Errorf("%s was not rendered", path)
+			continue
+		}
+		golden, err := os.ReadFile(filepath.Join("golden", "rpc", goldenName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(file.Content, golden) {
+			t.Errorf("%s does not match %s", path, goldenName)
+		}
+	}
+}
+
+func TestStepsAreRepeatable(t *testing.T) {
+	setupLibrary(t)
+
+	config := libraryConfig()
+	config.ServiceStyle = "aip"
+	config.ServiceOptions.HTTPAnnotations = true
+
+	schema, err := Parse(ParseOptions{Config: config})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// Resolving and rendering twice must not accumulate resource
+	// annotations or HTTP rules
+	var outputs []*FileSet
+	for range 2 {
+		plan, err := Resolve(schema, ResolveOptions{Config: config})
+		if err != nil {
+			t.Fatalf("Resolve failed: %v", err)
+		}
+		for range 2 {
+			files, err := Render(plan, RenderOptions{})
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			outputs = append(outputs, files)
+		}
+	}
+	for _, files := range outputs[1:] {
+		for i, file := range files.Files {
+			if !bytes.Equal(file.Content, outputs[0].Files[i].Content) {
+				t.Errorf("%s differs between runs", file.Path)
+			}
+		}
+	}
+}
+
+func TestTypeMappingsPerConfig(t *testing.T) {
+	setupLibrary(t)
+
+	// The mappings of one configuration don't leak into another
+	custom := libraryConfig()
+	custom.TypeMappings = map[string]string{"pgtype.Date": "string", "int32": "int64"}
+	customModels := content(t, render(t, custom), "proto/gen/models.proto")
+	customServices := content(t, render(t, custom), "proto/gen/service.proto")
+	models := content(t, render(t, libraryConfig()), "proto/gen/models.proto")
+
+	if !strings.Contains(customModels, "string published_on = ") {
+		t.Errorf("Expected published_on mapped to string, got:\n%s", customModels)
+	}
+	if !strings.Contains(customServices, "int64 id = 1") {
+		t.Errorf("Expected the id parameter mapped to int64, got:\n%s", customServices)
+	}
+	if !strings.Contains(models, "google.protobuf.Timestamp published_on = ") || !strings.Contains(models, "int32 id = ") {
+		t.Errorf("Expected the built-in mappings without custom ones, got:\n%s", models)
+	}
+}
+
+func TestParseConcurrently(t *testing.T) {
+	setupLibrary(t)
+
+	// Parse calls with their own type mappings share a cache directory
+	config := libraryConfig()
+	cacheDir := t.TempDir()
+	mappings := []TypeMappings{
+		NewTypeMappings(config),
+		NewTypeMappings(Config{TypeMappings: map[string]string{"int32": "int64"}}),
+	}
+	want := []string{"int32", "int64"}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	types := make([]string, len(errs))
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			schema, err := Parse(ParseOptions{Config: config, TypeMappings: &mappings[i%2], CacheDir: cacheDir})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			for _, message := range schema.Messages {
+				if message.Name == "Book" {
+					types[i] = message.Fields[0].Type
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Parse %d failed: %v", i, err)
+		}
+		if types[i] != want[i%2] {
+			t.Errorf("Parse %d: expected Book.id of type %s, got %q", i, want[i%2], types[i])
+		}
+	}
+}
+
+func TestResolveInvalidLayout(t *testing.T) {
+	config := libraryConfig()
+	config.Layout = "nested"
+	if _, err := Resolve(&Schema{}, ResolveOptions{Config: config}); err == nil {
+		t.Error("Resolve accepted an invalid layout")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	stale := filepath.Join("out", "models.proto")
+	old := generator.GeneratedHeader + "\nold"
+	if err := os.MkdirAll("out", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hand-written files are never removed
+	handWritten := filepath.Join("out", "service.proto")
+	if err := os.WriteFile(handWritten, []byte("syntax = \"proto3\";\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := &FileSet{
+		Files: []File{
+			{Path: filepath.Join("out", "book.proto"), Content: []byte("book"), Kind: "Protobuf definitions"},
+			{Path: filepath.Join("out", "mappers", "book.go"), Content: []byte("mapper"), Kind: "mapper functions"},
+		},
+		Stale: []string{stale, handWritten},
+	}
+
+	changes, err := files.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes before writing, want 3", len(changes))
+	}
+	for _, change := range changes {
+		if change.Path == stale && (change.New != nil || string(change.Old) != old) {
+			t.Errorf("stale file change = %+v, want removal", change)
+		}
+		if change.Path != stale && change.Old != nil {
+			t.Errorf("%s: expected a new file", change.Path)
+		}
+	}
+
+	result, err := Write(files, WriteOptions{})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if len(result.Written) != 2 || len(result.Removed) != 1 {
+		t.Errorf("first write = %+v, want 2 written and 1 removed", result)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale file was not removed")
+	}
+	if _, err := os.Stat(handWritten); err != nil {
+		t.Errorf("hand-written file was removed: %v", err)
+	}
+
+	// Nothing changes on the second run
+	result, err = Write(files, WriteOptions{})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if result.Changed() != 0 || len(result.Unchanged) != 2 {
+		t.Errorf("second write = %+v, want 2 unchanged", result)
+	}
+	if changes, _ := files.Changes(); len(changes) != 0 {
+		t.Errorf("got %d changes after writing, want none", len(changes))
+	}
+}
+
+func TestSwitchFromSplitLayout(t *testing.T) {
+	setupLibrary(t)
+
+	config := libraryConfig()
+	config.Layout = "split"
+	split := render(t, config)
+	if _, err := Write(split, WriteOptions{}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	config.Layout = "single"
+	single := render(t, config)
+	for _, path := range []string{
+		"proto/gen/book.proto",
+		"proto/gen/book_service.proto",
+		"proto/gen/mappers/book.go",
+		"proto/gen/mappers/helpers.go",
+	} {
+		if !slices.Contains(single.Stale, filepath.Clean(path)) {
+			t.Errorf("%s is not marked stale", path)
+		}
+	}
+
+	if _, err := Write(single, WriteOptions{}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, file := range split.Files {
+		if _, ok := single.Lookup(file.Path); ok {
+			continue
+		}
+		if _, err := os.Stat(file.Path); !os.IsNotExist(err) {
+			t.Errorf("%s of the split layout was not removed", file.Path)
+		}
+	}
+	for _, file := range single.Files {
+		if _, err := os.Stat(file.Path); err != nil {
+			t.Errorf("%s was not written: %v", file.Path, err)
+		}
+	}
+}
+
+func TestKeepHandWrittenFiles(t *testing.T) {
+	setupLibrary(t)
+
+	// Files sharing their names with those of the split layout
+	handWritten := map[string]string{
+		"proto/gen/book.proto":         "syntax = \"proto3\";\n\nmessage BookExtras {}\n",
+		"proto/gen/mappers/helpers.go": "package mappers\n\nfunc helper() {}\n",
+	}
+	for path, content := range handWritten {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := render(t, libraryConfig())
+	changes, err := files.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range changes {
+		if change.New == nil {
+			t.Errorf("%s would be removed", change.Path)
+		}
+	}
+
+	result, err := Write(files, WriteOptions{})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if len(result.Removed) != 0 {
+		t.Errorf("Write removed %v", result.Removed)
+	}
+	for path, content := range handWritten {
+		current, err := os.ReadFile(path)
+		if err != nil || string(current) != content {
+			t.Errorf("%s was changed or removed", path)
+		}
+	}
+}
+
+func TestFieldRules(t *testing.T) {
+	setupLibrary(t)
+
+	includesFile := `fields:
+  Member:
+    phone: exclude
+    Email:
+      rename: email_address
+      deprecated: true
+    join_date: outputOnly
+  GetMemberRequest:
+    id: deprecated
+  "*":
+    password_hash: exclude
+`
+	if err := os.WriteFile("sqlc2proto.includes.yaml", []byte(includesFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := libraryConfig()
+	config.IncludeFile = "sqlc2proto.includes.yaml"
+	config.MapperOptions.Strict = true
+
+	var log bytes.Buffer
+	logf := func(format string, args ...any) { fmt.Fprintf(&log, format, args...) }
+	schema, err := Parse(ParseOptions{Config: config})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	plan, err := Resolve(schema, ResolveOptions{Config: config, Log: Log{Printf: logf}})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	files, err := Render(plan, RenderOptions{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	models := content(t, files, "proto/gen/models.proto")
+	for _, want := range []string{
+		"message Member {\n  reserved 4;\n",
+		`string email_address = 3 [json_name="email_address", deprecated = true];`,
+		`join_date = 5 [json_name="join_date", (google.api.field_behavior) = OUTPUT_ONLY];`,
+		`import "google/api/field_behavior.proto";`,
+	} {
+		if !strings.Contains(models, want) {
+			t.Errorf("models.proto is missing %q", want)
+		}
+	}
+	member := models[strings.Index(models, "message Member {"):]
+	member = member[:strings.Index(member, "\n}\n")]
+	if strings.Contains(member, "phone") {
+		t.Errorf("Member contains the excluded phone field:\n%s", member)
+	}
+
+	// The rules of Member apply to the params of its queries too
+	params := models[strings.Index(models, "message CreateMemberParams {"):]
+	params = params[:strings.Index(params, "\n}\n")]
+	if strings.Contains(params, "phone") || !strings.Contains(params, "email_address") {
+		t.Errorf("Member rules were not applied to CreateMemberParams:\n%s", params)
+	}
+
+	service := content(t, files, "proto/gen/service.proto")
+	if !strings.Contains(service, "int32 id = 1 [deprecated = true];") {
+		t.Errorf("Rules were not applied to GetMemberRequest:\n%s", service)
+	}
+
+	// Mappers write the renamed field and leave excluded and output-only
+	// fields zero in FromProto
+	mappers := content(t, files, "proto/gen/mappers/mappers.go")
+	fromProto := mappers[strings.Index(mappers, "func MemberFromProto"):]
+	fromProto = fromProto[:strings.Index(fromProto, "\n}\n")]
+	if !strings.Contains(fromProto, "Email:    in.EmailAddress") {
+		t.Errorf("MemberFromProto doesn't read the renamed field:\n%s", fromProto)
+	}
+	for _, field := range []string{"Phone", "JoinDate"} {
+		if strings.Contains(fromProto, field) {
+			t.Errorf("MemberFromProto sets %s:\n%s", field, fromProto)
+		}
+	}
+
+	if !strings.Contains(log.String(), "field rule *.password_hash on line 11 matches nothing") {
+		t.Errorf("Expected a warning for the unmatched rule, got:\n%s", log.String())
+	}
+}
+
+// content returns the content of a rendered file
+func content(t *testing.T, files *FileSet, path string) string {
+	t.Helper()
+
+	file, ok := files.Lookup(path)
+	if !ok {
+		t.Fatalf("%s was not rendered", path)
+	}
+	return string(file.Content)
+}
+
+func TestExcludedDependency(t *testing.T) {
+	setupLibrary(t)
+
+	if err := os.WriteFile("sqlc2proto.includes.yaml", []byte("exclude:\n  models:\n  - Book\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := libraryConfig()
+	config.IncludeFile = "sqlc2proto.includes.yaml"
+
+	schema, err := Parse(ParseOptions{Config: config})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	_, err = Resolve(schema, ResolveOptions{Config: config})
+	if err == nil {
+		t.Fatal("Expected an error for queries needing the excluded Book")
+	}
+	for _, query := range []string{"CreateBook", "GetBook", "ListBooks", "SearchBooks"} {
+		if !strings.Contains(err.Error(), "query "+query+" needs Book") {
+			t.Errorf("Error doesn't mention %s:\n%v", query, err)
+		}
+	}
+}
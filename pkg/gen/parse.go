@@ -0,0 +1,135 @@
+package gen
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boomskats/sqlc2proto/internal/cache"
+	"github.com/boomskats/sqlc2proto/internal/includes"
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+// cacheMaxAge is how long unused cache entries are kept
+const cacheMaxAge = 7 * 24 * time.Hour
+
+// ParseOptions configures Parse
+type ParseOptions struct {
+	// Config provides the sqlc directory, the field style, the includes
+	// file and whether to parse queries for service generation
+	Config Config
+
+	// TypeMappings map the Go types of the sqlc structs and query
+	// parameters to proto types. If nil, the mappings of Config are used,
+	// see NewTypeMappings.
+	TypeMappings *TypeMappings
+
+	// CacheDir is where parse results of unchanged sqlc files are kept
+	// between runs. Caching is disabled when it is empty. Parse calls may
+	// run concurrently, also with the same CacheDir.
+	CacheDir string
+
+	// CacheKey identifies the build of the calling tool. Cached results
+	// are only reused by runs with the same key and configuration.
+	CacheKey string
+
+	Log Log
+}
+
+// Schema is the content of a sqlc package
+type Schema struct {
+	// Messages are the proto messages inferred from the sqlc structs. Their
+	// conversion code is built by Resolve, after the field rules.
+	Messages []Message
+
+	// Queries are the methods of the Querier interface. They are only
+	// parsed when service generation is enabled.
+	Queries []QueryMethod
+
+	// Includes is the parsed includes file, or nil if there is none
+	Includes *Includes
+
+	// TypeMappings are the type mappings used for the messages, and by
+	// Resolve for the query parameters
+	TypeMappings TypeMappings
+}
+
+// NewTypeMappings returns the built-in type mappings extended with the
+// typeMappings and nullableTypeMappings of a configuration. The built-in
+// mappings are not changed.
+func NewTypeMappings(config Config) TypeMappings {
+	return parser.NewTypeMapConfig(config.TypeMappings, config.NullableTypeMappings)
+}
+
+// Parse reads the sqlc package and the includes file of the configuration
+func Parse(opts ParseOptions) (*Schema, error) {
+	config := opts.Config
+	log := opts.Log
+
+	// Options from flags or code haven't been validated by LoadConfig
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Reuse the parse results of sqlc files that haven't changed
+	var parseCache *cache.Cache
+	if opts.CacheDir != "" {
+		c, err := cache.Open(opts.CacheDir, fmt.Sprintf("%s %+v", opts.CacheKey, config))
+		if err != nil {
+			log.info("Warning: cache disabled: %v\n", err)
+		} else {
+			parseCache = c
+			defer func() {
+				hits, misses := c.Stats()
+				log.info("Parsed %d changed file(s), reused %d from the cache\n", misses, hits)
+				_ = c.Prune(cacheMaxAge)
+			}()
+		}
+	}
+
+	schema := &Schema{TypeMappings: NewTypeMappings(config)}
+	if opts.TypeMappings != nil {
+		schema.TypeMappings = *opts.TypeMappings
+	}
+
+	// Check if includeFile is specified and exists
+	if config.IncludeFile != "" {
+		log.debug("Looking for includes file at %s\n", config.IncludeFile)
+
+		includesFile, err := includes.LoadIncludesFile(config.IncludeFile)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, fmt.Errorf("error loading includes file: %w", err)
+			}
+			log.info("Includes file %s not found. Run 'sqlc2proto getincludes' to generate it.\n", config.IncludeFile)
+			log.info("Proceeding with generating all models and queries...\n")
+		} else {
+			schema.Includes = &includesFile
+			log.debug("Loaded includes file with %d models and %d queries\n",
+				len(includesFile.Models), len(includesFile.Queries))
+		}
+	}
+
+	// Process sqlc directory, loading it once for both models and queries
+	pkg, err := parser.LoadPackage(config.SQLCDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process sqlc directory: %w", err)
+	}
+	pkg.Cache = parseCache
+	schema.Messages, err = pkg.Messages(config.FieldStyle, schema.TypeMappings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process sqlc directory: %w", err)
+	}
+
+	// Parse the Querier interface if service generation is enabled
+	if config.GenerateServices {
+		schema.Queries, err = pkg.QueryMethods()
+		if err != nil {
+			log.debug("Warning: Failed to parse Querier interface: %v\n", err)
+			log.debug("Make sure sqlc is configured with emit_interface: true\n")
+			log.debug("Skipping service generation...\n")
+		}
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,164 @@
+package gen
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/boomskats/sqlc2proto/internal/generator"
+)
+
+// File is a generated file rendered in memory
+type File struct {
+	Path    string
+	Content []byte
+
+	// Kind describes the generated code, e.g. "mapper functions"
+	Kind string
+}
+
+// FileSet is the output of Render
+type FileSet struct {
+	Files []File
+
+	// Stale are files of other layouts that the rendered files replace.
+	// Their definitions would clash with the new files, so Write removes
+	// those that sqlc2proto generated.
+	Stale []string
+}
+
+// RenderOptions configures Render
+type RenderOptions struct {
+	Log Log
+}
+
+// Render renders the proto files, mappers and services of a plan in memory
+func Render(plan *Plan, opts RenderOptions) (*FileSet, error) {
+	config := plan.Config
+	messages := plan.Messages
+	split := config.Layout == "split"
+
+	fs := &FileSet{}
+	add := func(kind string, files ...generator.File) {
+		for _, file := range files {
+			fs.Files = append(fs.Files, File{Path: file.Path, Content: file.Content, Kind: kind})
+		}
+	}
+
+	// Generate proto files
+	protoPath := filepath.Join(config.ProtoOutputDir, "models.proto")
+	if split {
+		files, err := generator.RenderSplitProtoFiles(messages, config, config.ProtoOutputDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate proto files: %w", err)
+		}
+		fs.Stale = append(fs.Stale, protoPath)
+		add("Protobuf definitions", files...)
+	} else {
+		file, err := generator.ProtoFile(messages, config, protoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate proto file: %w", err)
+		}
+		fs.Stale = append(fs.Stale, generator.SplitProtoPaths(messages, config.ProtoOutputDir)...)
+		add("Protobuf definitions", file)
+	}
+
+	// Generate mapper files if requested
+	if config.GenerateMappers {
+		// Remove old mappers.go file if it exists (for backward compatibility)
+		fs.Stale = append(fs.Stale, filepath.Join(config.ProtoOutputDir, "mappers.go"))
+
+		mappersDir := filepath.Join(config.ProtoOutputDir, "mappers")
+		mapperPath := filepath.Join(mappersDir, "mappers.go")
+		if split {
+			files, err := generator.RenderSplitMapperFiles(messages, config, mappersDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate mapper files: %w", err)
+			}
+			fs.Stale = append(fs.Stale, mapperPath)
+			add("mapper functions", files...)
+		} else {
+			file, err := generator.MapperFile(messages, config, mapperPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate mapper file: %w", err)
+			}
+			fs.Stale = append(fs.Stale, generator.SplitMapperPaths(messages, mappersDir)...)
+			add("mapper functions", file)
+		}
+
+		// Generate round-trip tests for the mappers if requested
+		if config.MapperOptions.GenerateTests {
+			file, err := generator.MapperTestFile(messages, config, filepath.Join(mappersDir, "mappers_test.go"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate mapper tests: %w", err)
+			}
+			add("mapper tests", file)
+		}
+	}
+
+	// Generate service definitions if requested
+	if len(plan.Services) > 0 {
+		opts.Log.debug("Generating services for %d query methods\n", len(plan.Queries))
+		for _, method := range plan.Queries {
+			opts.Log.debug("  - %s (returns %s)\n", method.Name, method.ReturnType)
+		}
+
+		// Service options are applied in place, so render a copy to keep
+		// the plan reusable
+		services := copyServices(plan.Services)
+		servicePath := filepath.Join(config.ProtoOutputDir, "service.proto")
+		if split || config.ServiceOptions.SplitServices {
+			// Generate one proto file per service
+			modelFiles := generator.ModelFiles(messages, split)
+			files, err := generator.RenderSplitServiceFiles(services, modelFiles, config, config.ProtoOutputDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate service files: %w", err)
+			}
+			fs.Stale = append(fs.Stale, servicePath)
+			add("service definitions", files...)
+		} else {
+			file, err := generator.ServiceFile(services, config, servicePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate service file: %w", err)
+			}
+			fs.Stale = append(fs.Stale, generator.SplitServicePaths(services, config.ProtoOutputDir)...)
+			add("service definitions", file)
+		}
+	}
+
+	// An entity may share its file name with a file of the current layout,
+	// e.g. an entity named Models, so never remove a rendered file
+	fs.Stale = slices.DeleteFunc(fs.Stale, func(path string) bool {
+		_, rendered := fs.Lookup(path)
+		return rendered
+	})
+
+	return fs, nil
+}
+
+// copyServices returns a copy of the services with their own methods
+func copyServices(services []Service) []Service {
+	copied := make([]Service, len(services))
+	for i, service := range services {
+		service.Methods = slices.Clone(service.Methods)
+		for j := range service.Methods {
+			method := &service.Methods[j]
+			method.RequestFields = slices.Clone(method.RequestFields)
+			method.ResponseFields = slices.Clone(method.ResponseFields)
+			method.Options = slices.Clone(method.Options)
+		}
+		copied[i] = service
+	}
+	return copied
+}
+
+// Lookup returns the rendered file with the given path
+func (fs *FileSet) Lookup(path string) (File, bool) {
+	path = filepath.Clean(path)
+	for _, file := range fs.Files {
+		if filepath.Clean(file.Path) == path {
+			return file, true
+		}
+	}
+	return File{}, false
+}
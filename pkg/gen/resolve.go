@@ -0,0 +1,188 @@
+package gen
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/boomskats/sqlc2proto/internal/config"
+	"github.com/boomskats/sqlc2proto/internal/includes"
+	"github.com/boomskats/sqlc2proto/internal/parser"
+)
+
+// ResolveOptions configures Resolve
+type ResolveOptions struct {
+	Config Config
+
+	// ReadGoMod reads the module name from go.mod in the working directory
+	// when neither the Go package path nor the module name is configured
+	ReadGoMod bool
+
+	Log Log
+}
+
+// Plan is what Render generates: the completed configuration and the
+// selected messages, queries and services
+type Plan struct {
+	Config   Config
+	Messages []Message
+	Queries  []QueryMethod
+	Services []Service
+}
+
+// Resolve completes the configuration, filters the schema with its includes
+// file, builds the conversion code of the messages and the service
+// definitions
+func Resolve(schema *Schema, opts ResolveOptions) (*Plan, error) {
+	cfg := opts.Config
+	log := opts.Log
+
+	// Options from flags or code haven't been validated by LoadConfig
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Infer the Go package from the proto package and the module name
+	if cfg.GoPackagePath == "" {
+		if opts.ReadGoMod && cfg.ModuleName == "" {
+			if moduleName, err := config.GetModuleNameFromGoMod(); err == nil {
+				cfg.ModuleName = moduleName
+				log.debug("Found module name in go.mod: %s\n", moduleName)
+			}
+		}
+		cfg.GoPackagePath = config.InferGoPackage(cfg.ProtoPackageName, cfg.ModuleName)
+	}
+
+	// Resolving annotates messages, so work on copies to keep the schema
+	// reusable
+	plan := &Plan{
+		Config:   cfg,
+		Messages: copyMessages(schema.Messages),
+		Queries:  schema.Queries,
+	}
+
+	// Filter messages and queries based on includes file
+	if inc := schema.Includes; inc != nil && !inc.IsEmpty() {
+		// Point out entries that select nothing, e.g. misspelled names.
+		// Queries are only known when service generation is enabled.
+		var queryNames []string
+		for _, method := range plan.Queries {
+			queryNames = append(queryNames, method.Name)
+		}
+		var modelNames []string
+		for _, msg := range plan.Messages {
+			modelNames = append(modelNames, msg.Name)
+		}
+		for _, warning := range includes.Unmatched(*inc, modelNames, queryNames) {
+			log.info("Warning: %s: %s\n", cfg.IncludeFile, warning)
+		}
+
+		// Resolve the dependencies of the included queries and models
+		resolved, dependencies, err := includes.ResolveDependencies(*inc, plan.Queries, plan.Messages)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.IncludeFile, err)
+		}
+
+		// Log which models are included due to dependencies, and why
+		if len(dependencies) > 0 {
+			log.debug("Models included due to dependencies:\n")
+			for _, dependency := range dependencies {
+				log.debug("  - %s\n", dependency)
+			}
+		}
+
+		var messages []Message
+		for _, msg := range plan.Messages {
+			if includes.IsModelIncluded(resolved, msg.Name) {
+				messages = append(messages, msg)
+			}
+		}
+		plan.Messages = messages
+
+		var queries []QueryMethod
+		for _, method := range plan.Queries {
+			if includes.IsQueryIncluded(*inc, method.Name) {
+				queries = append(queries, method)
+			}
+		}
+		plan.Queries = queries
+
+		log.debug("After filtering: %d message types and %d query methods\n",
+			len(plan.Messages), len(plan.Queries))
+	}
+
+	// Field rules from the includes file apply to the models before the
+	// services copy their fields, and then to the request and response
+	// messages
+	var rules *includes.FieldRuleSet
+	if schema.Includes != nil && len(schema.Includes.FieldRules) > 0 {
+		rules = includes.NewFieldRuleSet(schema.Includes.FieldRules)
+		if err := rules.ApplyToMessages(plan.Messages); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.IncludeFile, err)
+		}
+	}
+
+	// Schemas not built by Parse use the mappings of the configuration
+	typeMappings := schema.TypeMappings
+	if typeMappings.StandardTypes == nil {
+		typeMappings = NewTypeMappings(cfg)
+	}
+
+	// The mappers read the fields by the names the rules gave them
+	parser.GenerateConversionCode(plan.Messages, typeMappings)
+
+	log.debug("Generating %d message types from %s\n", len(plan.Messages), cfg.SQLCDir)
+	for _, msg := range plan.Messages {
+		log.debug("  - %s (%d fields)\n", msg.Name, len(msg.Fields))
+	}
+
+	buildServices(plan, schema, typeMappings, log)
+
+	if rules != nil {
+		if err := rules.ApplyToServices(plan.Services); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.IncludeFile, err)
+		}
+		for _, warning := range append(rules.Unmatched(), rules.Leaked()...) {
+			log.info("Warning: %s: %s\n", cfg.IncludeFile, warning)
+		}
+	}
+
+	return plan, nil
+}
+
+// buildServices builds the service definitions of the selected queries
+func buildServices(plan *Plan, schema *Schema, typeMappings TypeMappings, log Log) {
+	cfg := plan.Config
+	if !cfg.GenerateServices {
+		return
+	}
+	if len(plan.Queries) == 0 {
+		log.info("No query methods found or selected. Skipping service generation.\n")
+		return
+	}
+
+	// Explicit service grouping and method settings from the includes file
+	var overrides map[string]parser.MethodOverride
+	if schema.Includes != nil {
+		overrides = includes.MethodOverrides(*schema.Includes)
+	}
+
+	// Resource-oriented services annotate the messages they operate on
+	if cfg.ServiceStyle == "aip" {
+		plan.Services = parser.GenerateAIPServiceDefinitions(plan.Queries, plan.Messages, typeMappings, config.ResourceDomain(cfg), overrides)
+		parser.ApplyResourceAnnotations(plan.Messages, plan.Services)
+	} else {
+		plan.Services = parser.GenerateServiceDefinitions(plan.Queries, plan.Messages, typeMappings, overrides)
+	}
+}
+
+// copyMessages returns a copy of the messages with their own fields and
+// options
+func copyMessages(messages []Message) []Message {
+	copied := make([]Message, len(messages))
+	for i, msg := range messages {
+		msg.Fields = slices.Clone(msg.Fields)
+		msg.Options = slices.Clone(msg.Options)
+		copied[i] = msg
+	}
+	return copied
+}
@@ -0,0 +1,104 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/boomskats/sqlc2proto/internal/generator"
+)
+
+// WriteOptions configures Write
+type WriteOptions struct {
+	Log Log
+}
+
+// WriteResult lists the paths Write touched
+type WriteResult struct {
+	Written   []string
+	Removed   []string
+	Unchanged []string
+}
+
+// Changed returns the number of files written or removed
+func (r *WriteResult) Changed() int {
+	return len(r.Written) + len(r.Removed)
+}
+
+// Write writes the rendered files and removes the stale files of other
+// layouts. Files that are already up to date are left alone, preserving
+// their modification times for build tools. Stale files are only removed if
+// sqlc2proto generated them.
+func Write(fs *FileSet, opts WriteOptions) (*WriteResult, error) {
+	result := &WriteResult{}
+	for _, path := range fs.Stale {
+		if !staleGenerated(path) {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			opts.Log.info("Removed %s\n", path)
+			result.Removed = append(result.Removed, path)
+		}
+	}
+
+	for _, file := range fs.Files {
+		if current, err := os.ReadFile(file.Path); err == nil && bytes.Equal(current, file.Content) {
+			result.Unchanged = append(result.Unchanged, file.Path)
+			continue
+		}
+		if err := generator.WriteFiles([]generator.File{{Path: file.Path, Content: file.Content}}); err != nil {
+			return result, fmt.Errorf("failed to write %s: %w", file.Kind, err)
+		}
+		opts.Log.info("Generated %s in %s\n", file.Kind, file.Path)
+		result.Written = append(result.Written, file.Path)
+	}
+
+	if len(result.Unchanged) > 0 {
+		opts.Log.info("%d generated file(s) unchanged\n", len(result.Unchanged))
+	}
+	return result, nil
+}
+
+// Change is a difference between a file on disk and the file set
+type Change struct {
+	Path string
+
+	// Old is the content on disk, nil if the file doesn't exist
+	Old []byte
+
+	// New is the rendered content, nil if the file would be removed
+	New []byte
+}
+
+// Changes compares the file set with the files on disk and returns the
+// files that Write would create, update or remove. Like Write, it only
+// reports stale files that sqlc2proto generated.
+func (fs *FileSet) Changes() ([]Change, error) {
+	var changes []Change
+	for _, file := range fs.Files {
+		current, err := os.ReadFile(file.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil && bytes.Equal(current, file.Content) {
+			continue
+		}
+		changes = append(changes, Change{Path: file.Path, Old: current, New: file.Content})
+	}
+
+	for _, path := range fs.Stale {
+		if current, err := os.ReadFile(path); err == nil && generator.IsGenerated(current) {
+			changes = append(changes, Change{Path: path, Old: current})
+		}
+	}
+
+	return changes, nil
+}
+
+// staleGenerated reports whether a stale file exists and was generated by
+// sqlc2proto. Hand-written files that share a name with a file of another
+// layout, e.g. proto/book.proto, are kept.
+func staleGenerated(path string) bool {
+	content, err := os.ReadFile(path)
+	return err == nil && generator.IsGenerated(content)
+}